@@ -1,6 +1,7 @@
 package main
 
 import (
+	"flag"
 	"fmt"
 	"net/http"
 
@@ -17,7 +18,19 @@ import (
 var Router *mux.Router
 
 func main() {
+	flag.DurationVar(&gluster.ShortTimeout, "short-timeout", gluster.ShortTimeout, "timeout of gluster info/status/list commands")
+	flag.DurationVar(&gluster.LongTimeout, "long-timeout", gluster.LongTimeout, "timeout of gluster create/start/stop/brick/rebalance commands")
+	flag.StringVar(&gluster.ScheduleFile, "schedule-file", gluster.ScheduleFile, "snapshot schedules, on gluster shared storage so every node sees them")
+	flag.DurationVar(&gluster.ScheduleRunTimeout, "schedule-run-timeout", gluster.ScheduleRunTimeout, "longest a scheduled snapshot run may take, a run left RUNNING longer is marked failed")
+	flag.StringVar(&gluster.SplitBrainAuditFile, "split-brain-audit-file", gluster.SplitBrainAuditFile, "split-brain resolution audit trail, on gluster shared storage so every node sees it")
+	opTimeouts := flag.String("op-timeouts", "", `per operation timeouts, e.g. "volume create=20m,volume heal=1h"`)
+	flag.Parse()
+
 	L.Gluster.Info("In Gluster-rest")
+	if e := gluster.ParseOpTimeouts(*opTimeouts); e != nil {
+		fmt.Println(e.Error())
+		return
+	}
 
 	// coredump stack
 	defer func() {
@@ -27,6 +40,8 @@ func main() {
 		}
 	}()
 
+	gluster.StartScheduler()
+
 	// http router
 	Router = mux.NewRouter()
 
@@ -37,18 +52,90 @@ func main() {
 	Router.HandleFunc("/gluster/peer/status", gluster.ProcessPeerStatus).Methods("GET")
 
 	// volume
+	Router.HandleFunc("/gluster/volumes", gluster.ProcessVolumeList).Methods("GET")
 	Router.HandleFunc("/gluster/volume/create", gluster.ProcessVolumeCreate).Methods("POST")
+	Router.HandleFunc("/gluster/volume/plan", gluster.ProcessVolumePlan).Methods("POST")
 	Router.HandleFunc("/gluster/volume/start", gluster.ProcessVolumeStart).Methods("POST")
 	Router.HandleFunc("/gluster/volume/stop", gluster.ProcessVolumeStop).Methods("POST")
 	Router.HandleFunc("/gluster/volume/delete", gluster.ProcessVolumeDelete).Methods("POST")
 	Router.HandleFunc("/gluster/volume/info", gluster.ProcessVolumeInfo).Methods("POST")
 	Router.HandleFunc("/gluster/volume/status", gluster.ProcessVolumeStatus).Methods("POST")
 	Router.HandleFunc("/gluster/volume/health", gluster.ProcessVolumeHealth).Methods("POST")
+	Router.HandleFunc("/gluster/volume/heal/enable", gluster.ProcessVolumeHealEnable).Methods("POST")
+	Router.HandleFunc("/gluster/volume/heal/disable", gluster.ProcessVolumeHealDisable).Methods("POST")
+	Router.HandleFunc("/gluster/volume/heal/index", gluster.ProcessVolumeHealIndex).Methods("POST")
+	Router.HandleFunc("/gluster/volume/heal/full", gluster.ProcessVolumeHealFull).Methods("POST")
+	Router.HandleFunc("/gluster/volume/heal/options", gluster.ProcessVolumeHealOptions).Methods("POST")
+	Router.HandleFunc("/gluster/volume/heal/info", gluster.ProcessVolumeHealInfo).Methods("POST")
+	Router.HandleFunc("/gluster/volume/heal/info/summary", gluster.ProcessVolumeHealInfoSummary).Methods("POST")
+	Router.HandleFunc("/gluster/volume/heal/info/split-brain", gluster.ProcessVolumeHealInfoSplitBrain).Methods("POST")
+	Router.HandleFunc("/gluster/volume/heal/split-brain/resolve", gluster.ProcessVolumeSplitBrainResolve).Methods("POST")
+	Router.HandleFunc("/gluster/volume/heal/split-brain/audit", gluster.ProcessVolumeSplitBrainAudit).Methods("GET")
 	Router.HandleFunc("/gluster/volume/rebalance", gluster.ProcessVolumeReBalance).Methods("POST")
 
+	// volume options
+	Router.HandleFunc("/gluster/volume/options/set", gluster.ProcessVolumeOptionSet).Methods("POST")
+	Router.HandleFunc("/gluster/volume/options/get", gluster.ProcessVolumeOptionGet).Methods("POST")
+	Router.HandleFunc("/gluster/volume/options/list", gluster.ProcessVolumeOptionList).Methods("POST")
+	Router.HandleFunc("/gluster/volume/options/reset", gluster.ProcessVolumeOptionReset).Methods("POST")
+	Router.HandleFunc("/gluster/volume/options/catalog", gluster.ProcessVolumeOptionCatalog).Methods("GET")
+
+	// quota
+	Router.HandleFunc("/gluster/volume/quota/enable", gluster.ProcessVolumeQuotaEnable).Methods("POST")
+	Router.HandleFunc("/gluster/volume/quota/disable", gluster.ProcessVolumeQuotaDisable).Methods("POST")
+	Router.HandleFunc("/gluster/volume/quota/limit-usage", gluster.ProcessVolumeQuotaLimitUsage).Methods("POST")
+	Router.HandleFunc("/gluster/volume/quota/limit-objects", gluster.ProcessVolumeQuotaLimitObjects).Methods("POST")
+	Router.HandleFunc("/gluster/volume/quota/remove", gluster.ProcessVolumeQuotaRemove).Methods("POST")
+	Router.HandleFunc("/gluster/volume/quota/list", gluster.ProcessVolumeQuotaList).Methods("POST")
+	Router.HandleFunc("/gluster/volume/quota/list-objects", gluster.ProcessVolumeQuotaListObjects).Methods("POST")
+	Router.HandleFunc("/gluster/volume/quota/default-soft-limit", gluster.ProcessVolumeQuotaDefaultSoftLimit).Methods("POST")
+	Router.HandleFunc("/gluster/volume/quota/alert-time", gluster.ProcessVolumeQuotaAlertTime).Methods("POST")
+
 	// brick
 	Router.HandleFunc("/gluster/volume/brick/add", gluster.ProcessVolumeAddBrick).Methods("POST")
+	Router.HandleFunc("/gluster/volume/expand", gluster.ProcessVolumeExpand).Methods("POST")
+	Router.HandleFunc("/gluster/volume/shrink", gluster.ProcessVolumeShrink).Methods("POST")
 	Router.HandleFunc("/gluster/volume/brick/remove", gluster.ProcessVolumeRemoveBrick).Methods("POST")
+	Router.HandleFunc("/gluster/volume/brick/remove/start", gluster.ProcessVolumeRemoveBrickStart).Methods("POST")
+	Router.HandleFunc("/gluster/volume/brick/remove/status", gluster.ProcessVolumeRemoveBrickStatus).Methods("POST")
+	Router.HandleFunc("/gluster/volume/brick/remove/commit", gluster.ProcessVolumeRemoveBrickCommit).Methods("POST")
+	Router.HandleFunc("/gluster/volume/brick/remove/stop", gluster.ProcessVolumeRemoveBrickStop).Methods("POST")
+
+	Router.HandleFunc("/gluster/volume/brick/replace", gluster.ProcessVolumeReplaceBrick).Methods("POST")
+	Router.HandleFunc("/gluster/volume/brick/replace-failed", gluster.ProcessVolumeReplaceFailedBrick).Methods("POST")
+	Router.HandleFunc("/gluster/volume/brick/reset/start", gluster.ProcessVolumeResetBrickStart).Methods("POST")
+	Router.HandleFunc("/gluster/volume/brick/reset/commit", gluster.ProcessVolumeResetBrickCommit).Methods("POST")
+	Router.HandleFunc("/gluster/brick/path/check", gluster.ProcessBrickPathCheck).Methods("POST")
+	Router.HandleFunc("/gluster/brick/device/prepare", gluster.ProcessBrickDevicePrepare).Methods("POST")
+
+	// snapshot
+	Router.HandleFunc("/gluster/snapshot/create", gluster.ProcessSnapshotCreate).Methods("POST")
+	Router.HandleFunc("/gluster/snapshot/list", gluster.ProcessSnapshotList).Methods("POST")
+	Router.HandleFunc("/gluster/snapshot/info", gluster.ProcessSnapshotInfo).Methods("POST")
+	Router.HandleFunc("/gluster/snapshot/status", gluster.ProcessSnapshotStatus).Methods("POST")
+	Router.HandleFunc("/gluster/snapshot/activate", gluster.ProcessSnapshotActivate).Methods("POST")
+	Router.HandleFunc("/gluster/snapshot/deactivate", gluster.ProcessSnapshotDeactivate).Methods("POST")
+	Router.HandleFunc("/gluster/snapshot/delete", gluster.ProcessSnapshotDelete).Methods("POST")
+	Router.HandleFunc("/gluster/snapshot/restore", gluster.ProcessSnapshotRestore).Methods("POST")
+	Router.HandleFunc("/gluster/snapshot/clone", gluster.ProcessSnapshotClone).Methods("POST")
+	Router.HandleFunc("/gluster/snapshot/config", gluster.ProcessSnapshotConfig).Methods("POST")
+	Router.HandleFunc("/gluster/snapshot/config/set", gluster.ProcessSnapshotConfigSet).Methods("POST")
+	Router.HandleFunc("/gluster/brick/thin/check", gluster.ProcessBrickThinCheck).Methods("POST")
+
+	// snapshot schedule
+	Router.HandleFunc("/gluster/snapshot/schedule/add", gluster.ProcessSnapshotScheduleAdd).Methods("POST")
+	Router.HandleFunc("/gluster/snapshot/schedule/update", gluster.ProcessSnapshotScheduleUpdate).Methods("POST")
+	Router.HandleFunc("/gluster/snapshot/schedule/delete", gluster.ProcessSnapshotScheduleDelete).Methods("POST")
+	Router.HandleFunc("/gluster/snapshot/schedule/list", gluster.ProcessSnapshotScheduleList).Methods("GET")
+	Router.HandleFunc("/gluster/snapshot/schedule/run", gluster.ProcessSnapshotScheduleRun).Methods("POST")
+
+	// job
+	Router.HandleFunc("/gluster/jobs", gluster.ProcessJobList).Methods("GET")
+	Router.HandleFunc("/gluster/jobs/{id}", gluster.ProcessJobGet).Methods("GET")
+	Router.HandleFunc("/gluster/jobs/{id}/cancel", gluster.ProcessJobCancel).Methods("POST")
+
+	// lock
+	Router.HandleFunc("/gluster/locks", gluster.ProcessLockList).Methods("GET")
 
 	// mount
 	Router.HandleFunc("/gluster/mount/add", gluster.ProcessMountAdd).Methods("POST")
@@ -57,9 +144,9 @@ func main() {
 
 	// http server
 	svr := http.Server{
-		Addr:         ":7030",
+		Addr:         ":" + gluster.ServicePort,
 		ReadTimeout:  300 * time.Second,
-		WriteTimeout: 300 * time.Second,
+		WriteTimeout: gluster.MaxLockWait + gluster.MaxTimeout() + 30*time.Second, // the lock wait and reply of the slowest command
 		Handler: handlers.CORS(
 			handlers.AllowedHeaders([]string{"X-Requested-With", "Content-Type", "Authorization"}),
 			handlers.AllowedMethods([]string{"GET", "POST", "PUT", "HEAD", "OPTIONS", "DELETE"}),
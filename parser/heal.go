@@ -0,0 +1,162 @@
+package parser
+
+import (
+	"encoding/xml"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// HealBrick is a brick of `gluster volume heal <vol> info [summary|split-brain] --xml`.
+// Counts are zero when the brick could not be reached, see Connected.
+type HealBrick struct {
+	Name            string      `json:"name"` // host:/path
+	Host            string      `json:"host"`
+	Path            string      `json:"path"`
+	HostUUID        string      `json:"host_uuid"`
+	Status          string      `json:"status"` // Connected, or why the brick was not reached
+	Connected       bool        `json:"connected"`
+	Entries         int         `json:"entries"` // pending, or in split-brain for split-brain
+	HealPending     int         `json:"heal_pending"`
+	SplitBrain      int         `json:"split_brain"`
+	PossiblyHealing int         `json:"possibly_healing"`
+	Files           []HealEntry `json:"files,omitempty"` // not listed by summary
+}
+
+// HealEntry is a file or directory needing heal. Path is empty when the brick
+// only knows the gfid, e.g. the entry was deleted on another brick.
+type HealEntry struct {
+	GFID string `json:"gfid"`
+	Path string `json:"path,omitempty"`
+}
+
+type healInfoXML struct {
+	XMLName  xml.Name `xml:"cliOutput"`
+	OpRet    int      `xml:"opRet"`
+	OpErrno  int      `xml:"opErrno"`
+	OpErrstr string   `xml:"opErrstr"`
+	Bricks   []struct {
+		HostUUID string `xml:"hostUuid,attr"`
+		Name     string `xml:"name"`
+		Status   string `xml:"status"`
+		Files    []struct {
+			GFID string `xml:"gfid,attr"`
+			Path string `xml:",chardata"`
+		} `xml:"file"`
+		// "-" when the brick is not connected
+		NumberOfEntries string `xml:"numberOfEntries"`
+		Total           string `xml:"totalNumberOfEntries"`
+		HealPending     string `xml:"numberOfEntriesInHealPending"`
+		SplitBrain      string `xml:"numberOfEntriesInSplitBrain"`
+		PossiblyHealing string `xml:"numberOfEntriesPossiblyHealing"`
+	} `xml:"healInfo>bricks>brick"`
+}
+
+// ParseHealInfo parses `gluster volume heal <vol> info [summary|split-brain] --xml`.
+func ParseHealInfo(data []byte) ([]HealBrick, error) {
+	var info healInfoXML
+	if e := xml.Unmarshal(data, &info); e != nil {
+		return nil, fmt.Errorf("parse heal info: %s", e)
+	}
+	if info.OpRet != 0 {
+		return nil, fmt.Errorf("heal info failed: %s", info.OpErrstr)
+	}
+
+	bricks := []HealBrick{}
+	for _, b := range info.Bricks {
+		host, path := SplitBrick(b.Name)
+		brick := HealBrick{
+			Name:            b.Name,
+			Host:            host,
+			Path:            path,
+			HostUUID:        b.HostUUID,
+			Status:          b.Status,
+			Connected:       b.Status == "Connected",
+			Entries:         atoi(b.NumberOfEntries),
+			HealPending:     atoi(b.HealPending),
+			SplitBrain:      atoi(b.SplitBrain),
+			PossiblyHealing: atoi(b.PossiblyHealing),
+		}
+		if b.Total != "" { // summary
+			brick.Entries = atoi(b.Total)
+		}
+		for _, f := range b.Files {
+			entry := HealEntry{GFID: f.GFID, Path: strings.TrimSpace(f.Path)}
+			if strings.HasPrefix(entry.Path, "<gfid:") {
+				entry.GFID = strings.TrimSuffix(strings.TrimPrefix(entry.Path, "<gfid:"), ">")
+				entry.Path = ""
+			}
+			brick.Files = append(brick.Files, entry)
+		}
+		bricks = append(bricks, brick)
+	}
+	return bricks, nil
+}
+
+func atoi(s string) int {
+	n, _ := strconv.Atoi(strings.TrimSpace(s))
+	return n
+}
+
+// HealCrawl is one crawl of the self-heal daemon over a brick, from
+// `gluster volume heal <vol> statistics`.
+type HealCrawl struct {
+	Brick      int       `json:"brick"` // index of the brick in the volume
+	Host       string    `json:"host"`
+	Type       string    `json:"type"` // INDEX, FULL
+	Start      time.Time `json:"start"`
+	End        time.Time `json:"end,omitempty"`
+	InProgress bool      `json:"in_progress"`
+	Healed     int       `json:"healed"`
+	SplitBrain int       `json:"split_brain"`
+	Failed     int       `json:"failed"`
+}
+
+// crawlTime is the ctime format of the statistics. The times are the wall
+// clock of the node of the brick, without a zone, and are read as local time:
+// good to show and to tell crawls apart, not to compare with this node's clock.
+const crawlTime = "Mon Jan _2 15:04:05 2006"
+
+// ParseHealStatistics parses the plain output of `gluster volume heal <vol>
+// statistics`, which has no --xml form. A brick lists its recent crawls, an
+// ending time of "Crawl is in progress" marks the running one.
+func ParseHealStatistics(data []byte) []HealCrawl {
+	var crawls []HealCrawl
+	var crawl *HealCrawl
+	brick, host := -1, ""
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		key, value := line, ""
+		if i := strings.Index(line, ":"); i >= 0 {
+			key, value = strings.TrimSpace(line[:i]), strings.TrimSpace(line[i+1:])
+		}
+		switch {
+		case strings.HasPrefix(line, "Crawl statistics for brick no "):
+			brick, host = atoi(strings.TrimPrefix(line, "Crawl statistics for brick no ")), ""
+			crawl = nil
+		case strings.HasPrefix(line, "Hostname of brick "):
+			host = strings.TrimPrefix(line, "Hostname of brick ")
+		case key == "Starting time of crawl":
+			start, _ := time.ParseInLocation(crawlTime, value, time.Local)
+			crawls = append(crawls, HealCrawl{Brick: brick, Host: host, Start: start})
+			crawl = &crawls[len(crawls)-1]
+		case crawl == nil:
+		case key == "Ending time of crawl":
+			if value == "Crawl is in progress" {
+				crawl.InProgress = true
+			} else {
+				crawl.End, _ = time.ParseInLocation(crawlTime, value, time.Local)
+			}
+		case key == "Type of crawl":
+			crawl.Type = value
+		case key == "No. of entries healed":
+			crawl.Healed = atoi(value)
+		case key == "No. of entries in split-brain":
+			crawl.SplitBrain = atoi(value)
+		case key == "No. of heal failed entries":
+			crawl.Failed = atoi(value)
+		}
+	}
+	return crawls
+}
@@ -0,0 +1,92 @@
+package parser
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseHealInfo(t *testing.T) {
+	bricks, e := ParseHealInfo(fixture(t, "heal_info.xml"))
+	if e != nil {
+		t.Fatal(e)
+	}
+	if len(bricks) != 2 {
+		t.Fatalf("got %d bricks, want 2", len(bricks))
+	}
+
+	b := bricks[0]
+	if b.Name != "node1:/data/brick1/test" || b.Host != "node1" || !b.Connected || b.Entries != 3 {
+		t.Errorf("brick 0 = %+v", b)
+	}
+	want := []HealEntry{
+		{GFID: "8f0b1c3e-2d4a-4b6c-9e8f-7a6b5c4d3e2f", Path: "/dir/a.txt"},
+		{GFID: "00000000-0000-0000-0000-000000000001", Path: "/"},
+		{GFID: "c4d3e2f1-a0b9-4c8d-8e7f-6a5b4c3d2e1f"}, // only known by gfid
+	}
+	if len(b.Files) != len(want) {
+		t.Fatalf("got %d files, want %d", len(b.Files), len(want))
+	}
+	for i := range want {
+		if b.Files[i] != want[i] {
+			t.Errorf("file %d = %+v, want %+v", i, b.Files[i], want[i])
+		}
+	}
+
+	b = bricks[1]
+	if b.Connected || b.Status != "Transport endpoint is not connected" || b.Entries != 0 {
+		t.Errorf("brick 1 = %+v", b)
+	}
+}
+
+func TestParseHealInfoSummary(t *testing.T) {
+	bricks, e := ParseHealInfo(fixture(t, "heal_info_summary.xml"))
+	if e != nil {
+		t.Fatal(e)
+	}
+	if len(bricks) != 1 {
+		t.Fatalf("got %d bricks, want 1", len(bricks))
+	}
+	b := bricks[0]
+	if b.Entries != 5 || b.HealPending != 3 || b.SplitBrain != 1 || b.PossiblyHealing != 1 || len(b.Files) != 0 {
+		t.Errorf("got %+v", b)
+	}
+}
+
+func TestParseHealStatistics(t *testing.T) {
+	crawls := ParseHealStatistics(fixture(t, "heal_statistics.txt"))
+	at := func(s string) time.Time {
+		tm, _ := time.ParseInLocation(crawlTime, s, time.Local)
+		return tm
+	}
+	want := []HealCrawl{
+		{Brick: 0, Host: "node1", Type: "INDEX", Start: at("Thu Apr 11 08:25:34 2019"), End: at("Thu Apr 11 08:25:35 2019"), Healed: 4, SplitBrain: 1},
+		{Brick: 0, Host: "node1", Type: "INDEX", Start: at("Thu Apr 11 08:35:34 2019"), InProgress: true},
+		{Brick: 1, Host: "node2", Type: "FULL", Start: at("Thu Apr 11 08:25:36 2019"), InProgress: true, Healed: 2, Failed: 1},
+	}
+	if len(crawls) != len(want) {
+		t.Fatalf("got %d crawls, want %d", len(crawls), len(want))
+	}
+	for i := range want {
+		if crawls[i] != want[i] {
+			t.Errorf("crawl %d = %+v, want %+v", i, crawls[i], want[i])
+		}
+	}
+}
+
+func TestHealable(t *testing.T) {
+	tests := []struct {
+		name   string
+		volume Volume
+		want   bool
+	}{
+		{"distribute", Volume{ReplicaCount: 1, SubvolumeSize: 1}, false},
+		{"replicate", Volume{ReplicaCount: 3, SubvolumeSize: 3}, true},
+		{"disperse", Volume{DisperseCount: 3, RedundancyCount: 1, SubvolumeSize: 3}, true},
+		{"stripe", Volume{ReplicaCount: 1, SubvolumeSize: 2}, false},
+	}
+	for _, test := range tests {
+		if got := test.volume.Healable(); got != test.want {
+			t.Errorf("%s: healable %v, want %v", test.name, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,118 @@
+package parser
+
+import (
+	"encoding/xml"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// QuotaLimit is a directory of `gluster volume quota <vol> list --xml`.
+// Sizes are bytes.
+type QuotaLimit struct {
+	Path             string `json:"path"`
+	HardLimit        uint64 `json:"hard_limit"`
+	SoftLimitPercent string `json:"soft_limit_percent"`
+	SoftLimit        uint64 `json:"soft_limit"`
+	Used             uint64 `json:"used"`
+	Available        uint64 `json:"available"`
+	SoftExceeded     bool   `json:"soft_limit_exceeded"`
+	Exceeded         bool   `json:"exceeded"` // hard limit
+}
+
+// QuotaObjectLimit is a directory of `gluster volume quota <vol> list-objects --xml`.
+type QuotaObjectLimit struct {
+	Path             string `json:"path"`
+	HardLimit        uint64 `json:"hard_limit"`
+	SoftLimitPercent string `json:"soft_limit_percent"`
+	SoftLimit        uint64 `json:"soft_limit"`
+	FileCount        uint64 `json:"file_count"`
+	DirCount         uint64 `json:"dir_count"`
+	Available        uint64 `json:"available"`
+	SoftExceeded     bool   `json:"soft_limit_exceeded"`
+	Exceeded         bool   `json:"exceeded"`
+}
+
+// the CLI prints N/A for directories it could not account yet
+type quotaLimitXML struct {
+	Path             string `xml:"path"`
+	HardLimit        string `xml:"hard_limit"`
+	SoftLimitPercent string `xml:"soft_limit_percent"`
+	SoftLimitValue   string `xml:"soft_limit_value"`
+	UsedSpace        string `xml:"used_space"`
+	AvailSpace       string `xml:"avail_space"`
+	FileCount        string `xml:"file_count"`
+	DirCount         string `xml:"dir_count"`
+	Available        string `xml:"available"`
+	SlExceeded       string `xml:"sl_exceeded"`
+	HlExceeded       string `xml:"hl_exceeded"`
+}
+
+type volQuotaXML struct {
+	XMLName  xml.Name        `xml:"cliOutput"`
+	OpRet    int             `xml:"opRet"`
+	OpErrno  int             `xml:"opErrno"`
+	OpErrstr string          `xml:"opErrstr"`
+	Limits   []quotaLimitXML `xml:"volQuota>limit"`
+}
+
+func parseUint(s string) uint64 {
+	n, _ := strconv.ParseUint(strings.TrimSpace(s), 10, 64)
+	return n
+}
+
+func parseQuota(data []byte) ([]quotaLimitXML, error) {
+	var quota volQuotaXML
+	if e := xml.Unmarshal(data, &quota); e != nil {
+		return nil, fmt.Errorf("parse quota list: %s", e)
+	}
+	if quota.OpRet != 0 {
+		return nil, fmt.Errorf("quota list failed: %s", quota.OpErrstr)
+	}
+	return quota.Limits, nil
+}
+
+// ParseQuotaList parses `gluster volume quota <vol> list [<path>...] --xml`.
+func ParseQuotaList(data []byte) ([]QuotaLimit, error) {
+	raw, e := parseQuota(data)
+	if e != nil {
+		return nil, e
+	}
+	limits := []QuotaLimit{}
+	for _, l := range raw {
+		limits = append(limits, QuotaLimit{
+			Path:             l.Path,
+			HardLimit:        parseUint(l.HardLimit),
+			SoftLimitPercent: l.SoftLimitPercent,
+			SoftLimit:        parseUint(l.SoftLimitValue),
+			Used:             parseUint(l.UsedSpace),
+			Available:        parseUint(l.AvailSpace),
+			SoftExceeded:     l.SlExceeded == "Yes",
+			Exceeded:         l.HlExceeded == "Yes",
+		})
+	}
+	return limits, nil
+}
+
+// ParseQuotaObjects parses `gluster volume quota <vol> list-objects [<path>...] --xml`.
+func ParseQuotaObjects(data []byte) ([]QuotaObjectLimit, error) {
+	raw, e := parseQuota(data)
+	if e != nil {
+		return nil, e
+	}
+	limits := []QuotaObjectLimit{}
+	for _, l := range raw {
+		limits = append(limits, QuotaObjectLimit{
+			Path:             l.Path,
+			HardLimit:        parseUint(l.HardLimit),
+			SoftLimitPercent: l.SoftLimitPercent,
+			SoftLimit:        parseUint(l.SoftLimitValue),
+			FileCount:        parseUint(l.FileCount),
+			DirCount:         parseUint(l.DirCount),
+			Available:        parseUint(l.Available),
+			SoftExceeded:     l.SlExceeded == "Yes",
+			Exceeded:         l.HlExceeded == "Yes",
+		})
+	}
+	return limits, nil
+}
@@ -0,0 +1,39 @@
+package parser
+
+import "testing"
+
+func TestParseQuotaList(t *testing.T) {
+	limits, e := ParseQuotaList(fixture(t, "quota_list.xml"))
+	if e != nil {
+		t.Fatal(e)
+	}
+	want := []QuotaLimit{
+		{Path: "/data", HardLimit: 10737418240, SoftLimitPercent: "80%", SoftLimit: 8589934592, Used: 9663676416, Available: 1073741824, SoftExceeded: true},
+		{Path: "/new", SoftLimitPercent: "N/A"}, // not accounted yet
+	}
+	if len(limits) != len(want) {
+		t.Fatalf("got %d limits, want %d", len(limits), len(want))
+	}
+	for i := range want {
+		if limits[i] != want[i] {
+			t.Errorf("limit %d = %+v, want %+v", i, limits[i], want[i])
+		}
+	}
+}
+
+func TestParseQuotaObjects(t *testing.T) {
+	limits, e := ParseQuotaObjects(fixture(t, "quota_list_objects.xml"))
+	if e != nil {
+		t.Fatal(e)
+	}
+	want := QuotaObjectLimit{Path: "/data", HardLimit: 100000, SoftLimitPercent: "80%", SoftLimit: 80000, FileCount: 1200, DirCount: 35, Available: 98765}
+	if len(limits) != 1 || limits[0] != want {
+		t.Errorf("got %+v, want %+v", limits, want)
+	}
+}
+
+func TestParseQuotaDisabled(t *testing.T) {
+	if _, e := ParseQuotaList(fixture(t, "quota_disabled.xml")); e == nil {
+		t.Error("no error for opRet -1")
+	}
+}
@@ -0,0 +1,253 @@
+package parser
+
+import (
+	"encoding/xml"
+	"fmt"
+	"strings"
+	"time"
+)
+
+type Snapshot struct {
+	Name        string         `json:"name"`
+	UUID        string         `json:"uuid"`
+	Description string         `json:"description,omitempty"`
+	CreateTime  *time.Time     `json:"create_time,omitempty"`
+	Volume      string         `json:"volume"`      // origin volume
+	SnapVolume  string         `json:"snap_volume"` // volume holding the snapshot bricks
+	Status      VolumeStatus   `json:"status"`      // Started when activated
+	Activated   bool           `json:"activated"`
+	Origin      *SnapshotQuota `json:"origin,omitempty"` // snapshots taken and left on the origin volume
+}
+
+type SnapshotQuota struct {
+	SnapCount     int `json:"snap_count"`
+	SnapRemaining int `json:"snap_remaining"`
+}
+
+// SnapshotStatus is a snapshot of `gluster snapshot status --xml`.
+type SnapshotStatus struct {
+	Name   string                `json:"name"`
+	UUID   string                `json:"uuid"`
+	Bricks []SnapshotBrickStatus `json:"bricks"`
+}
+
+type SnapshotBrickStatus struct {
+	Path           string `json:"path"` // host:/run/gluster/snaps/<snapvol>/brickN/...
+	VolumeGroup    string `json:"volume_group"`
+	Running        bool   `json:"running"`
+	Pid            string `json:"pid"`
+	DataPercentage string `json:"data_percentage"`
+	LvSize         string `json:"lv_size"`
+}
+
+// SnapshotConfig is `gluster snapshot config [<vol>] --xml`.
+type SnapshotConfig struct {
+	HardLimit        int                    `json:"snap_max_hard_limit"`
+	SoftLimit        string                 `json:"snap_max_soft_limit"` // percent of the hard limit
+	AutoDelete       bool                   `json:"auto_delete"`
+	ActivateOnCreate bool                   `json:"activate_on_create"`
+	Volumes          []VolumeSnapshotConfig `json:"volumes"`
+}
+
+type VolumeSnapshotConfig struct {
+	Name               string `json:"name"`
+	HardLimit          int    `json:"snap_max_hard_limit"`
+	EffectiveHardLimit int    `json:"effective_hard_limit"`
+	SoftLimit          int    `json:"snap_max_soft_limit"`
+	EffectiveSoftLimit int    `json:"effective_soft_limit"`
+}
+
+type snapshotXML struct {
+	Name        string `xml:"name"`
+	UUID        string `xml:"uuid"`
+	Description string `xml:"description"`
+	CreateTime  string `xml:"createTime"`
+	SnapVolume  struct {
+		Name         string `xml:"name"`
+		Status       string `xml:"status"`
+		OriginVolume struct {
+			Name          string `xml:"name"`
+			SnapCount     int    `xml:"snapCount"`
+			SnapRemaining int    `xml:"snapRemaining"`
+		} `xml:"originVolume"`
+	} `xml:"snapVolume"`
+}
+
+type snapOutputXML struct {
+	XMLName  xml.Name `xml:"cliOutput"`
+	OpRet    int      `xml:"opRet"`
+	OpErrno  int      `xml:"opErrno"`
+	OpErrstr string   `xml:"opErrstr"`
+
+	// snapshot create/activate/deactivate/delete report the snapshot they acted on
+	Created     snapshotXML `xml:"snapCreate>snapshot"`
+	Activated   snapshotXML `xml:"snapActivate>snapshot"`
+	Deactivated snapshotXML `xml:"snapDeactivate>snapshot"`
+	Deleted     snapshotXML `xml:"snapDelete>snapshot"`
+	Cloned      struct {
+		Name string `xml:"name"`
+		UUID string `xml:"uuid"`
+	} `xml:"CloneCreate>volume"`
+
+	List []string      `xml:"snapList>snapshot"`
+	Info []snapshotXML `xml:"snapInfo>snapshots>snapshot"`
+
+	Status []struct {
+		Name   string `xml:"name"`
+		UUID   string `xml:"uuid"`
+		Bricks []struct {
+			Path           string `xml:"path"`
+			VolumeGroup    string `xml:"volumeGroup"`
+			BrickRunning   string `xml:"brick_running"`
+			Pid            string `xml:"pid"`
+			DataPercentage string `xml:"data_percentage"`
+			LvSize         string `xml:"lvSize"`
+		} `xml:"volume>brick"`
+	} `xml:"snapStatus>snapshots>snapshot"`
+
+	Config struct {
+		HardLimit        int    `xml:"systemConfig>hardLimit"`
+		SoftLimit        string `xml:"systemConfig>softLimit"`
+		AutoDelete       string `xml:"systemConfig>autoDelete"`
+		ActivateOnCreate string `xml:"systemConfig>activateOnCreate"`
+		Volumes          []struct {
+			Name               string `xml:"name"`
+			HardLimit          int    `xml:"hardLimit"`
+			EffectiveHardLimit int    `xml:"effectiveHardLimit"`
+			SoftLimit          int    `xml:"softLimit"`
+			EffectiveSoftLimit int    `xml:"effectiveSoftLimit"`
+		} `xml:"volumeConfig>volume"`
+	} `xml:"snapConfig"`
+}
+
+// snapshot create time, glusterd formats it in UTC
+const snapTimeLayout = "2006-01-02 15:04:05"
+
+func parseSnapOutput(data []byte, op string) (*snapOutputXML, error) {
+	var out snapOutputXML
+	if e := xml.Unmarshal(data, &out); e != nil {
+		return nil, fmt.Errorf("parse snapshot %s: %s", op, e)
+	}
+	if out.OpRet != 0 {
+		return nil, fmt.Errorf("snapshot %s failed: %s", op, out.OpErrstr)
+	}
+	return &out, nil
+}
+
+// ParseSnapshotAction parses the reply of `gluster snapshot
+// create|activate|deactivate|delete|clone --xml` into the snapshot, or the
+// clone volume, it acted on.
+func ParseSnapshotAction(data []byte, op string) (Snapshot, error) {
+	out, e := parseSnapOutput(data, op)
+	if e != nil {
+		return Snapshot{}, e
+	}
+	var s snapshotXML
+	switch op {
+	case "create":
+		s = out.Created
+	case "activate":
+		s = out.Activated
+	case "deactivate":
+		s = out.Deactivated
+	case "delete":
+		s = out.Deleted
+	case "clone":
+		s.Name, s.UUID = out.Cloned.Name, out.Cloned.UUID
+	}
+	return Snapshot{Name: s.Name, UUID: s.UUID}, nil
+}
+
+// ParseSnapshotList parses `gluster snapshot list [<vol>] --xml`.
+func ParseSnapshotList(data []byte) ([]string, error) {
+	out, e := parseSnapOutput(data, "list")
+	if e != nil {
+		return nil, e
+	}
+	names := []string{}
+	for _, name := range out.List {
+		if name = strings.TrimSpace(name); name != "" {
+			names = append(names, name)
+		}
+	}
+	return names, nil
+}
+
+// ParseSnapshotInfo parses `gluster snapshot info [<snap>|volume <vol>] --xml`.
+func ParseSnapshotInfo(data []byte) ([]Snapshot, error) {
+	out, e := parseSnapOutput(data, "info")
+	if e != nil {
+		return nil, e
+	}
+	snapshots := []Snapshot{}
+	for _, s := range out.Info {
+		snapshot := Snapshot{
+			Name:        s.Name,
+			UUID:        s.UUID,
+			Description: s.Description,
+			Volume:      s.SnapVolume.OriginVolume.Name,
+			SnapVolume:  s.SnapVolume.Name,
+			Status:      VolumeStatus(s.SnapVolume.Status),
+			Activated:   s.SnapVolume.Status == string(VolumeStarted),
+			Origin: &SnapshotQuota{
+				SnapCount:     s.SnapVolume.OriginVolume.SnapCount,
+				SnapRemaining: s.SnapVolume.OriginVolume.SnapRemaining,
+			},
+		}
+		if t, e := time.Parse(snapTimeLayout, s.CreateTime); e == nil {
+			snapshot.CreateTime = &t
+		}
+		snapshots = append(snapshots, snapshot)
+	}
+	return snapshots, nil
+}
+
+// ParseSnapshotStatus parses `gluster snapshot status [<snap>|volume <vol>] --xml`.
+func ParseSnapshotStatus(data []byte) ([]SnapshotStatus, error) {
+	out, e := parseSnapOutput(data, "status")
+	if e != nil {
+		return nil, e
+	}
+	statuses := []SnapshotStatus{}
+	for _, s := range out.Status {
+		status := SnapshotStatus{Name: s.Name, UUID: s.UUID, Bricks: []SnapshotBrickStatus{}}
+		for _, b := range s.Bricks {
+			status.Bricks = append(status.Bricks, SnapshotBrickStatus{
+				Path:           b.Path,
+				VolumeGroup:    b.VolumeGroup,
+				Running:        strings.EqualFold(b.BrickRunning, "yes"),
+				Pid:            b.Pid,
+				DataPercentage: b.DataPercentage,
+				LvSize:         b.LvSize,
+			})
+		}
+		statuses = append(statuses, status)
+	}
+	return statuses, nil
+}
+
+// ParseSnapshotConfig parses `gluster snapshot config [<vol>] --xml`.
+func ParseSnapshotConfig(data []byte) (SnapshotConfig, error) {
+	out, e := parseSnapOutput(data, "config")
+	if e != nil {
+		return SnapshotConfig{}, e
+	}
+	c := out.Config
+	config := SnapshotConfig{
+		HardLimit:        c.HardLimit,
+		SoftLimit:        c.SoftLimit,
+		AutoDelete:       c.AutoDelete == "enable",
+		ActivateOnCreate: c.ActivateOnCreate == "enable",
+		Volumes:          []VolumeSnapshotConfig{},
+	}
+	for _, v := range c.Volumes {
+		config.Volumes = append(config.Volumes, VolumeSnapshotConfig{
+			Name:               v.Name,
+			HardLimit:          v.HardLimit,
+			EffectiveHardLimit: v.EffectiveHardLimit,
+			SoftLimit:          v.SoftLimit,
+			EffectiveSoftLimit: v.EffectiveSoftLimit,
+		})
+	}
+	return config, nil
+}
@@ -0,0 +1,65 @@
+package parser
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseSnapshotAction(t *testing.T) {
+	snapshot, e := ParseSnapshotAction(fixture(t, "snapshot_create.xml"), "create")
+	if e != nil {
+		t.Fatal(e)
+	}
+	if snapshot.Name != "snap1_GMT-2019.04.11-08.25.34" || snapshot.UUID != "3f1b6d2e-8c4a-4e9b-a1d7-5c2f0e9b8a47" {
+		t.Errorf("got %+v", snapshot)
+	}
+	if _, e := ParseSnapshotAction(fixture(t, "snapshot_create_limit.xml"), "create"); e == nil {
+		t.Error("no error for opRet -1")
+	}
+}
+
+func TestParseSnapshotInfo(t *testing.T) {
+	snapshots, e := ParseSnapshotInfo(fixture(t, "snapshot_info.xml"))
+	if e != nil {
+		t.Fatal(e)
+	}
+	if len(snapshots) != 1 {
+		t.Fatalf("got %d snapshots, want 1", len(snapshots))
+	}
+	s := snapshots[0]
+	if s.Name != "snap1_GMT-2019.04.11-08.25.34" || s.Description != "before upgrade" || s.Volume != "test" ||
+		s.SnapVolume != "a8f2c1e4d9b74f6e8a3c5b2d1e0f9a7c" || s.Status != VolumeStopped || s.Activated {
+		t.Errorf("got %+v", s)
+	}
+	if s.Origin == nil || s.Origin.SnapCount != 1 || s.Origin.SnapRemaining != 255 {
+		t.Errorf("origin = %+v", s.Origin)
+	}
+	created := time.Date(2019, 4, 11, 8, 25, 34, 0, time.UTC)
+	if s.CreateTime == nil || !s.CreateTime.Equal(created) {
+		t.Errorf("create time = %v, want %v", s.CreateTime, created)
+	}
+}
+
+func TestParseSnapshotList(t *testing.T) {
+	names, e := ParseSnapshotList(fixture(t, "snapshot_list.xml"))
+	if e != nil {
+		t.Fatal(e)
+	}
+	if len(names) != 2 || names[0] != "snap1_GMT-2019.04.11-08.25.34" || names[1] != "snap2_GMT-2019.04.12-08.00.01" {
+		t.Errorf("got %q", names)
+	}
+}
+
+func TestParseSnapshotConfig(t *testing.T) {
+	config, e := ParseSnapshotConfig(fixture(t, "snapshot_config.xml"))
+	if e != nil {
+		t.Fatal(e)
+	}
+	if config.HardLimit != 256 || config.SoftLimit != "90%" || config.AutoDelete || !config.ActivateOnCreate {
+		t.Errorf("got %+v", config)
+	}
+	want := VolumeSnapshotConfig{Name: "test", HardLimit: 256, EffectiveHardLimit: 256, SoftLimit: 230, EffectiveSoftLimit: 230}
+	if len(config.Volumes) != 1 || config.Volumes[0] != want {
+		t.Errorf("volumes = %+v, want %+v", config.Volumes, want)
+	}
+}
@@ -0,0 +1,147 @@
+package parser
+
+import (
+	"encoding/xml"
+	"fmt"
+	"strconv"
+)
+
+// BrickStatus is a brick of `gluster volume status <vol> [detail] --xml`.
+// The sizes are only reported with detail.
+type BrickStatus struct {
+	Volume    string `json:"volume"`
+	Name      string `json:"name"` // host:/path
+	Host      string `json:"host"`
+	Path      string `json:"path"`
+	PeerID    string `json:"peer_id"`
+	Online    bool   `json:"online"`
+	Port      string `json:"port"`
+	Pid       int    `json:"pid"`
+	SizeTotal uint64 `json:"size_total,omitempty"`
+	SizeFree  uint64 `json:"size_free,omitempty"`
+	Device    string `json:"device,omitempty"`
+	FsName    string `json:"fs_name,omitempty"`
+}
+
+// ParseVolumeStatus parses `gluster volume status [<vol>|all] [detail] --xml`
+// into its bricks by "host:/path". Daemons such as the self-heal daemon are
+// reported with the path "localhost" and are skipped.
+func ParseVolumeStatus(data []byte) (map[string]BrickStatus, error) {
+	var status volStatusXML
+	if e := xml.Unmarshal(data, &status); e != nil {
+		return nil, fmt.Errorf("parse volume status: %s", e)
+	}
+	if status.OpRet != 0 {
+		return nil, fmt.Errorf("volume status failed: %s", status.OpErrstr)
+	}
+
+	bricks := make(map[string]BrickStatus)
+	for _, volume := range status.Volumes {
+		for _, node := range volume.Nodes {
+			if node.Path == "localhost" {
+				continue
+			}
+			name := node.Hostname + ":" + node.Path
+			bricks[name] = BrickStatus{
+				Volume:    volume.VolName,
+				Name:      name,
+				Host:      node.Hostname,
+				Path:      node.Path,
+				PeerID:    node.PeerID,
+				Online:    node.Status == 1,
+				Port:      node.Port,
+				Pid:       node.Pid,
+				SizeTotal: node.SizeTotal,
+				SizeFree:  node.SizeFree,
+				Device:    node.Device,
+				FsName:    node.FsName,
+			}
+		}
+	}
+	return bricks, nil
+}
+
+type HealthState string
+
+const (
+	Healthy  HealthState = "healthy"
+	Degraded HealthState = "degraded" // bricks down, still writable
+	Down     HealthState = "down"     // quorum lost
+)
+
+// SubvolumeHealth is derived from the brick status of a subvolume.
+type SubvolumeHealth struct {
+	State        HealthState `json:"state"`
+	BricksOnline int         `json:"bricks_online"`
+	BricksTotal  int         `json:"bricks_total"`
+	QuorumCount  int         `json:"quorum_count"` // bricks needed for writes
+	Quorum       bool        `json:"quorum"`
+	Degraded     bool        `json:"degraded"`
+}
+
+// ApplyStatus sets the brick state and the health of every subvolume of v.
+func (v *Volume) ApplyStatus(bricks map[string]BrickStatus) {
+	for i := range v.Subvolumes {
+		s := &v.Subvolumes[i]
+		health := SubvolumeHealth{BricksTotal: len(s.Bricks)}
+		online := make([]bool, len(s.Bricks))
+		for j, b := range s.Bricks {
+			online[j] = bricks[b.Name].Online
+			if online[j] {
+				health.BricksOnline++
+			}
+		}
+
+		health.QuorumCount, health.Quorum = v.quorum(*s, online, health.BricksOnline)
+		health.Degraded = health.Quorum && health.BricksOnline < health.BricksTotal
+		switch {
+		case !health.Quorum:
+			health.State = Down
+		case health.Degraded:
+			health.State = Degraded
+		default:
+			health.State = Healthy
+		}
+		s.Health = &health
+	}
+}
+
+// quorum returns the bricks needed for writes and whether they are online.
+// Replicate follows cluster.quorum-type/quorum-count, auto by default: more
+// than half of the bricks, or exactly half including the first one. Disperse
+// needs its data bricks.
+func (v *Volume) quorum(s Subvolume, online []bool, count int) (int, bool) {
+	total := len(s.Bricks)
+	switch s.Type {
+	case SubvolumeReplicate:
+		switch v.Option("cluster.quorum-type") {
+		case "none":
+			return 1, count >= 1
+		case "fixed":
+			n, e := strconv.Atoi(v.Option("cluster.quorum-count"))
+			if e != nil || n < 1 {
+				n = 1
+			}
+			return n, count >= n
+		}
+		need := total/2 + 1
+		if total%2 == 0 && count == total/2 && online[0] {
+			return total / 2, true
+		}
+		return need, count >= need
+	case SubvolumeDisperse:
+		need := v.DisperseCount - v.RedundancyCount
+		return need, count >= need
+	}
+	return total, count == total
+}
+
+// Option returns the reconfigured value of name, "" when it is not set.
+func (v *Volume) Option(name string) string {
+	for _, o := range v.Options {
+		if o.Name == name {
+			return o.Value
+		}
+	}
+	return ""
+}
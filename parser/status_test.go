@@ -0,0 +1,89 @@
+package parser
+
+import "testing"
+
+func TestParseVolumeStatus(t *testing.T) {
+	bricks, e := ParseVolumeStatus(fixture(t, "volume_status_detail.xml"))
+	if e != nil {
+		t.Fatal(e)
+	}
+	// the self-heal daemon is not a brick
+	if len(bricks) != 3 {
+		t.Fatalf("got %d bricks, want 3", len(bricks))
+	}
+	b, ok := bricks["node1:/data/brick1/test"]
+	if !ok {
+		t.Fatal("node1:/data/brick1/test missing")
+	}
+	if !b.Online || b.Port != "49152" || b.Pid != 2314 || b.SizeTotal != 107321753600 || b.SizeFree != 75125227520 ||
+		b.Device != "/dev/mapper/vg_bricks-brick1" || b.FsName != "xfs" {
+		t.Errorf("node1 brick = %+v", b)
+	}
+	if bricks["node3:/data/brick1/test"].Online {
+		t.Error("node3 brick is online")
+	}
+}
+
+func TestApplyStatus(t *testing.T) {
+	volumes, e := ParseVolumeInfo(fixture(t, "volume_info.xml"))
+	if e != nil {
+		t.Fatal(e)
+	}
+	bricks, e := ParseVolumeStatus(fixture(t, "volume_status_detail.xml"))
+	if e != nil {
+		t.Fatal(e)
+	}
+	v := volumes[0]
+	v.ApplyStatus(bricks)
+
+	tests := []struct {
+		state  HealthState
+		online int
+		quorum bool
+	}{
+		{Degraded, 2, true}, // arbiter down, 2 of 3 keep quorum
+		{Down, 0, false},    // not in the status
+	}
+	for i, test := range tests {
+		h := v.Subvolumes[i].Health
+		if h == nil {
+			t.Fatalf("subvolume %d has no health", i)
+		}
+		if h.State != test.state || h.BricksOnline != test.online || h.Quorum != test.quorum || h.QuorumCount != 2 {
+			t.Errorf("subvolume %d health = %+v", i, *h)
+		}
+	}
+}
+
+func TestQuorum(t *testing.T) {
+	tests := []struct {
+		name    string
+		options []Option
+		online  []bool
+		need    int
+		quorum  bool
+	}{
+		{"auto majority", nil, []bool{false, true, true}, 2, true},
+		{"auto minority", nil, []bool{false, false, true}, 2, false},
+		{"auto half with first", nil, []bool{true, false}, 1, true},
+		{"auto half without first", nil, []bool{false, true}, 2, false},
+		{"none", []Option{{"cluster.quorum-type", "none"}}, []bool{false, false, true}, 1, true},
+		{"fixed", []Option{{"cluster.quorum-type", "fixed"}, {"cluster.quorum-count", "3"}}, []bool{true, true, false}, 3, false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			v := Volume{Options: test.options}
+			s := Subvolume{Type: SubvolumeReplicate, Bricks: make([]Brick, len(test.online))}
+			count := 0
+			for _, online := range test.online {
+				if online {
+					count++
+				}
+			}
+			need, quorum := v.quorum(s, test.online, count)
+			if need != test.need || quorum != test.quorum {
+				t.Errorf("got %d %v, want %d %v", need, quorum, test.need, test.quorum)
+			}
+		})
+	}
+}
@@ -0,0 +1,213 @@
+// Package parser turns the --xml output of the gluster CLI into typed values.
+package parser
+
+import (
+	"encoding/xml"
+	"fmt"
+	"strings"
+)
+
+type VolumeStatus string
+
+const (
+	VolumeCreated VolumeStatus = "Created"
+	VolumeStarted VolumeStatus = "Started"
+	VolumeStopped VolumeStatus = "Stopped"
+	VolumeUnknown VolumeStatus = "Unknown"
+)
+
+// volume status codes of glusterd, GLUSTERD_STATUS_*
+var volumeStatuses = map[int]VolumeStatus{
+	0: VolumeCreated,
+	1: VolumeStarted,
+	2: VolumeStopped,
+}
+
+// VolumeType is the typeStr of a volume, e.g. Distributed-Replicate.
+type VolumeType string
+
+const (
+	TypeDistribute           VolumeType = "Distribute"
+	TypeReplicate            VolumeType = "Replicate"
+	TypeDistributedReplicate VolumeType = "Distributed-Replicate"
+	TypeDisperse             VolumeType = "Disperse"
+	TypeDistributedDisperse  VolumeType = "Distributed-Disperse"
+	TypeStripe               VolumeType = "Stripe"
+	TypeDistributedStripe    VolumeType = "Distributed-Stripe"
+	TypeStripedReplicate     VolumeType = "Striped-Replicate"
+	TypeDistStripedReplicate VolumeType = "Distributed-Striped-Replicate"
+	TypeTier                 VolumeType = "Tier"
+)
+
+// SubvolumeType is the translator joining the bricks of a subvolume.
+type SubvolumeType string
+
+const (
+	SubvolumeDistribute SubvolumeType = "distribute" // a single brick
+	SubvolumeReplicate  SubvolumeType = "replicate"
+	SubvolumeDisperse   SubvolumeType = "disperse"
+	SubvolumeStripe     SubvolumeType = "stripe"
+)
+
+var transports = map[int]string{0: "tcp", 1: "rdma", 2: "tcp,rdma"}
+
+type Volume struct {
+	Name            string       `json:"name"`
+	ID              string       `json:"id"`
+	Type            VolumeType   `json:"type"`
+	Status          VolumeStatus `json:"status"`
+	Transport       string       `json:"transport"`
+	SnapshotCount   int          `json:"snapshot_count"`
+	BrickCount      int          `json:"brick_count"`
+	SubvolumeSize   int          `json:"subvolume_size"` // bricks per subvolume, distCount of the CLI
+	ReplicaCount    int          `json:"replica_count"`
+	ArbiterCount    int          `json:"arbiter_count"`
+	DisperseCount   int          `json:"disperse_count"`
+	RedundancyCount int          `json:"redundancy_count"`
+	Bricks          []Brick      `json:"bricks"`
+	Subvolumes      []Subvolume  `json:"subvolumes"`
+	Options         []Option     `json:"options"`
+}
+
+type Brick struct {
+	Name      string `json:"name"` // host:/path
+	Host      string `json:"host"`
+	Path      string `json:"path"`
+	UUID      string `json:"uuid,omitempty"`
+	HostUUID  string `json:"host_uuid,omitempty"`
+	IsArbiter bool   `json:"is_arbiter"`
+}
+
+// Subvolume is a replica or disperse set, or a single brick of a pure
+// distribute volume. DHT distributes files over the subvolumes.
+type Subvolume struct {
+	Name   string           `json:"name"` // <volname>-replicate-<n> etc, as in the client volfile
+	Type   SubvolumeType    `json:"type"`
+	Bricks []Brick          `json:"bricks"`
+	Health *SubvolumeHealth `json:"health,omitempty"` // set by ApplyStatus
+}
+
+type Option struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// SplitBrick splits "host:/path" at the last ':' before the path.
+func SplitBrick(name string) (host, path string) {
+	i := strings.LastIndex(name, ":/")
+	if i < 0 {
+		return "", name
+	}
+	return name[:i], name[i+1:]
+}
+
+// ParseVolumeInfo parses `gluster volume info [<vol>|all] --xml`.
+func ParseVolumeInfo(data []byte) ([]Volume, error) {
+	var info volInfoXML
+	if e := xml.Unmarshal(data, &info); e != nil {
+		return nil, fmt.Errorf("parse volume info: %s", e)
+	}
+	if info.OpRet != 0 {
+		return nil, fmt.Errorf("volume info failed: %s", info.OpErrstr)
+	}
+
+	volumes := make([]Volume, 0, len(info.Volumes))
+	for _, v := range info.Volumes {
+		volumes = append(volumes, newVolume(v))
+	}
+	return volumes, nil
+}
+
+func newVolume(v volumeXML) Volume {
+	volume := Volume{
+		Name:            v.Name,
+		ID:              v.ID,
+		Type:            VolumeType(v.TypeStr),
+		Status:          volumeStatuses[v.Status],
+		Transport:       transports[v.Transport],
+		SnapshotCount:   v.SnapshotCount,
+		BrickCount:      v.BrickCount,
+		SubvolumeSize:   v.DistCount,
+		ReplicaCount:    v.ReplicaCount,
+		ArbiterCount:    v.ArbiterCount,
+		DisperseCount:   v.DisperseCount,
+		RedundancyCount: v.RedundancyCount,
+		Bricks:          []Brick{},
+		Options:         []Option{},
+	}
+	if volume.Status == "" {
+		volume.Status = VolumeUnknown
+	}
+	for _, b := range v.Bricks {
+		host, path := SplitBrick(b.Name)
+		volume.Bricks = append(volume.Bricks, Brick{
+			Name:      b.Name,
+			Host:      host,
+			Path:      path,
+			UUID:      b.UUID,
+			HostUUID:  b.HostUUID,
+			IsArbiter: b.IsArbiter == 1,
+		})
+	}
+	for _, o := range v.Options {
+		volume.Options = append(volume.Options, Option{Name: o.Name, Value: o.Value})
+	}
+	volume.Subvolumes = GroupSubvolumes(volume)
+	return volume
+}
+
+// GroupSubvolumes splits the bricks of v into subvolumes in gluster's order:
+// consecutive runs of SubvolumeSize bricks form one set.
+func GroupSubvolumes(v Volume) []Subvolume {
+	size, typ := v.subvolumeLayout()
+	prefix := string(typ)
+	if typ == SubvolumeDistribute {
+		prefix = "client"
+	}
+	subvolumes := []Subvolume{}
+	for start, n := 0, 0; start+size <= len(v.Bricks); start, n = start+size, n+1 {
+		subvolumes = append(subvolumes, Subvolume{
+			Name:   fmt.Sprintf("%s-%s-%d", v.Name, prefix, n),
+			Type:   typ,
+			Bricks: v.Bricks[start : start+size],
+		})
+	}
+	return subvolumes
+}
+
+// subvolumeLayout returns the bricks per subvolume, distCount when the CLI
+// reports it, and the translator joining them.
+func (v Volume) subvolumeLayout() (int, SubvolumeType) {
+	typ := SubvolumeDistribute
+	size := 1
+	switch {
+	case v.DisperseCount > 0:
+		typ, size = SubvolumeDisperse, v.DisperseCount
+	case v.ReplicaCount > 1:
+		typ, size = SubvolumeReplicate, v.ReplicaCount
+	case v.SubvolumeSize > 1:
+		typ = SubvolumeStripe
+	}
+	if v.SubvolumeSize > 0 {
+		size = v.SubvolumeSize
+	}
+	return size, typ
+}
+
+// DataBricks returns the bricks of s that hold file data, arbiters excluded.
+func (s Subvolume) DataBricks() []Brick {
+	var bricks []Brick
+	for _, b := range s.Bricks {
+		if !b.IsArbiter {
+			bricks = append(bricks, b)
+		}
+	}
+	return bricks
+}
+
+// Healable reports whether the self-heal daemon serves v, i.e. its bricks are
+// joined by replicate or disperse.
+func (v Volume) Healable() bool {
+	_, typ := v.subvolumeLayout()
+	return typ == SubvolumeReplicate || typ == SubvolumeDisperse
+}
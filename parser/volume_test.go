@@ -0,0 +1,117 @@
+package parser
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+)
+
+// fixture returns testdata/name, captured from the gluster CLI.
+func fixture(t *testing.T, name string) []byte {
+	t.Helper()
+	data, e := ioutil.ReadFile(filepath.Join("testdata", name))
+	if e != nil {
+		t.Fatal(e)
+	}
+	return data
+}
+
+func TestParseVolumeInfo(t *testing.T) {
+	volumes, e := ParseVolumeInfo(fixture(t, "volume_info.xml"))
+	if e != nil {
+		t.Fatal(e)
+	}
+	if len(volumes) != 1 {
+		t.Fatalf("got %d volumes, want 1", len(volumes))
+	}
+	v := volumes[0]
+	if v.Name != "test" || v.Type != TypeDistributedReplicate || v.Status != VolumeStarted || v.Transport != "tcp" {
+		t.Errorf("got %s %s %s %s", v.Name, v.Type, v.Status, v.Transport)
+	}
+	if v.BrickCount != 6 || v.ReplicaCount != 3 || v.ArbiterCount != 1 || v.SubvolumeSize != 3 {
+		t.Errorf("got bricks %d replica %d arbiter %d subvolume size %d", v.BrickCount, v.ReplicaCount, v.ArbiterCount, v.SubvolumeSize)
+	}
+	b := v.Bricks[2]
+	if b.Name != "node3:/data/brick1/test" || b.Host != "node3" || b.Path != "/data/brick1/test" || !b.IsArbiter {
+		t.Errorf("brick 2 = %+v", b)
+	}
+}
+
+func TestParseVolumeInfoFailed(t *testing.T) {
+	data := []byte(`<cliOutput><opRet>-1</opRet><opErrno>30806</opErrno><opErrstr>Volume nope does not exist</opErrstr></cliOutput>`)
+	if _, e := ParseVolumeInfo(data); e == nil {
+		t.Error("no error for opRet -1")
+	}
+	if _, e := ParseVolumeInfo([]byte("Connection failed. Please check if gluster daemon is operational.")); e == nil {
+		t.Error("no error for plain output")
+	}
+}
+
+func TestVolumeInfoSubvolumes(t *testing.T) {
+	volumes, e := ParseVolumeInfo(fixture(t, "volume_info.xml"))
+	if e != nil {
+		t.Fatal(e)
+	}
+	v := volumes[0]
+	if v.Option("cluster.quorum-type") != "auto" {
+		t.Errorf("cluster.quorum-type = %q", v.Option("cluster.quorum-type"))
+	}
+	if len(v.Subvolumes) != 2 {
+		t.Fatalf("got %d subvolumes, want 2", len(v.Subvolumes))
+	}
+	for i, name := range []string{"test-replicate-0", "test-replicate-1"} {
+		s := v.Subvolumes[i]
+		if s.Name != name || s.Type != SubvolumeReplicate || len(s.Bricks) != 3 || len(s.DataBricks()) != 2 {
+			t.Errorf("subvolume %d = %s %s %d bricks %d data bricks", i, s.Name, s.Type, len(s.Bricks), len(s.DataBricks()))
+		}
+	}
+}
+
+func TestGroupSubvolumes(t *testing.T) {
+	bricks := make([]Brick, 6)
+	for i := range bricks {
+		bricks[i].Name = string(rune('a' + i))
+	}
+	tests := []struct {
+		name   string
+		volume Volume
+		want   []string
+		typ    SubvolumeType
+	}{
+		{"distribute", Volume{Name: "v", ReplicaCount: 1}, []string{"v-client-0", "v-client-1", "v-client-2", "v-client-3", "v-client-4", "v-client-5"}, SubvolumeDistribute},
+		{"replicate", Volume{Name: "v", ReplicaCount: 2}, []string{"v-replicate-0", "v-replicate-1", "v-replicate-2"}, SubvolumeReplicate},
+		{"disperse", Volume{Name: "v", DisperseCount: 3, RedundancyCount: 1}, []string{"v-disperse-0", "v-disperse-1"}, SubvolumeDisperse},
+		{"distCount wins", Volume{Name: "v", ReplicaCount: 2, SubvolumeSize: 3}, []string{"v-replicate-0", "v-replicate-1"}, SubvolumeReplicate},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			test.volume.Bricks = bricks
+			subvolumes := GroupSubvolumes(test.volume)
+			if len(subvolumes) != len(test.want) {
+				t.Fatalf("got %d subvolumes, want %d", len(subvolumes), len(test.want))
+			}
+			for i, s := range subvolumes {
+				if s.Name != test.want[i] || s.Type != test.typ {
+					t.Errorf("subvolume %d = %s %s, want %s %s", i, s.Name, s.Type, test.want[i], test.typ)
+				}
+			}
+		})
+	}
+}
+
+func TestSplitBrick(t *testing.T) {
+	tests := []struct {
+		name, host, path string
+	}{
+		{"node1:/data/test", "node1", "/data/test"},
+		{"10.0.0.1:/data/test", "10.0.0.1", "/data/test"},
+		{"[fe80::1]:/data/test", "[fe80::1]", "/data/test"},
+		{"/data/test", "", "/data/test"},
+	}
+	for _, test := range tests {
+		host, path := SplitBrick(test.name)
+		if host != test.host || path != test.path {
+			t.Errorf("SplitBrick(%q) = %q, %q, want %q, %q", test.name, host, path, test.host, test.path)
+		}
+	}
+}
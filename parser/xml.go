@@ -0,0 +1,71 @@
+package parser
+
+import "encoding/xml"
+
+// raw --xml documents of the gluster CLI, see cli-xml-output.c
+
+type volInfoXML struct {
+	XMLName  xml.Name    `xml:"cliOutput"`
+	OpRet    int         `xml:"opRet"`
+	OpErrno  int         `xml:"opErrno"`
+	OpErrstr string      `xml:"opErrstr"`
+	Volumes  []volumeXML `xml:"volInfo>volumes>volume"`
+}
+
+type volumeXML struct {
+	Name            string      `xml:"name"`
+	ID              string      `xml:"id"`
+	Status          int         `xml:"status"`
+	StatusStr       string      `xml:"statusStr"`
+	SnapshotCount   int         `xml:"snapshotCount"`
+	BrickCount      int         `xml:"brickCount"`
+	DistCount       int         `xml:"distCount"`
+	StripeCount     int         `xml:"stripeCount"`
+	ReplicaCount    int         `xml:"replicaCount"`
+	ArbiterCount    int         `xml:"arbiterCount"`
+	DisperseCount   int         `xml:"disperseCount"`
+	RedundancyCount int         `xml:"redundancyCount"`
+	Type            int         `xml:"type"`
+	TypeStr         string      `xml:"typeStr"`
+	Transport       int         `xml:"transport"`
+	Bricks          []brickXML  `xml:"bricks>brick"`
+	Options         []optionXML `xml:"options>option"`
+}
+
+type brickXML struct {
+	UUID      string `xml:"uuid,attr"`
+	Name      string `xml:"name"`
+	HostUUID  string `xml:"hostUuid"`
+	IsArbiter int    `xml:"isArbiter"`
+}
+
+type optionXML struct {
+	Name  string `xml:"name"`
+	Value string `xml:"value"`
+}
+
+type volStatusXML struct {
+	XMLName  xml.Name          `xml:"cliOutput"`
+	OpRet    int               `xml:"opRet"`
+	OpErrno  int               `xml:"opErrno"`
+	OpErrstr string            `xml:"opErrstr"`
+	Volumes  []volumeStatusXML `xml:"volStatus>volumes>volume"`
+}
+
+type volumeStatusXML struct {
+	VolName string          `xml:"volName"`
+	Nodes   []nodeStatusXML `xml:"node"`
+}
+
+type nodeStatusXML struct {
+	Hostname  string `xml:"hostname"`
+	Path      string `xml:"path"`
+	PeerID    string `xml:"peerid"`
+	Status    int    `xml:"status"`
+	Port      string `xml:"port"`
+	Pid       int    `xml:"pid"`
+	SizeTotal uint64 `xml:"sizeTotal"`
+	SizeFree  uint64 `xml:"sizeFree"`
+	Device    string `xml:"device"`
+	FsName    string `xml:"fsName"`
+}
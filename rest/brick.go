@@ -1,9 +1,12 @@
 package gluster
 
 import (
+	"context"
 	"encoding/xml"
 	"fmt"
-	"os/exec"
+	"net/http"
+	"strconv"
+	"strings"
 
 	L "hualu.com/logger"
 )
@@ -43,65 +46,91 @@ type Aggregate struct {
 	Runtime   string `xml:"runtime" json:"runtime"`
 }
 
-func RemoveBrick(volumeRemoveBrickReq VolumeRemoveBrickRequest) (rsp CommonVolumeResponse) {
+// removeBrickArgs builds "volume remove-brick <vol> [replica <n>] <bricks...> <op...>".
+func removeBrickArgs(volumeRemoveBrickReq VolumeRemoveBrickRequest, op ...string) []string {
+	args := []string{"volume", "remove-brick", volumeRemoveBrickReq.Volname}
+	if volumeRemoveBrickReq.replica > 0 {
+		args = append(args, "replica", strconv.Itoa(volumeRemoveBrickReq.replica))
+	}
+	args = append(args, volumeRemoveBrickReq.Bricks...)
+	return append(args, op...)
+}
+
+// forceRemovable checks removing the bricks with force loses no data: every
+// replica set keeps a data brick and loses as many bricks as the others. It
+// returns the replica count left.
+func forceRemovable(ctx context.Context, volumeRemoveBrickReq VolumeRemoveBrickRequest) (int, error) {
+	volumes, e := volumesInfo(ctx, volumeRemoveBrickReq.Volname)
+	if e != nil {
+		return 0, e
+	}
+	if len(volumes) == 0 {
+		return 0, NewError(http.StatusNotFound, CodeVolumeNotFound, "volume %s does not exist", volumeRemoveBrickReq.Volname)
+	}
+	volume := volumes[0]
+	removing := make(map[string]bool)
+	for i, brick := range volumeRemoveBrickReq.Bricks {
+		if !volumeHasBrick(volume, brick) {
+			return 0, invalid(fmt.Sprintf("bricks[%d]", i), brick, "is not a brick of volume "+volume.Name)
+		}
+		removing[brick] = true
+	}
+	if volume.ReplicaCount < 2 || volume.DisperseCount > 0 {
+		return 0, NewError(http.StatusConflict, CodeNotSupported,
+			"removing bricks of %s volume %s with force loses their data, use /gluster/volume/shrink", volume.Type, volume.Name)
+	}
+
+	per := -1
+	for _, subvolume := range volume.Subvolumes {
+		n, data := 0, 0
+		for _, brick := range subvolume.Bricks {
+			if removing[brick.Name] {
+				n++
+			} else if !brick.IsArbiter {
+				data++
+			}
+		}
+		if data == 0 {
+			return 0, NewError(http.StatusConflict, CodeNotSupported,
+				"removing every data brick of %s with force loses its data, use /gluster/volume/shrink", subvolume.Name)
+		}
+		if per >= 0 && n != per {
+			return 0, invalid("bricks", strings.Join(volumeRemoveBrickReq.Bricks, ","), "must take as many bricks from every replica set")
+		}
+		per = n
+	}
+	return volume.ReplicaCount - per, nil
+}
+
+func RemoveBrick(ctx context.Context, volumeRemoveBrickReq VolumeRemoveBrickRequest) (rsp CommonVolumeResponse) {
 	// run command in docker
 	L.Gluster.Debug(volumeRemoveBrickReq.Options)
-	var bricks string
-	//for i := range volumeRemoveBrickReq.Bricks {
-	//	L.Gluster.Debug(bricks)
-	//	bricks = bricks + volumeRemoveBrickReq.Bricks[i].Name + " "
-	//}
-	//L.Gluster.Debugf("bricks is %s", bricks)
-	for _, brick := range volumeRemoveBrickReq.Bricks {
-		bricks = brick + " " + bricks
-	}
 
 	//cmdString := fmt.Sprintf("docker exec glusterfs sh -c \"gluster volume remove-brick %s %s %s <<< y\"", volumeRemoveBrickReq.Volname, bricks, volumeRemoveBrickReq.Options)
-	cmdString := fmt.Sprintf("gluster volume remove-brick %s %s %s force<<< y", volumeRemoveBrickReq.Volname, bricks, volumeRemoveBrickReq.Options)
-	L.Gluster.Info(cmdString)
-
-	//run command in docker
-	cmd := exec.Command("sh", "-c", cmdString)
-	output, e := cmd.CombinedOutput()
+	output, e := runGlusterConfirm(ctx, removeBrickArgs(volumeRemoveBrickReq, "force")...)
 	if e != nil {
 		L.Gluster.Error(e.Error())
 		L.Gluster.Error(string(output))
-		rsp.Result = "ERROR"
-		rsp.Errors = string(output)
+		rsp.Fail(glusterError(output, e))
 		return
 	}
 
 	rsp.Result = "OK"
+	rsp.Errors = string(output)
 
 	// make response
 	return rsp
 }
 
-func RemoveBrickStatus(volumeRemoveBrickReq VolumeRemoveBrickRequest) (rsp RemoveBrickStatusResponse) {
+func RemoveBrickStatus(ctx context.Context, volumeRemoveBrickReq VolumeRemoveBrickRequest) (rsp RemoveBrickStatusResponse) {
 	L.Gluster.Debug("rsp is RemoveBrickStatusResponse")
 
-	var bricks string
-	//for i := range volumeRemoveBrickReq.Bricks {
-	//	L.Gluster.Debug(bricks)
-	//	bricks = bricks + volumeRemoveBrickReq.Bricks[i].Name + " "
-	//}
-	//L.Gluster.Debugf("bricks is %s", bricks)
-	for _, brick := range volumeRemoveBrickReq.Bricks {
-		bricks = brick + " " + bricks
-	}
-
 	//cmdString := fmt.Sprintf("docker exec glusterfs gluster volume remove-brick %s %s %s --xml", volumeRemoveBrickReq.Volname, bricks, volumeRemoveBrickReq.Options)
-	cmdString := fmt.Sprintf("gluster volume remove-brick %s %s %s --xml", volumeRemoveBrickReq.Volname, bricks, volumeRemoveBrickReq.Options)
-	L.Gluster.Info(cmdString)
-
-	//run command in docker
-	cmd := exec.Command("sh", "-c", cmdString)
-	output, e := cmd.CombinedOutput()
+	output, e := runGluster(ctx, removeBrickArgs(volumeRemoveBrickReq, "status", "--xml")...)
 	if e != nil {
 		L.Gluster.Error(e.Error())
 		L.Gluster.Error(string(output))
-		rsp.Result = "ERROR"
-		rsp.Errors = string(output)
+		rsp.Fail(glusterError(output, e))
 		return
 	}
 	L.Gluster.Debug(string(output))
@@ -110,8 +139,7 @@ func RemoveBrickStatus(volumeRemoveBrickReq VolumeRemoveBrickRequest) (rsp Remov
 	e = xml.Unmarshal(output, &removeBrickStatusXML)
 	if e != nil {
 		L.Gluster.Error(e.Error())
-		rsp.Result = "ERROR"
-		rsp.Errors = e.Error()
+		rsp.Fail(e)
 		return
 	}
 
@@ -123,3 +151,236 @@ func RemoveBrickStatus(volumeRemoveBrickReq VolumeRemoveBrickRequest) (rsp Remov
 	// make response
 	return rsp
 }
+
+func removeBrickJob(volumeRemoveBrickReq VolumeRemoveBrickRequest) JobFunc {
+	return func(ctx context.Context, update func(JobProgress)) (string, error) {
+		rsp := RemoveBrick(ctx, volumeRemoveBrickReq)
+		if rsp.Error != nil {
+			return rsp.Error.Output, rsp.Error
+		}
+		return rsp.Errors, nil
+	}
+}
+
+/*
+[example]
+docker exec glusterfs gluster volume remove-brick test 10.2.174.237:/data/test start --xml
+
+curl -X POST http://127.0.0.1:7030/gluster/volume/brick/remove/start -H 'Content-Type: application/json' -d '{
+"volname": "test",
+"bricks": ["10.2.174.237:/data/test"]
+}'
+<- {"result":"OK","job_id":"..."}
+*/
+func ProcessVolumeRemoveBrickStart(w http.ResponseWriter, r *http.Request) {
+	var rsp CommonVolumeResponse
+	defer writeResponse(w, &rsp)
+
+	// analyze request
+	var volRemoveBrickReq VolumeRemoveBrickRequest
+	if e := readRequest(r, &volRemoveBrickReq); e != nil {
+		L.Gluster.Error(e.Error())
+		rsp.Fail(e)
+		return
+	}
+
+	// status and stop must get through while the migration job holds the lock
+	release, e := lockForRequest(r, volumeLock(volRemoveBrickReq.Volname), "remove-brick start")
+	if e != nil {
+		L.Gluster.Error(e.Error())
+		rsp.Fail(e)
+		return
+	}
+
+	job := jobs.Start("remove-brick start", volRemoveBrickReq.Volname, locked(release, removeBrickStartJob(volRemoveBrickReq)))
+	rsp.JobID = job.ID
+	rsp.Result = "OK"
+}
+
+/*
+[example]
+docker exec glusterfs gluster volume remove-brick test 10.2.174.237:/data/test status --xml
+
+curl -X POST http://127.0.0.1:7030/gluster/volume/brick/remove/status -H 'Content-Type: application/json' -d '{
+"volname": "test",
+"bricks": ["10.2.174.237:/data/test"]
+}'
+<- {"result":"OK","volremovebrick":{"taskid":"...","nodes":[...],"aggregate":{...,"statusstr":"in progress"}}}
+*/
+func ProcessVolumeRemoveBrickStatus(w http.ResponseWriter, r *http.Request) {
+	var rsp RemoveBrickStatusResponse
+	defer writeResponse(w, &rsp)
+
+	// analyze request
+	var volRemoveBrickReq VolumeRemoveBrickRequest
+	if e := readRequest(r, &volRemoveBrickReq); e != nil {
+		L.Gluster.Error(e.Error())
+		rsp.Fail(e)
+		return
+	}
+
+	rsp = RemoveBrickStatus(r.Context(), volRemoveBrickReq)
+}
+
+/*
+[example]
+docker exec glusterfs sh -c "gluster volume remove-brick test 10.2.174.237:/data/test commit <<< y"
+
+curl -X POST http://127.0.0.1:7030/gluster/volume/brick/remove/commit -H 'Content-Type: application/json' -d '{
+"volname": "test",
+"bricks": ["10.2.174.237:/data/test"]
+}'
+<- {"result":"OK"}
+<- {"result":"ERROR","error":{"status":409,"code":"migration_in_progress",...}}
+*/
+func ProcessVolumeRemoveBrickCommit(w http.ResponseWriter, r *http.Request) {
+	var rsp CommonVolumeResponse
+	defer writeResponse(w, &rsp)
+
+	// analyze request
+	var volRemoveBrickReq VolumeRemoveBrickRequest
+	if e := readRequest(r, &volRemoveBrickReq); e != nil {
+		L.Gluster.Error(e.Error())
+		rsp.Fail(e)
+		return
+	}
+
+	release, e := lockForRequest(r, volumeLock(volRemoveBrickReq.Volname), "remove-brick commit")
+	if e != nil {
+		L.Gluster.Error(e.Error())
+		rsp.Fail(e)
+		return
+	}
+	defer release()
+
+	status := RemoveBrickStatus(r.Context(), volRemoveBrickReq)
+	if status.Error != nil {
+		rsp.Fail(status.Error)
+		return
+	}
+	if e := removeBrickCommittable(status.VolRemoveBrick); e != nil {
+		L.Gluster.Error(e.Error())
+		rsp.Fail(e)
+		return
+	}
+
+	output, e := runGlusterConfirm(r.Context(), removeBrickArgs(volRemoveBrickReq, "commit")...)
+	if e != nil {
+		L.Gluster.Error(string(output))
+		rsp.Fail(glusterError(output, e))
+		return
+	}
+
+	rsp.Result = "OK"
+	rsp.Errors = string(output)
+}
+
+/*
+[example]
+docker exec glusterfs gluster volume remove-brick test 10.2.174.237:/data/test stop
+
+curl -X POST http://127.0.0.1:7030/gluster/volume/brick/remove/stop -H 'Content-Type: application/json' -d '{
+"volname": "test",
+"bricks": ["10.2.174.237:/data/test"]
+}'
+<- {"result":"OK"}
+*/
+func ProcessVolumeRemoveBrickStop(w http.ResponseWriter, r *http.Request) {
+	var rsp CommonVolumeResponse
+	defer writeResponse(w, &rsp)
+
+	// analyze request
+	var volRemoveBrickReq VolumeRemoveBrickRequest
+	if e := readRequest(r, &volRemoveBrickReq); e != nil {
+		L.Gluster.Error(e.Error())
+		rsp.Fail(e)
+		return
+	}
+
+	output, e := runGluster(r.Context(), removeBrickArgs(volRemoveBrickReq, "stop")...)
+	if e != nil {
+		L.Gluster.Error(string(output))
+		rsp.Fail(glusterError(output, e))
+		return
+	}
+
+	rsp.Result = "OK"
+	rsp.Errors = string(output)
+}
+
+// removeBrickStartJob starts migrating data off the bricks and follows it until
+// every node is final. Cancelling the job stops the migration.
+func removeBrickStartJob(volumeRemoveBrickReq VolumeRemoveBrickRequest) JobFunc {
+	return func(ctx context.Context, update func(JobProgress)) (string, error) {
+		output, e := runGluster(ctx, removeBrickArgs(volumeRemoveBrickReq, "start", "--xml")...)
+		if e != nil {
+			return string(output), glusterError(output, e)
+		}
+		return followRemoveBrick(ctx, volumeRemoveBrickReq, update)
+	}
+}
+
+// followRemoveBrick polls a started remove-brick until every node is final.
+// Cancelling ctx stops the migration.
+func followRemoveBrick(ctx context.Context, volumeRemoveBrickReq VolumeRemoveBrickRequest, update func(JobProgress)) (string, error) {
+	for {
+		if e := sleepContext(ctx, pollInterval); e != nil {
+			runGluster(context.Background(), removeBrickArgs(volumeRemoveBrickReq, "stop")...)
+			return "", e
+		}
+		status := RemoveBrickStatus(ctx, volumeRemoveBrickReq)
+		if status.Error != nil {
+			return status.Error.Output, status.Error
+		}
+		p := removeBrickProgress(status.VolRemoveBrick)
+		update(p)
+		if p.NodesDone == p.NodesTotal && taskFinished(p.Status) {
+			if p.Status != "completed" {
+				return "", fmt.Errorf("remove-brick %s", p.Status)
+			}
+			return "", nil
+		}
+	}
+}
+
+func removeBrickProgress(volRemoveBrick VolRemoveBrick) JobProgress {
+	aggregate := volRemoveBrick.Aggregate
+	p := JobProgress{
+		Status:     aggregate.StatusStr,
+		Files:      atoi(aggregate.Files),
+		Size:       atoi(aggregate.Size),
+		Failures:   atoi(aggregate.Failures),
+		Skipped:    atoi(aggregate.Skipped),
+		Runtime:    aggregate.Runtime,
+		NodesTotal: len(volRemoveBrick.Nodes),
+	}
+	for _, node := range volRemoveBrick.Nodes {
+		if taskFinished(node.StatusStr) {
+			p.NodesDone++
+		}
+	}
+	return p
+}
+
+// removeBrickCommittable refuses a commit unless migration completed on every
+// node, committing earlier loses the files not yet moved off the bricks.
+func removeBrickCommittable(volRemoveBrick VolRemoveBrick) *Error {
+	if len(volRemoveBrick.Nodes) == 0 {
+		return NewError(http.StatusConflict, CodeMigrationIncomplete, "remove-brick was not started on these bricks")
+	}
+	for _, node := range volRemoveBrick.Nodes {
+		switch node.StatusStr {
+		case "completed":
+		case "in progress":
+			return NewError(http.StatusConflict, CodeMigrationRunning, "remove-brick is still in progress on %s", node.NodeName)
+		default:
+			return NewError(http.StatusConflict, CodeMigrationIncomplete, "remove-brick is %s on %s, start it again before commit", node.StatusStr, node.NodeName)
+		}
+	}
+	return nil
+}
+
+func atoi(s string) int {
+	n, _ := strconv.Atoi(s)
+	return n
+}
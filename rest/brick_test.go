@@ -0,0 +1,145 @@
+package gluster
+
+import (
+	"net/http"
+	"testing"
+)
+
+const removeBrickCompletedXML = `<cliOutput><opRet>0</opRet><volRemoveBrick><task-id>7b5f</task-id>` +
+	`<node><nodeName>node1</nodeName><files>10</files><size>1024</size><failures>0</failures><skipped>0</skipped><status>3</status><statusStr>completed</statusStr></node>` +
+	`<aggregate><files>10</files><size>1024</size><failures>0</failures><skipped>0</skipped><status>3</status><statusStr>completed</statusStr></aggregate>` +
+	`</volRemoveBrick></cliOutput>`
+
+var replica3Bricks = []string{
+	"node1:/data/brick1/test", "node2:/data/brick1/test", "node3:/data/brick1/test",
+	"node1:/data/brick2/test", "node2:/data/brick2/test", "node3:/data/brick2/test",
+}
+
+// TestVolumeRemoveBrick checks the forced removal only takes bricks whose
+// data stays on their set, anything else is left to shrink.
+func TestVolumeRemoveBrick(t *testing.T) {
+	tests := []struct {
+		name   string
+		body   string
+		info   string
+		status int
+		want   []string
+	}{
+		{
+			name:   "reduce replica",
+			body:   `{"volname": "test", "bricks": ["node3:/data/brick1/test", "node3:/data/brick2/test"], "options": "force"}`,
+			info:   volumeInfoXML("test", "3", replica3Bricks...),
+			status: http.StatusOK,
+			want: []string{
+				"gluster volume info test --xml",
+				"gluster volume remove-brick test replica 2 node3:/data/brick1/test node3:/data/brick2/test force",
+			},
+		},
+		{
+			name:   "whole replica set",
+			body:   `{"volname": "test", "bricks": ["node1:/data/brick2/test", "node2:/data/brick2/test", "node3:/data/brick2/test"]}`,
+			info:   volumeInfoXML("test", "3", replica3Bricks...),
+			status: http.StatusConflict,
+			want:   []string{"gluster volume info test --xml"},
+		},
+		{
+			name:   "uneven sets",
+			body:   `{"volname": "test", "bricks": ["node3:/data/brick1/test"]}`,
+			info:   volumeInfoXML("test", "3", replica3Bricks...),
+			status: http.StatusBadRequest,
+			want:   []string{"gluster volume info test --xml"},
+		},
+		{
+			name:   "distribute",
+			body:   `{"volname": "test", "bricks": ["node2:/data/test"]}`,
+			info:   volumeInfoXML("test", "1", "node1:/data/test", "node2:/data/test"),
+			status: http.StatusConflict,
+			want:   []string{"gluster volume info test --xml"},
+		},
+		{
+			name:   "not a brick of the volume",
+			body:   `{"volname": "test", "bricks": ["node4:/data/brick1/test"]}`,
+			info:   volumeInfoXML("test", "3", replica3Bricks...),
+			status: http.StatusBadRequest,
+			want:   []string{"gluster volume info test --xml"},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			f := fakeGluster(t).On(test.info, nil, "gluster", "volume", "info")
+			var rsp CommonVolumeResponse
+			if status := serve(t, ProcessVolumeRemoveBrick, test.body, &rsp); status != test.status {
+				t.Fatalf("status %d, want %d: %+v", status, test.status, rsp.Error)
+			}
+			if rsp.JobID != "" {
+				if job := waitJob(t, rsp.JobID); job.State != JobSucceeded {
+					t.Errorf("job %s: %+v", job.State, job.Error)
+				}
+			}
+			checkCalls(t, f, test.want)
+		})
+	}
+}
+
+func TestVolumeRemoveBrickWorkflow(t *testing.T) {
+	body := `{"volname": "test", "bricks": ["node1:/data/brick2/test", "node2:/data/brick2/test"]}`
+	tests := []struct {
+		name    string
+		handler http.HandlerFunc
+		want    []string
+	}{
+		{"status", ProcessVolumeRemoveBrickStatus, []string{
+			"gluster volume remove-brick test node1:/data/brick2/test node2:/data/brick2/test status --xml",
+		}},
+		{"commit", ProcessVolumeRemoveBrickCommit, []string{
+			"gluster volume remove-brick test node1:/data/brick2/test node2:/data/brick2/test status --xml",
+			"gluster volume remove-brick test node1:/data/brick2/test node2:/data/brick2/test commit",
+		}},
+		{"stop", ProcessVolumeRemoveBrickStop, []string{
+			"gluster volume remove-brick test node1:/data/brick2/test node2:/data/brick2/test stop",
+		}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			f := fakeGluster(t).On(removeBrickCompletedXML, nil, "gluster", "volume", "remove-brick")
+			var rsp CommonResponse
+			if status := serve(t, test.handler, body, &rsp); status != http.StatusOK {
+				t.Fatalf("status %d: %+v", status, rsp.Error)
+			}
+			checkCalls(t, f, test.want)
+		})
+	}
+}
+
+func TestVolumeRemoveBrickStartJob(t *testing.T) {
+	fastPoll(t)
+	f := fakeGluster(t).On(removeBrickCompletedXML, nil, "gluster", "volume", "remove-brick")
+	req := VolumeRemoveBrickRequest{Bricks: []string{"node1:/data/brick2/test", "node2:/data/brick2/test"}}
+	req.Volname = "test"
+	job := waitJob(t, jobs.Start("remove-brick", "test", removeBrickStartJob(req)).ID)
+	if job.State != JobSucceeded || job.Progress == nil || job.Progress.Files != 10 {
+		t.Errorf("job = %+v", job)
+	}
+	checkCalls(t, f, []string{
+		"gluster volume remove-brick test node1:/data/brick2/test node2:/data/brick2/test start --xml",
+		"gluster volume remove-brick test node1:/data/brick2/test node2:/data/brick2/test status --xml",
+	})
+}
+
+// TestVolumeRemoveBrickCommitRunning checks a commit is refused while data is
+// still migrating.
+func TestVolumeRemoveBrickCommitRunning(t *testing.T) {
+	running := `<cliOutput><opRet>0</opRet><volRemoveBrick><task-id>7b5f</task-id>` +
+		`<node><nodeName>node1</nodeName><status>1</status><statusStr>in progress</statusStr></node>` +
+		`<aggregate><status>1</status><statusStr>in progress</statusStr></aggregate>` +
+		`</volRemoveBrick></cliOutput>`
+	f := fakeGluster(t).On(running, nil, "gluster", "volume", "remove-brick")
+	var rsp CommonResponse
+	if status := serve(t, ProcessVolumeRemoveBrickCommit, `{"volname": "test", "bricks": ["node1:/data/brick2/test"]}`, &rsp); status != http.StatusConflict {
+		t.Errorf("status %d, want 409", status)
+	}
+	if rsp.Error == nil || rsp.Error.Code != CodeMigrationRunning {
+		t.Errorf("got %+v", rsp.Error)
+	}
+	checkCalls(t, f, []string{"gluster volume remove-brick test node1:/data/brick2/test status --xml"})
+}
@@ -0,0 +1,270 @@
+package gluster
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	L "hualu.com/logger"
+)
+
+// CatalogOption is a settable volume option of `volume set help-xml`.
+type CatalogOption struct {
+	Name        string `json:"name"`
+	Type        string `json:"type,omitempty"` // see optionTypes, empty when glusterd alone checks the value
+	Default     string `json:"default"`
+	Description string `json:"description"`
+}
+
+// OptionCatalog holds the options of one gluster version.
+type OptionCatalog struct {
+	Version string
+	Options map[string]CatalogOption
+}
+
+// OptionCatalogCache loads the catalog once per gluster version, an upgrade
+// of the local glusterfs reloads it.
+type OptionCatalogCache struct {
+	mu        sync.Mutex
+	byVersion map[string]*OptionCatalog
+}
+
+func NewOptionCatalogCache() *OptionCatalogCache {
+	return &OptionCatalogCache{byVersion: make(map[string]*OptionCatalog)}
+}
+
+var catalogs = NewOptionCatalogCache()
+
+var (
+	glusterVersionRe = regexp.MustCompile(`^glusterfs\s+(\S+)`)
+	intValue         = regexp.MustCompile(`^-?[0-9]+$`)
+	percentValue     = regexp.MustCompile(`^[0-9]+(\.[0-9]+)?%?$`)
+	sizeValue        = regexp.MustCompile(`(?i)^[0-9]+(\.[0-9]+)?\s*([KMGTP]B?|B)?$`)
+	boolValues       = map[string]bool{
+		"on": true, "off": true, "enable": true, "disable": true,
+		"true": true, "false": true, "yes": true, "no": true, "1": true, "0": true,
+	}
+)
+
+// optionTypes are the options whose values are checked before gluster is
+// called. A default does not tell the type, cache-max-file-size defaults to 0
+// but takes 64MB, so the other options are only checked to exist and glusterd
+// judges their values.
+var optionTypes = map[string]string{
+	"cluster.eager-lock":                   "bool",
+	"cluster.granular-entry-heal":          "bool",
+	"cluster.lookup-optimize":              "bool",
+	"cluster.readdir-optimize":             "bool",
+	"features.read-only":                   "bool",
+	"features.shard":                       "bool",
+	"network.remote-dio":                   "bool",
+	"nfs.disable":                          "bool",
+	"performance.client-io-threads":        "bool",
+	"performance.io-cache":                 "bool",
+	"performance.open-behind":              "bool",
+	"performance.parallel-readdir":         "bool",
+	"performance.quick-read":               "bool",
+	"performance.read-ahead":               "bool",
+	"performance.readdir-ahead":            "bool",
+	"performance.stat-prefetch":            "bool",
+	"performance.strict-o-direct":          "bool",
+	"performance.write-behind":             "bool",
+	"client.event-threads":                 "int",
+	"cluster.background-self-heal-count":   "int",
+	"cluster.heal-timeout":                 "int",
+	"cluster.self-heal-window-size":        "int",
+	"cluster.shd-max-threads":              "int",
+	"cluster.shd-wait-qlength":             "int",
+	"network.ping-timeout":                 "int",
+	"performance.io-thread-count":          "int",
+	"server.event-threads":                 "int",
+	"features.shard-block-size":            "size",
+	"performance.cache-max-file-size":      "size",
+	"performance.cache-min-file-size":      "size",
+	"performance.cache-size":               "size",
+	"performance.write-behind-window-size": "size",
+	"cluster.min-free-inodes":              "percent",
+	"cluster.min-free-disk":                "size-or-percent",
+	"storage.reserve":                      "size-or-percent",
+}
+
+// glusterVersion returns the version of the local gluster CLI, e.g. "9.4".
+func glusterVersion(ctx context.Context) (string, error) {
+	output, e := runGluster(ctx, "--version")
+	if e != nil {
+		return "", glusterError(output, e)
+	}
+	m := glusterVersionRe.FindStringSubmatch(strings.TrimSpace(string(output)))
+	if m == nil {
+		return "", fmt.Errorf("unexpected gluster --version output: %q", output)
+	}
+	return m[1], nil
+}
+
+// Get returns the catalog of the running gluster version.
+func (c *OptionCatalogCache) Get(ctx context.Context) (*OptionCatalog, error) {
+	version, e := glusterVersion(ctx)
+	if e != nil {
+		return nil, e
+	}
+
+	c.mu.Lock()
+	catalog, ok := c.byVersion[version]
+	c.mu.Unlock()
+	if ok {
+		return catalog, nil
+	}
+
+	defaults, e := optionDefaults(ctx)
+	if e != nil {
+		return nil, e
+	}
+	catalog = &OptionCatalog{Version: version, Options: make(map[string]CatalogOption, len(defaults))}
+	for name, option := range defaults {
+		catalog.Options[name] = CatalogOption{
+			Name:        name,
+			Type:        optionTypes[name],
+			Default:     option.DefaultValue,
+			Description: strings.TrimSpace(option.Description),
+		}
+	}
+
+	c.mu.Lock()
+	c.byVersion[version] = catalog
+	c.mu.Unlock()
+	L.Gluster.Infof("loaded %d volume options of gluster %s", len(catalog.Options), version)
+	return catalog, nil
+}
+
+// Lookup finds an option by its full name, or by the part after the dot when
+// that is unique, as the CLI does.
+func (c *OptionCatalog) Lookup(key string) (CatalogOption, bool) {
+	if option, ok := c.Options[key]; ok {
+		return option, true
+	}
+	if strings.Contains(key, ".") {
+		return CatalogOption{}, false
+	}
+	var found []CatalogOption
+	for name, option := range c.Options {
+		if strings.HasSuffix(name, "."+key) {
+			found = append(found, option)
+		}
+	}
+	if len(found) != 1 {
+		return CatalogOption{}, false
+	}
+	return found[0], true
+}
+
+// Suggest returns the option name closest to a mistyped key.
+func (c *OptionCatalog) Suggest(key string) string {
+	best, bestDistance := "", len(key)/2+1
+	for name := range c.Options {
+		short := name[strings.LastIndex(name, ".")+1:]
+		for _, candidate := range []string{name, short} {
+			if d := editDistance(key, candidate); d < bestDistance || d == bestDistance && name < best {
+				best, bestDistance = name, d
+			}
+		}
+	}
+	return best
+}
+
+// Validate checks the key exists and, for the options of optionTypes, that
+// the value matches the type.
+func (c *OptionCatalog) Validate(field, key, value string) *ValidationError {
+	option, ok := c.Lookup(key)
+	if !ok {
+		reason := "is not an option of gluster " + c.Version
+		if suggestion := c.Suggest(key); suggestion != "" {
+			reason += fmt.Sprintf(", did you mean %s?", suggestion)
+		}
+		return invalid(field+".name", key, reason)
+	}
+
+	valid := true
+	switch option.Type {
+	case "bool":
+		valid = boolValues[strings.ToLower(value)]
+	case "int":
+		valid = intValue.MatchString(value)
+	case "percent":
+		valid = percentValue.MatchString(value)
+	case "size":
+		valid = sizeValue.MatchString(value)
+	case "size-or-percent":
+		valid = sizeValue.MatchString(value) || percentValue.MatchString(value)
+	}
+	if !valid {
+		return invalid(field+".value", value, fmt.Sprintf("must be a %s value for %s", option.Type, option.Name))
+	}
+	return nil
+}
+
+func editDistance(a, b string) int {
+	prev := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur := make([]int, len(b)+1)
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = minInt(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev = cur
+	}
+	return prev[len(b)]
+}
+
+func minInt(v ...int) int {
+	m := v[0]
+	for _, n := range v[1:] {
+		if n < m {
+			m = n
+		}
+	}
+	return m
+}
+
+type OptionCatalogResponse struct {
+	CommonVolumeResponse
+	Version string          `json:"version"`
+	Options []CatalogOption `json:"options"`
+}
+
+/*
+[example]
+docker exec glusterfs gluster volume set help-xml
+
+curl -X GET http://127.0.0.1:7030/gluster/volume/options/catalog
+<- {"result":"OK","version":"9.4","options":[{"name":"performance.cache-size","type":"size","default":"32MB","description":"..."},...]}
+*/
+func ProcessVolumeOptionCatalog(w http.ResponseWriter, r *http.Request) {
+	var rsp OptionCatalogResponse
+	defer writeResponse(w, &rsp)
+
+	catalog, e := catalogs.Get(r.Context())
+	if e != nil {
+		L.Gluster.Error(e.Error())
+		rsp.Fail(e)
+		return
+	}
+
+	rsp.Version = catalog.Version
+	rsp.Options = make([]CatalogOption, 0, len(catalog.Options))
+	for _, option := range catalog.Options {
+		rsp.Options = append(rsp.Options, option)
+	}
+	sort.Slice(rsp.Options, func(i, j int) bool { return rsp.Options[i].Name < rsp.Options[j].Name })
+	rsp.Result = "OK"
+}
@@ -0,0 +1,96 @@
+package gluster
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestOptionCatalogValidate(t *testing.T) {
+	fakeOptions(t)
+	catalog, e := catalogs.Get(context.Background())
+	if e != nil {
+		t.Fatal(e)
+	}
+	if catalog.Version != "9.4" || len(catalog.Options) != 5 || catalog.Options["network.ping-timeout"].Type != "int" {
+		t.Fatalf("catalog = %+v", catalog)
+	}
+
+	tests := []struct {
+		key   string
+		value string
+		field string // empty when valid
+	}{
+		{"performance.cache-size", "256MB", ""},
+		{"performance.cache-size", "1.5 GB", ""},
+		{"performance.cache-size", "lots", "o.value"},
+		{"cache-size", "64MB", ""}, // the CLI takes the part after the dot
+		{"nfs.disable", "On", ""},
+		{"nfs.disable", "maybe", "o.value"},
+		{"network.ping-timeout", "10", ""},
+		{"network.ping-timeout", "10s", "o.value"},
+		{"cluster.min-free-disk", "5%", ""},
+		{"cluster.min-free-disk", "20GB", ""},
+		{"cluster.min-free-disk", "much", "o.value"},
+		{"diagnostics.brick-log-level", "anything", ""}, // untyped, glusterd judges
+		{"performance.cache-sise", "256MB", "o.name"},
+		{"timeout", "5", "o.name"}, // a part of ping-timeout, not the part after the dot
+	}
+	for _, test := range tests {
+		e := catalog.Validate("o", test.key, test.value)
+		switch {
+		case test.field == "" && e != nil:
+			t.Errorf("%s=%s: %v", test.key, test.value, e)
+		case test.field != "" && (e == nil || e.Field != test.field):
+			t.Errorf("%s=%s: %v, want invalid %s", test.key, test.value, e, test.field)
+		}
+	}
+
+	if suggestion := catalog.Suggest("performance.cache-sise"); suggestion != "performance.cache-size" {
+		t.Errorf("suggest %q", suggestion)
+	}
+}
+
+func TestVolumeOptionSetChecked(t *testing.T) {
+	tests := []struct {
+		name  string
+		body  string
+		field string
+		calls int
+	}{
+		{"typo", `{"volname": "test", "options": [{"name": "performance.cache-sise", "value": "256MB"}]}`, "options[0].name", 0},
+		{"bad value", `{"volname": "test", "options": [{"name": "nfs.disable", "value": "on"}, {"name": "network.ping-timeout", "value": "10s"}]}`, "options[1].value", 0},
+		{"forced", `{"volname": "test", "options": [{"name": "performance.cache-sise", "value": "256MB"}], "force": "true"}`, "", 1},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			f := fakeOptions(t).On("volume set: failed: option : performance.cache-sise does not exist", errExit, "gluster", "volume", "set", "test")
+			var rsp VolumeOptionResponse
+			status := serve(t, ProcessVolumeOptionSet, test.body, &rsp)
+			if test.field != "" && (status != http.StatusBadRequest || rsp.Error == nil || rsp.Error.Field != test.field) {
+				t.Errorf("status %d, %+v, want invalid %s", status, rsp.Error, test.field)
+			}
+			sets := 0
+			for _, call := range f.Invocations() {
+				if strings.HasPrefix(call, "gluster volume set test ") {
+					sets++
+				}
+			}
+			if sets != test.calls {
+				t.Errorf("ran %d volume set, want %d", sets, test.calls)
+			}
+		})
+	}
+}
+
+func TestVolumeOptionCatalog(t *testing.T) {
+	fakeOptions(t)
+	var rsp OptionCatalogResponse
+	if status := serve(t, ProcessVolumeOptionCatalog, "", &rsp); status != http.StatusOK {
+		t.Fatalf("status %d: %+v", status, rsp.Error)
+	}
+	if rsp.Version != "9.4" || len(rsp.Options) != 5 || rsp.Options[0].Name != "cluster.min-free-disk" || rsp.Options[0].Type != "size-or-percent" {
+		t.Errorf("catalog = %+v", rsp)
+	}
+}
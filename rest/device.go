@@ -0,0 +1,143 @@
+package gluster
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	L "hualu.com/logger"
+)
+
+// FstabFile records the mounts of prepared brick devices, so they are mounted
+// again after a reboot.
+var FstabFile = "/etc/fstab"
+
+// brickMountOptions are the xfs mount options gluster recommends for bricks.
+const brickMountOptions = "inode64,noatime"
+
+// brickMountDir is where the device of the brick at path is mounted: its
+// parent, glusterd refuses a brick on the root of a mount.
+func brickMountDir(path string) string {
+	return filepath.Dir(path)
+}
+
+// prepareBrickDevice formats device with xfs and mounts it on the parent of
+// the brick path, adding the mount to FstabFile. Only an unused device is
+// taken: one that is mounted or holds any signature, a filesystem, LVM or a
+// partition table, is refused rather than wiped.
+func prepareBrickDevice(ctx context.Context, device, path string) (string, error) {
+	dir := brickMountDir(path)
+
+	output, e := run(ctx, Command{Name: "lsblk", Args: []string{"-dn", "-o", "NAME", device}})
+	if e != nil {
+		return "", NewError(http.StatusNotFound, CodeNotFound, "%s is not a block device: %s", device, strings.TrimSpace(string(output)))
+	}
+	// findmnt exits 1 when nothing matches
+	output, e = run(ctx, Command{Name: "findmnt", Args: []string{"-n", "-o", "TARGET", "--source", device}})
+	if target := strings.TrimSpace(string(output)); e == nil && target != "" {
+		return "", NewError(http.StatusConflict, CodeBrickInUse, "device %s is mounted on %s", device, target)
+	}
+	output, e = run(ctx, Command{Name: "findmnt", Args: []string{"-n", "-o", "SOURCE", "--mountpoint", dir}})
+	if source := strings.TrimSpace(string(output)); e == nil && source != "" {
+		return "", NewError(http.StatusConflict, CodeBrickInUse, "%s is mounted on %s, unmount it first", source, dir)
+	}
+	// mounting over files would hide them
+	if empty, e := localPathEmpty(ctx, dir); e != nil {
+		return "", e
+	} else if !empty {
+		return "", NewError(http.StatusConflict, CodeBrickNotEmpty, "directory %s is not empty", dir)
+	}
+	// blkid exits 2 when the device holds no signature
+	output, e = run(ctx, Command{Name: "blkid", Args: []string{"-p", "-o", "value", "-s", "TYPE", device}})
+	signature := strings.TrimSpace(string(output))
+	if e == nil && signature != "" {
+		return "", NewError(http.StatusConflict, CodeBrickInUse,
+			"device %s holds a %s signature, wipe it (wipefs -a) to use it for a brick", device, signature)
+	}
+	if e != nil && signature != "" {
+		return "", glusterError(output, e)
+	}
+
+	if output, e := run(ctx, Command{Name: "mkfs.xfs", Args: []string{"-i", "size=512", device}, Timeout: LongTimeout}); e != nil {
+		L.Gluster.Error(string(output))
+		return "", glusterError(output, e)
+	}
+	if output, e := run(ctx, Command{Name: "mkdir", Args: []string{"-p", dir}}); e != nil {
+		return "", glusterError(output, e)
+	}
+	if output, e := run(ctx, Command{Name: "mount", Args: []string{"-t", "xfs", "-o", brickMountOptions, device, dir}}); e != nil {
+		L.Gluster.Error(string(output))
+		return "", glusterError(output, e)
+	}
+
+	output, e = run(ctx, Command{Name: "blkid", Args: []string{"-o", "value", "-s", "UUID", device}})
+	source := "UUID=" + strings.TrimSpace(string(output))
+	if e != nil || source == "UUID=" {
+		source = device
+	}
+	f, e := os.OpenFile(FstabFile, os.O_APPEND|os.O_WRONLY, 0644)
+	if e != nil {
+		return dir, NewError(http.StatusInternalServerError, CodeInternal, "%s is mounted on %s but not added to %s: %s", device, dir, FstabFile, e)
+	}
+	defer f.Close()
+	if _, e := fmt.Fprintf(f, "%s %s xfs defaults,%s 0 0\n", source, dir, brickMountOptions); e != nil {
+		return dir, NewError(http.StatusInternalServerError, CodeInternal, "%s is mounted on %s but not added to %s: %s", device, dir, FstabFile, e)
+	}
+	return dir, nil
+}
+
+// remotePrepareDevice has the gluster-rest service of host prepare device,
+// see ProcessBrickDevicePrepare.
+func remotePrepareDevice(ctx context.Context, host, device, path string) (string, error) {
+	var rsp BrickDeviceResponse
+	if e := callPeer(ctx, host, "/gluster/brick/device/prepare", BrickDeviceRequest{Device: device, Path: path}, &rsp); e != nil {
+		return "", NewError(http.StatusServiceUnavailable, CodePeerNotConnected, "cannot prepare device %s on %s: %s", device, host, e)
+	}
+	if rsp.Error != nil {
+		return "", rsp.Error
+	}
+	return rsp.Mount, nil
+}
+
+type BrickDeviceRequest struct {
+	Device string `json:"device"` // an unused block device
+	Path   string `json:"path"`   // the brick path, the device is mounted on its parent
+}
+
+type BrickDeviceResponse struct {
+	CommonResponse
+	Mount string `json:"mount"`
+}
+
+/*
+[example]
+curl -X POST http://node3:7030/gluster/brick/device/prepare -H 'Content-Type: application/json' -d '{
+"device": "/dev/sdc",
+"path": "/data/brick1/test"
+}'
+<- {"result":"OK","mount":"/data/brick1"}
+*/
+func ProcessBrickDevicePrepare(w http.ResponseWriter, r *http.Request) {
+	var rsp BrickDeviceResponse
+	defer writeResponse(w, &rsp)
+
+	// analyze request
+	var req BrickDeviceRequest
+	if e := readRequest(r, &req); e != nil {
+		L.Gluster.Error(e.Error())
+		rsp.Fail(e)
+		return
+	}
+
+	mount, e := prepareBrickDevice(r.Context(), req.Device, req.Path)
+	if e != nil {
+		L.Gluster.Error(e.Error())
+		rsp.Fail(e)
+		return
+	}
+	rsp.Mount = mount
+	rsp.Result = "OK"
+}
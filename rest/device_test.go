@@ -0,0 +1,145 @@
+package gluster
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// fakeDevice has f answer the checks of an unused /dev/sdc, with a temporary
+// FstabFile.
+func fakeDevice(t *testing.T, f *FakeRunner) *FakeRunner {
+	fstab := filepath.Join(t.TempDir(), "fstab")
+	if e := os.WriteFile(fstab, []byte("/dev/sda1 / xfs defaults 0 0\n"), 0644); e != nil {
+		t.Fatal(e)
+	}
+	previous := FstabFile
+	FstabFile = fstab
+	t.Cleanup(func() { FstabFile = previous })
+
+	return f.On("sdc\n", nil, "lsblk").
+		On("", errExit, "findmnt").
+		On("", errExit, "blkid", "-p").
+		On("1b2c\n", nil, "blkid", "-o", "value", "-s", "UUID")
+}
+
+func TestBrickDevicePrepare(t *testing.T) {
+	f := fakeDevice(t, fakeGluster(t))
+	var rsp BrickDeviceResponse
+	if status := serve(t, ProcessBrickDevicePrepare, `{"device": "/dev/sdc", "path": "/data/brick3/test"}`, &rsp); status != http.StatusOK {
+		t.Fatalf("status %d: %+v", status, rsp.Error)
+	}
+	if rsp.Mount != "/data/brick3" {
+		t.Errorf("mount = %q", rsp.Mount)
+	}
+	checkCalls(t, f, []string{
+		"lsblk -dn -o NAME /dev/sdc",
+		"findmnt -n -o TARGET --source /dev/sdc",
+		"findmnt -n -o SOURCE --mountpoint /data/brick3",
+		"ls -A /data/brick3",
+		"blkid -p -o value -s TYPE /dev/sdc",
+		"mkfs.xfs -i size=512 /dev/sdc",
+		"mkdir -p /data/brick3",
+		"mount -t xfs -o inode64,noatime /dev/sdc /data/brick3",
+		"blkid -o value -s UUID /dev/sdc",
+	})
+	fstab, _ := os.ReadFile(FstabFile)
+	if want := "/dev/sda1 / xfs defaults 0 0\nUUID=1b2c /data/brick3 xfs defaults,inode64,noatime 0 0\n"; string(fstab) != want {
+		t.Errorf("fstab = %q, want %q", fstab, want)
+	}
+}
+
+func TestBrickDevicePrepareRefused(t *testing.T) {
+	tests := []struct {
+		name   string
+		output string
+		err    error
+		argv   []string
+		status int
+		code   string
+	}{
+		{"not a block device", "lsblk: /dev/sdc: not a block device", errExit, []string{"lsblk"}, http.StatusNotFound, CodeNotFound},
+		{"device mounted", "/mnt/old\n", nil, []string{"findmnt", "-n", "-o", "TARGET"}, http.StatusConflict, CodeBrickInUse},
+		{"directory mounted", "/dev/sdb\n", nil, []string{"findmnt", "-n", "-o", "SOURCE"}, http.StatusConflict, CodeBrickInUse},
+		{"directory not empty", "lost+found\n", nil, []string{"ls", "-A"}, http.StatusConflict, CodeBrickNotEmpty},
+		{"filesystem", "xfs\n", nil, []string{"blkid", "-p"}, http.StatusConflict, CodeBrickInUse},
+		{"lvm", "LVM2_member\n", nil, []string{"blkid", "-p"}, http.StatusConflict, CodeBrickInUse},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			f := fakeDevice(t, fakeGluster(t)).On(test.output, test.err, test.argv...)
+			var rsp BrickDeviceResponse
+			if status := serve(t, ProcessBrickDevicePrepare, `{"device": "/dev/sdc", "path": "/data/brick3/test"}`, &rsp); status != test.status {
+				t.Errorf("status %d, want %d", status, test.status)
+			}
+			if rsp.Error == nil || rsp.Error.Code != test.code {
+				t.Errorf("got %+v, want %s", rsp.Error, test.code)
+			}
+			for _, call := range f.Invocations() {
+				if strings.HasPrefix(call, "mkfs") || strings.HasPrefix(call, "mount") {
+					t.Errorf("ran %q on a device in use", call)
+				}
+			}
+			if fstab, _ := os.ReadFile(FstabFile); strings.Contains(string(fstab), "/data/brick3") {
+				t.Errorf("fstab = %q", fstab)
+			}
+		})
+	}
+}
+
+// TestVolumeReplaceFailedBrickDevice has the peer of the new brick prepare
+// the device before the brick is replaced.
+func TestVolumeReplaceFailedBrickDevice(t *testing.T) {
+	tests := []struct {
+		name      string
+		signature string
+		state     JobState
+		replaced  bool
+	}{
+		{"blank device", "", JobSucceeded, true},
+		{"device in use", "ext4\n", JobFailed, false},
+	}
+	body := `{"volname": "test", "brick": "node2:/data/brick1/test", "new_brick": "127.0.0.1:/data/brick3/test", "device": "/dev/sdc"}`
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			peerService(t)
+			f := fakeDevice(t, fakeBrickVolume(t, "")).
+				On(volumeStatusXML("node1:/data/brick1/test", "127.0.0.1:/data/brick3/test"), nil, "gluster", "volume", "status")
+			if test.signature != "" {
+				f.On(test.signature, nil, "blkid", "-p")
+			}
+
+			var rsp CommonVolumeResponse
+			if status := serve(t, ProcessVolumeReplaceFailedBrick, body, &rsp); status != http.StatusOK {
+				t.Fatalf("status %d: %+v", status, rsp.Error)
+			}
+			job := waitJob(t, rsp.JobID)
+			if job.State != test.state {
+				t.Fatalf("job = %+v", job)
+			}
+			var prepare *JobStep
+			for i, step := range job.Progress.Steps {
+				if step.Name == stepPrepareDevice {
+					prepare = &job.Progress.Steps[i]
+				}
+			}
+			if prepare == nil {
+				t.Fatalf("steps = %+v", job.Progress.Steps)
+			}
+			if test.replaced && prepare.Message != "/dev/sdc mounted on 127.0.0.1:/data/brick3" {
+				t.Errorf("prepare device = %+v", prepare)
+			}
+			replaced := false
+			for _, call := range f.Invocations() {
+				if strings.HasPrefix(call, "gluster volume replace-brick test node2:/data/brick1/test 127.0.0.1:/data/brick3/test") {
+					replaced = true
+				}
+			}
+			if replaced != test.replaced {
+				t.Errorf("replaced %v, want %v: %q", replaced, test.replaced, f.Invocations())
+			}
+		})
+	}
+}
@@ -0,0 +1,279 @@
+package gluster
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+
+	L "hualu.com/logger"
+)
+
+// Error is the error model of every API response.
+type Error struct {
+	Status   int         `json:"status"`
+	Code     string      `json:"code"`
+	Message  string      `json:"message"`
+	OpRet    int         `json:"op_ret,omitempty"`
+	OpErrno  int         `json:"op_errno,omitempty"`
+	OpErrstr string      `json:"op_errstr,omitempty"`
+	Output   string      `json:"output,omitempty"` // raw command output, for debugging
+	Field    string      `json:"field,omitempty"`  // request field that failed validation
+	Holder   *LockHolder `json:"holder,omitempty"` // operation holding a busy lock
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+// stable error codes
+const (
+	CodeInvalidArgument       = "invalid_argument"
+	CodeNotFound              = "not_found"
+	CodeVolumeNotFound        = "volume_not_found"
+	CodeVolumeExists          = "volume_exists"
+	CodeVolumeStarted         = "volume_already_started"
+	CodeVolumeStopped         = "volume_not_started"
+	CodeVolumeIsSnapshot      = "volume_is_snapshot"
+	CodeSnapshotNotFound      = "snapshot_not_found"
+	CodeSnapshotExists        = "snapshot_exists"
+	CodeSnapshotLimit         = "snapshot_limit_reached"
+	CodeNotThinProvisioned    = "brick_not_thin_provisioned"
+	CodePeerNotFound          = "peer_not_found"
+	CodePeerNotConnected      = "peer_not_connected"
+	CodeBrickInUse            = "brick_in_use"
+	CodeInsufficientBricks    = "insufficient_bricks"
+	CodeInsufficientSpace     = "insufficient_space"
+	CodeBrickOnline           = "brick_online"
+	CodeBrickNotEmpty         = "brick_not_empty"
+	CodeBrickDown             = "brick_down"
+	CodeQuorumNotMet          = "quorum_not_met"
+	CodeRebalanceRunning      = "rebalance_in_progress"
+	CodeMigrationRunning      = "migration_in_progress"
+	CodeMigrationIncomplete   = "migration_not_completed"
+	CodeGeoRepRunning         = "georep_running"
+	CodeTransactionInProgress = "transaction_in_progress"
+	CodeLocked                = "locked"
+	CodeNotInSplitBrain       = "not_in_split_brain"
+	CodeQuotaDisabled         = "quota_not_enabled"
+	CodeQuotaEnabled          = "quota_already_enabled"
+	CodeScheduleExists        = "schedule_exists"
+	CodeScheduleStore         = "schedule_store_unavailable"
+	CodeAuditStore            = "audit_store_unavailable"
+	CodeNotSupported          = "operation_not_supported"
+	CodeTimeout               = "timeout"
+	CodeCancelled             = "cancelled"
+	CodeCommandFailed         = "command_failed"
+	CodeInternal              = "internal"
+)
+
+func NewError(status int, code, format string, v ...interface{}) *Error {
+	return &Error{Status: status, Code: code, Message: fmt.Sprintf(format, v...)}
+}
+
+// opErrno values of glusterd, see glusterd-errno.h
+const (
+	egIntrnl    = 30800
+	egOpNotSup  = 30801
+	egAnoTrans  = 30802
+	egBrckDwn   = 30803
+	egNodeDwn   = 30804
+	egHrdLmt    = 30805
+	egNoVol     = 30806
+	egNoSnap    = 30807
+	egRbalRun   = 30808
+	egVolRun    = 30809
+	egVolStp    = 30810
+	egVolExst   = 30811
+	egSnapExst  = 30812
+	egIsSnap    = 30813
+	egGeoRepRun = 30814
+	egNotThinP  = 30815
+)
+
+var opErrnoCodes = map[int]struct {
+	status int
+	code   string
+}{
+	egIntrnl:    {http.StatusInternalServerError, CodeInternal},
+	egOpNotSup:  {http.StatusBadRequest, CodeNotSupported},
+	egAnoTrans:  {http.StatusConflict, CodeTransactionInProgress},
+	egBrckDwn:   {http.StatusServiceUnavailable, CodeBrickDown},
+	egNodeDwn:   {http.StatusServiceUnavailable, CodePeerNotConnected},
+	egHrdLmt:    {http.StatusConflict, CodeSnapshotLimit},
+	egNoVol:     {http.StatusNotFound, CodeVolumeNotFound},
+	egNoSnap:    {http.StatusNotFound, CodeSnapshotNotFound},
+	egRbalRun:   {http.StatusConflict, CodeRebalanceRunning},
+	egVolRun:    {http.StatusConflict, CodeVolumeStarted},
+	egVolStp:    {http.StatusConflict, CodeVolumeStopped},
+	egVolExst:   {http.StatusConflict, CodeVolumeExists},
+	egSnapExst:  {http.StatusConflict, CodeSnapshotExists},
+	egIsSnap:    {http.StatusConflict, CodeVolumeIsSnapshot},
+	egGeoRepRun: {http.StatusConflict, CodeGeoRepRunning},
+	egNotThinP:  {http.StatusConflict, CodeNotThinProvisioned},
+}
+
+// cliMessages maps fragments of plain CLI messages to codes, for commands
+// without --xml or failures reported before glusterd is reached.
+var cliMessages = []struct {
+	fragment string
+	status   int
+	code     string
+}{
+	{"already exists", http.StatusConflict, CodeVolumeExists},
+	{"is already part of a volume", http.StatusConflict, CodeBrickInUse},
+	{"is already used by", http.StatusConflict, CodeBrickInUse},
+	{"quorum is not met", http.StatusServiceUnavailable, CodeQuorumNotMet},
+	{"quorum not met", http.StatusServiceUnavailable, CodeQuorumNotMet},
+	{"another transaction is in progress", http.StatusConflict, CodeTransactionInProgress},
+	{"locking failed", http.StatusConflict, CodeTransactionInProgress},
+	{"is not in 'peer in cluster' state", http.StatusServiceUnavailable, CodePeerNotConnected},
+	{"transport endpoint is not connected", http.StatusServiceUnavailable, CodePeerNotConnected},
+	{"is down", http.StatusServiceUnavailable, CodePeerNotConnected},
+	{"not a friend", http.StatusNotFound, CodePeerNotFound},
+	{"is not part of cluster", http.StatusNotFound, CodePeerNotFound},
+	{"quota is disabled", http.StatusConflict, CodeQuotaDisabled},
+	{"quota is not enabled", http.StatusConflict, CodeQuotaDisabled},
+	{"quota is already enabled", http.StatusConflict, CodeQuotaEnabled},
+	{"no such file or directory", http.StatusNotFound, CodeNotFound}, // quota path
+	{"does not exist", http.StatusNotFound, CodeVolumeNotFound},
+	{"already started", http.StatusConflict, CodeVolumeStarted},
+	{"is not started", http.StatusConflict, CodeVolumeStopped},
+	{"not thinly provisioned", http.StatusConflict, CodeNotThinProvisioned},
+	{"rebalance is in progress", http.StatusConflict, CodeRebalanceRunning},
+	{"not in split-brain", http.StatusConflict, CodeNotInSplitBrain},
+	{"is not of type replicate/disperse", http.StatusBadRequest, CodeNotSupported},
+	{"usage:", http.StatusBadRequest, CodeInvalidArgument},
+	{"wrong brick type", http.StatusBadRequest, CodeInvalidArgument},
+}
+
+type cliOutputXML struct {
+	XMLName  xml.Name `xml:"cliOutput"`
+	OpRet    int      `xml:"opRet"`
+	OpErrno  int      `xml:"opErrno"`
+	OpErrstr string   `xml:"opErrstr"`
+}
+
+// glusterError classifies a failed command from its runner error, the
+// opRet/opErrno/opErrstr of an --xml reply, or the plain CLI message.
+func glusterError(output []byte, e error) *Error {
+	switch e {
+	case ErrTimeout:
+		return &Error{Status: http.StatusGatewayTimeout, Code: CodeTimeout, Message: e.Error(), Output: string(output)}
+	case context.Canceled:
+		return &Error{Status: http.StatusServiceUnavailable, Code: CodeCancelled, Message: e.Error(), Output: string(output)}
+	}
+
+	err := &Error{
+		Status:  http.StatusInternalServerError,
+		Code:    CodeCommandFailed,
+		Message: strings.TrimSpace(string(output)),
+		Output:  string(output),
+	}
+
+	var reply cliOutputXML
+	if bytes.Contains(output, []byte("<cliOutput>")) && xml.Unmarshal(output, &reply) == nil {
+		err.OpRet = reply.OpRet
+		err.OpErrno = reply.OpErrno
+		err.OpErrstr = reply.OpErrstr
+		if reply.OpErrstr != "" {
+			err.Message = reply.OpErrstr
+		}
+		if c, ok := opErrnoCodes[reply.OpErrno]; ok {
+			err.Status, err.Code = c.status, c.code
+			return err
+		}
+	}
+
+	if err.Message == "" && e != nil {
+		err.Message = e.Error()
+	}
+	message := strings.ToLower(err.Message)
+	for _, m := range cliMessages {
+		if strings.Contains(message, m.fragment) {
+			err.Status, err.Code = m.status, m.code
+			break
+		}
+	}
+	return err
+}
+
+// toError converts any error raised in a handler into the API error model.
+func toError(e error) *Error {
+	switch err := e.(type) {
+	case *Error:
+		return err
+	case *ValidationError:
+		return &Error{Status: http.StatusBadRequest, Code: CodeInvalidArgument, Message: err.Error(), Field: err.Field}
+	case *LockBusyError:
+		return &Error{Status: http.StatusConflict, Code: CodeLocked, Message: err.Error(), Holder: &err.Holder}
+	case *json.SyntaxError, *json.UnmarshalTypeError:
+		return &Error{Status: http.StatusBadRequest, Code: CodeInvalidArgument, Message: err.Error()}
+	}
+	if e == ErrTimeout || e == context.Canceled {
+		return glusterError(nil, e)
+	}
+	return &Error{Status: http.StatusInternalServerError, Code: CodeInternal, Message: e.Error()}
+}
+
+// CommonResponse is embedded in every response.
+type CommonResponse struct {
+	Result string `json:"result"`
+	Errors string `json:"errors,omitempty"` // same as Error.Message, kept for existing clients
+	Error  *Error `json:"error,omitempty"`
+	JobID  string `json:"job_id,omitempty"` // background job, see /gluster/jobs/{id}
+}
+
+// Fail marks the response as failed with e.
+func (rsp *CommonResponse) Fail(e error) {
+	err := toError(e)
+	rsp.Result = "ERROR"
+	rsp.Errors = err.Message
+	rsp.Error = err
+}
+
+func (rsp *CommonResponse) HTTPStatus() int {
+	if rsp.Error != nil && rsp.Error.Status != 0 {
+		return rsp.Error.Status
+	}
+	return http.StatusOK
+}
+
+type response interface {
+	HTTPStatus() int
+}
+
+// writeResponse writes rsp as json with the status of its error.
+func writeResponse(w http.ResponseWriter, rsp response) {
+	buf, e := json.Marshal(rsp)
+	if e != nil {
+		L.Gluster.Error(e.Error())
+		w.WriteHeader(500)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(rsp.HTTPStatus())
+	w.Write(buf)
+}
+
+// readRequest reads the json body of r into req and validates it.
+func readRequest(r *http.Request, req interface{}) error {
+	body, e := ioutil.ReadAll(r.Body)
+	defer r.Body.Close()
+	if e != nil {
+		return NewError(http.StatusBadRequest, CodeInvalidArgument, "read request: %s", e)
+	}
+	if e := json.Unmarshal(body, req); e != nil {
+		return NewError(http.StatusBadRequest, CodeInvalidArgument, "parse request: %s", e)
+	}
+	if v, ok := req.(interface{ Validate() *ValidationError }); ok {
+		if e := v.Validate(); e != nil {
+			return e
+		}
+	}
+	return nil
+}
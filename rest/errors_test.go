@@ -0,0 +1,37 @@
+package gluster
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestGlusterErrorStatus(t *testing.T) {
+	tests := []struct {
+		name   string
+		output string
+		err    error
+		status int
+		code   string
+	}{
+		{"volume not found", `<cliOutput><opRet>-1</opRet><opErrno>30806</opErrno><opErrstr>Volume test does not exist</opErrstr></cliOutput>`, errExit, http.StatusNotFound, CodeVolumeNotFound},
+		{"already started", `<cliOutput><opRet>-1</opRet><opErrno>30809</opErrno><opErrstr>Volume test already started</opErrstr></cliOutput>`, errExit, http.StatusConflict, CodeVolumeStarted},
+		{"another transaction", `<cliOutput><opRet>-1</opRet><opErrno>30802</opErrno><opErrstr>Another transaction is in progress for test.</opErrstr></cliOutput>`, errExit, http.StatusConflict, CodeTransactionInProgress},
+		{"node down", `<cliOutput><opRet>-1</opRet><opErrno>30804</opErrno><opErrstr>Host node2 not connected</opErrstr></cliOutput>`, errExit, http.StatusServiceUnavailable, CodePeerNotConnected},
+		{"unknown opErrno", `<cliOutput><opRet>-1</opRet><opErrno>22</opErrno><opErrstr>something broke</opErrstr></cliOutput>`, errExit, http.StatusInternalServerError, CodeCommandFailed},
+		{"plain quorum message", "volume start: test: failed: Quorum not met. Volume operation not allowed.", errExit, http.StatusServiceUnavailable, CodeQuorumNotMet},
+		{"plain lock message", "Locking failed on node2. Please check log file for details.", errExit, http.StatusConflict, CodeTransactionInProgress},
+		{"timeout", "", ErrTimeout, http.StatusGatewayTimeout, CodeTimeout},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			fakeGluster(t).On(test.output, test.err, "gluster", "volume", "start", "test")
+			var rsp CommonResponse
+			if status := serve(t, ProcessVolumeStart, `{"volname": "test"}`, &rsp); status != test.status {
+				t.Errorf("status %d, want %d", status, test.status)
+			}
+			if rsp.Error == nil || rsp.Error.Code != test.code {
+				t.Errorf("got %+v, want %s", rsp.Error, test.code)
+			}
+		})
+	}
+}
@@ -0,0 +1,159 @@
+package gluster
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"hualu.com/gluster-rest/parser"
+	L "hualu.com/logger"
+)
+
+// VolumeExpandRequest adds whole replica / disperse sets to a volume and
+// rebalances it, so the new bricks take files.
+type VolumeExpandRequest struct {
+	CommonVolumeRequest
+	Bricks    []string `json:"bricks"`    // kept in order, consecutive bricks form a set
+	Force     string   `json:"force"`     // add-brick force, also allows two bricks of a set on one host and used brick directories
+	Rebalance string   `json:"rebalance"` // full (default) migrates existing files, fix-layout only places new ones
+}
+
+type VolumeExpandResponse struct {
+	CommonVolumeResponse
+	Checks []PreCheck `json:"checks,omitempty"`
+}
+
+// steps of the volume expansion
+const (
+	stepAddBricks = "add bricks"
+	stepRebalance = "rebalance"
+)
+
+// expandJob adds the bricks and follows the rebalance to completion. The
+// volume lock is held throughout, the rebalance is part of the operation.
+func expandJob(req VolumeExpandRequest, volume parser.Volume) JobFunc {
+	return func(ctx context.Context, update func(JobProgress)) (string, error) {
+		steps := newJobSteps(update, stepAddBricks, stepRebalance)
+
+		e := steps.Run(stepAddBricks, func() (string, error) {
+			args := append([]string{"volume", "add-brick", req.Volname}, req.Bricks...)
+			if req.Force == "true" {
+				args = append(args, "force")
+			}
+			output, e := runGlusterConfirm(ctx, args...)
+			if e != nil {
+				L.Gluster.Error(string(output))
+				return "", glusterError(output, e)
+			}
+			return strings.TrimSpace(string(output)), nil
+		})
+		if e != nil {
+			return "", e
+		}
+
+		if volume.Status != parser.VolumeStarted {
+			steps.Skip(stepRebalance, "volume is not started, rebalance it once started")
+			return "", nil
+		}
+		var output string
+		e = steps.Run(stepRebalance, func() (string, error) {
+			args := []string{"volume", "rebalance", req.Volname, "start", "--xml"}
+			if req.Rebalance == "fix-layout" {
+				args = []string{"volume", "rebalance", req.Volname, "fix-layout", "start", "--xml"}
+			}
+			out, e := runGluster(ctx, args...)
+			if e != nil {
+				L.Gluster.Error(string(out))
+				return "", glusterError(out, e)
+			}
+			if output, e = followRebalance(ctx, req.Volname, steps.Progress); e != nil {
+				return "", e
+			}
+			return req.Rebalance + " rebalance completed", nil
+		})
+		return output, e
+	}
+}
+
+// expandChecks returns the volume after checking the bricks fill whole sets
+// of its layout and are on connected peers.
+func expandChecks(ctx context.Context, req VolumeExpandRequest) (parser.Volume, []PreCheck, error) {
+	volumes, e := volumesInfo(ctx, req.Volname)
+	if e != nil {
+		return parser.Volume{}, nil, e
+	}
+	if len(volumes) == 0 {
+		return parser.Volume{}, nil, NewError(http.StatusNotFound, CodeVolumeNotFound, "volume %s does not exist", req.Volname)
+	}
+	layout, e := volumeLayout(volumes[0])
+	if e != nil {
+		return parser.Volume{}, nil, e
+	}
+	if e := layout.CheckBricks("bricks", req.Bricks, req.Force == "true"); e != nil {
+		return parser.Volume{}, nil, e
+	}
+	checks := []PreCheck{{Check: "layout", Result: "OK", Message: fmt.Sprintf("%d %s set(s) of %d", len(req.Bricks)/layout.SetSize, layout.Type, layout.SetSize)}}
+
+	// with force a brick directory in use is accepted, as add-brick force does
+	for _, brick := range req.Bricks {
+		brickChecks, e := checkNewBrick(ctx, brick, req.Force != "true")
+		if e != nil {
+			return parser.Volume{}, nil, e
+		}
+		checks = append(checks, brickChecks...)
+	}
+	return volumes[0], checks, nil
+}
+
+/*
+[example]
+docker exec glusterfs sh -c "gluster volume add-brick test node4:/data/test node5:/data/test node6:/data/test <<< y"
+docker exec glusterfs gluster volume rebalance test start --xml
+
+curl -X POST http://127.0.0.1:7030/gluster/volume/expand -H 'Content-Type: application/json' -d '{
+"volname": "test",
+"bricks": ["node4:/data/test", "node5:/data/test", "node6:/data/test"],
+"rebalance": "full"
+}'
+<- {"result":"OK","job_id":"9f2c6a1e0b7d4c35","checks":[{"check":"layout","result":"OK","message":"1 replicate set(s) of 3"},{"check":"peer connected","result":"OK","message":"node4"},...]}
+<- {"result":"ERROR","error":{"status":400,"code":"invalid_argument","field":"bricks","message":"brick count must be a multiple of the replicate set size 3",...}}
+
+curl -X GET http://127.0.0.1:7030/gluster/jobs/9f2c6a1e0b7d4c35
+<- {"result":"OK","job":{"id":"9f2c6a1e0b7d4c35","op":"expand","volname":"test","state":"running","progress":{"status":"in progress","files":1200,...,
+"steps":[{"name":"add bricks","state":"done",...},{"name":"rebalance","state":"running",...}]}}}
+*/
+func ProcessVolumeExpand(w http.ResponseWriter, r *http.Request) {
+	var rsp VolumeExpandResponse
+	defer writeResponse(w, &rsp)
+
+	// analyze request
+	var req VolumeExpandRequest
+	if e := readRequest(r, &req); e != nil {
+		L.Gluster.Error(e.Error())
+		rsp.Fail(e)
+		return
+	}
+	if req.Rebalance == "" {
+		req.Rebalance = "full"
+	}
+
+	release, e := lockForRequest(r, volumeLock(req.Volname), "expand")
+	if e != nil {
+		L.Gluster.Error(e.Error())
+		rsp.Fail(e)
+		return
+	}
+	volume, checks, e := expandChecks(r.Context(), req)
+	if e != nil {
+		release()
+		L.Gluster.Error(e.Error())
+		rsp.Fail(e)
+		return
+	}
+	rsp.Checks = checks
+
+	job := jobs.Start("expand", req.Volname, locked(release, expandJob(req, volume)))
+	rsp.JobID = job.ID
+	rsp.Result = "OK"
+}
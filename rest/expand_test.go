@@ -0,0 +1,169 @@
+package gluster
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestVolumeExpand(t *testing.T) {
+	tests := []struct {
+		name   string
+		body   string
+		dir    string // what the new brick directories hold
+		status int
+		field  string // the invalid field
+		code   string
+		checks int
+		want   []string // commands of the job
+	}{
+		{
+			name:   "full rebalance",
+			body:   `{"volname": "test", "bricks": ["127.0.0.1:/data/brick2/test", "node1:/data/brick2/test"]}`,
+			status: http.StatusOK,
+			checks: 5,
+			want: []string{
+				"gluster volume add-brick test 127.0.0.1:/data/brick2/test node1:/data/brick2/test",
+				"gluster volume rebalance test start --xml",
+				"gluster volume rebalance test status --xml",
+			},
+		},
+		{
+			name:   "fix-layout",
+			body:   `{"volname": "test", "bricks": ["127.0.0.1:/data/brick2/test", "node1:/data/brick2/test"], "rebalance": "fix-layout"}`,
+			status: http.StatusOK,
+			checks: 5,
+			want: []string{
+				"gluster volume add-brick test 127.0.0.1:/data/brick2/test node1:/data/brick2/test",
+				"gluster volume rebalance test fix-layout start --xml",
+				"gluster volume rebalance test status --xml",
+			},
+		},
+		{
+			name:   "forced over used directories",
+			body:   `{"volname": "test", "bricks": ["127.0.0.1:/data/brick2/test", "node1:/data/brick2/test"], "force": "true"}`,
+			dir:    "a.txt\n",
+			status: http.StatusOK,
+			checks: 3,
+			want: []string{
+				"gluster volume add-brick test 127.0.0.1:/data/brick2/test node1:/data/brick2/test force",
+				"gluster volume rebalance test start --xml",
+				"gluster volume rebalance test status --xml",
+			},
+		},
+		{
+			name:   "partial set",
+			body:   `{"volname": "test", "bricks": ["127.0.0.1:/data/brick2/test"]}`,
+			status: http.StatusBadRequest,
+			field:  "bricks",
+			code:   CodeInvalidArgument,
+		},
+		{
+			name:   "set on one host",
+			body:   `{"volname": "test", "bricks": ["node1:/data/brick2/test", "node1:/data/brick3/test"]}`,
+			status: http.StatusBadRequest,
+			field:  "bricks[1]",
+			code:   CodeInvalidArgument,
+		},
+		{
+			name:   "used directory",
+			body:   `{"volname": "test", "bricks": ["127.0.0.1:/data/brick2/test", "node1:/data/brick2/test"]}`,
+			dir:    "a.txt\n",
+			status: http.StatusConflict,
+			code:   CodeBrickNotEmpty,
+		},
+		{
+			name:   "disconnected peer",
+			body:   `{"volname": "test", "bricks": ["127.0.0.1:/data/brick2/test", "node2:/data/brick2/test"]}`,
+			status: http.StatusServiceUnavailable,
+			code:   CodePeerNotConnected,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			peerService(t)
+			f := fakeBrickVolume(t, test.dir).
+				On(`<cliOutput><opRet>0</opRet></cliOutput>`, nil, "gluster", "volume", "rebalance", "test", "start").
+				On(`<cliOutput><opRet>0</opRet></cliOutput>`, nil, "gluster", "volume", "rebalance", "test", "fix-layout").
+				On(rebalanceStatusXML("completed"), nil, "gluster", "volume", "rebalance", "test", "status")
+			var rsp VolumeExpandResponse
+			if status := serve(t, ProcessVolumeExpand, test.body, &rsp); status != test.status {
+				t.Fatalf("status %d, want %d: %+v", status, test.status, rsp.Error)
+			}
+			if test.code != "" {
+				if rsp.Error == nil || rsp.Error.Code != test.code || rsp.Error.Field != test.field {
+					t.Errorf("got %+v, want %s on %q", rsp.Error, test.code, test.field)
+				}
+				for _, call := range f.Invocations() {
+					if strings.HasPrefix(call, "gluster volume add-brick") {
+						t.Errorf("ran %q after a failed check", call)
+					}
+				}
+				return
+			}
+			if len(rsp.Checks) != test.checks {
+				t.Errorf("checks = %+v", rsp.Checks)
+			}
+			if job := waitJob(t, rsp.JobID); job.State != JobSucceeded {
+				t.Errorf("job = %+v", job)
+			}
+			var got []string
+			for _, c := range f.Calls {
+				if call := strings.Join(append([]string{c.Name}, c.Args...), " "); strings.HasPrefix(call, "gluster volume add-brick") || strings.HasPrefix(call, "gluster volume rebalance") {
+					got = append(got, call)
+					if c.Args[1] == "add-brick" && c.Stdin != "y\n" {
+						t.Errorf("add-brick stdin %q", c.Stdin)
+					}
+				}
+			}
+			if strings.Join(got, "\n") != strings.Join(test.want, "\n") {
+				t.Errorf("ran %q, want %q", got, test.want)
+			}
+		})
+	}
+}
+
+// TestVolumeExpandJob checks a stopped volume is left to rebalance once
+// started and a failed rebalance fails the operation.
+func TestVolumeExpandJob(t *testing.T) {
+	tests := []struct {
+		name       string
+		volumeInfo string
+		rebalance  string
+		state      JobState
+		step       StepState
+	}{
+		{"stopped volume", strings.Replace(volumeInfoXML("test", "2", "node1:/data/brick1/test", "node2:/data/brick1/test"),
+			"<status>1</status><statusStr>Started</statusStr>", "<status>2</status><statusStr>Stopped</statusStr>", 1), "", JobSucceeded, StepSkipped},
+		{"rebalance failed", volumeInfoXML("test", "2", "node1:/data/brick1/test", "node2:/data/brick1/test"), "failed", JobFailed, StepFailed},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			peerService(t)
+			f := fakeBrickVolume(t, "").
+				On(test.volumeInfo, nil, "gluster", "volume", "info").
+				On(`<cliOutput><opRet>0</opRet></cliOutput>`, nil, "gluster", "volume", "rebalance", "test", "start").
+				On(rebalanceStatusXML(test.rebalance), nil, "gluster", "volume", "rebalance", "test", "status")
+			var rsp VolumeExpandResponse
+			if status := serve(t, ProcessVolumeExpand, `{"volname": "test", "bricks": ["127.0.0.1:/data/brick2/test", "node1:/data/brick2/test"]}`, &rsp); status != http.StatusOK {
+				t.Fatalf("status %d: %+v", status, rsp.Error)
+			}
+			job := waitJob(t, rsp.JobID)
+			if job.State != test.state {
+				t.Errorf("job = %+v", job)
+			}
+			for _, step := range job.Progress.Steps {
+				if step.Name == stepAddBricks && step.State != StepDone || step.Name == stepRebalance && step.State != test.step {
+					t.Errorf("step %+v", step)
+				}
+			}
+			rebalanced := false
+			for _, call := range f.Invocations() {
+				rebalanced = rebalanced || strings.HasPrefix(call, "gluster volume rebalance")
+			}
+			if rebalanced != (test.rebalance != "") {
+				t.Errorf("ran %q", f.Invocations())
+			}
+		})
+	}
+}
@@ -0,0 +1,118 @@
+package gluster
+
+import (
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// errExit is how a failed gluster command returns.
+var errExit = errors.New("exit status 1")
+
+// fakeGluster replaces the package runner with a FakeRunner for the test.
+func fakeGluster(t *testing.T) *FakeRunner {
+	t.Helper()
+	f := NewFakeRunner()
+	previous := runner
+	SetRunner(f)
+	t.Cleanup(func() { SetRunner(previous) })
+	return f
+}
+
+// serve posts body to handler and decodes its response into rsp.
+func serve(t *testing.T, handler http.HandlerFunc, body string, rsp interface{}) int {
+	t.Helper()
+	w := httptest.NewRecorder()
+	handler(w, httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body)))
+	decode(t, w, rsp)
+	return w.Code
+}
+
+// decode decodes the response recorded by w into rsp.
+func decode(t *testing.T, w *httptest.ResponseRecorder, rsp interface{}) {
+	t.Helper()
+	if e := json.Unmarshal(w.Body.Bytes(), rsp); e != nil {
+		t.Fatalf("decode %s: %s", w.Body.String(), e)
+	}
+}
+
+// waitJob returns job id once it finished.
+func waitJob(t *testing.T, id string) Job {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		job, ok := jobs.Get(id)
+		if !ok {
+			t.Fatalf("job %s not found", id)
+		}
+		if job.State != JobRunning {
+			return job
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("job %s still running", id)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+// checkCalls compares the commands f ran with want.
+func checkCalls(t *testing.T, f *FakeRunner, want []string) {
+	t.Helper()
+	got := f.Invocations()
+	if len(got) != len(want) {
+		t.Fatalf("ran %q, want %q", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("command %d = %q, want %q", i, got[i], want[i])
+		}
+	}
+}
+
+// volumeInfoXML is `volume info <name> --xml` of a started volume with the
+// given replica count and bricks, the uuid of a brick host is its name.
+func volumeInfoXML(name, replica string, bricks ...string) string {
+	var b strings.Builder
+	b.WriteString(`<cliOutput><opRet>0</opRet><opErrno>0</opErrno><opErrstr/><volInfo><volumes><volume>`)
+	b.WriteString(`<name>` + name + `</name><status>1</status><statusStr>Started</statusStr>`)
+	b.WriteString(`<replicaCount>` + replica + `</replicaCount><distCount>` + replica + `</distCount><bricks>`)
+	for _, brick := range bricks {
+		b.WriteString(`<brick><name>` + brick + `</name><hostUuid>` + strings.Split(brick, ":")[0] + `</hostUuid><isArbiter>0</isArbiter></brick>`)
+	}
+	b.WriteString(`</bricks></volume><count>1</count></volumes></volInfo></cliOutput>`)
+	return b.String()
+}
+
+// volumeStatusXML is `volume status test detail --xml` listing the online
+// bricks, bricks left out are offline.
+func volumeStatusXML(online ...string) string {
+	var b strings.Builder
+	b.WriteString(`<cliOutput><opRet>0</opRet><opErrno>0</opErrno><opErrstr/><volStatus><volumes><volume><volName>test</volName>`)
+	for _, brick := range online {
+		i := strings.LastIndex(brick, ":")
+		b.WriteString(`<node><hostname>` + brick[:i] + `</hostname><path>` + brick[i+1:] + `</path><status>1</status></node>`)
+	}
+	b.WriteString(`</volume></volumes></volStatus></cliOutput>`)
+	return b.String()
+}
+
+// peerService serves the peer endpoints on 127.0.0.1 as the gluster-rest
+// service of another node, running its commands on the same fake runner.
+func peerService(t *testing.T) string {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/gluster/brick/path/check", ProcessBrickPathCheck)
+	mux.HandleFunc("/gluster/brick/thin/check", ProcessBrickThinCheck)
+	mux.HandleFunc("/gluster/brick/device/prepare", ProcessBrickDevicePrepare)
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+	host, port, _ := net.SplitHostPort(server.Listener.Addr().String())
+	previous := ServicePort
+	ServicePort = port
+	t.Cleanup(func() { ServicePort = previous })
+	return host
+}
@@ -0,0 +1,485 @@
+package gluster
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"hualu.com/gluster-rest/parser"
+	L "hualu.com/logger"
+)
+
+// VolumeHealOptionsRequest tunes the self-heal daemon, empty fields are left
+// unchanged.
+type VolumeHealOptionsRequest struct {
+	CommonVolumeRequest
+	WindowSize  string `json:"self_heal_window_size"` // 128KB blocks healed at once per file, 1-1024
+	HealTimeout string `json:"heal_timeout"`          // seconds between index crawls, >= 5
+	MaxThreads  string `json:"shd_max_threads"`       // files healed in parallel per brick, 1-64
+}
+
+type VolumeHealInfoResponse struct {
+	CommonResponse
+	Bricks  []parser.HealBrick `json:"bricks"`
+	Entries int                `json:"entries"` // sum over the bricks, a copy needing heal is counted on every brick reporting it
+}
+
+// healableVolume returns the volume after checking the self-heal daemon serves
+// it, pure distribute volumes have nothing to heal.
+func healableVolume(ctx context.Context, volname string) (parser.Volume, error) {
+	volumes, e := volumesInfo(ctx, volname)
+	if e != nil {
+		return parser.Volume{}, e
+	}
+	if len(volumes) == 0 {
+		return parser.Volume{}, NewError(http.StatusNotFound, CodeVolumeNotFound, "volume %s does not exist", volname)
+	}
+	if !volumes[0].Healable() {
+		return parser.Volume{}, NewError(http.StatusBadRequest, CodeNotSupported,
+			"volume %s is %s, self-heal needs a replicate or disperse volume", volname, volumes[0].Type)
+	}
+	return volumes[0], nil
+}
+
+// healInfo returns `volume heal <vol> info [summary|split-brain] --xml`.
+func healInfo(ctx context.Context, volname string, mode ...string) ([]parser.HealBrick, error) {
+	args := append([]string{"volume", "heal", volname, "info"}, mode...)
+	output, e := runGluster(ctx, append(args, "--xml")...)
+	if e != nil {
+		L.Gluster.Error(string(output))
+		return nil, glusterError(output, e)
+	}
+	bricks, e := parser.ParseHealInfo(output)
+	if e != nil {
+		return nil, glusterError(output, e)
+	}
+	return bricks, nil
+}
+
+func processHealInfo(w http.ResponseWriter, r *http.Request, mode ...string) {
+	var rsp VolumeHealInfoResponse
+	defer writeResponse(w, &rsp)
+
+	// analyze request
+	var req CommonVolumeRequest
+	if e := readRequest(r, &req); e != nil {
+		L.Gluster.Error(e.Error())
+		rsp.Fail(e)
+		return
+	}
+
+	if _, e := healableVolume(r.Context(), req.Volname); e != nil {
+		L.Gluster.Error(e.Error())
+		rsp.Fail(e)
+		return
+	}
+	bricks, e := healInfo(r.Context(), req.Volname, mode...)
+	if e != nil {
+		L.Gluster.Error(e.Error())
+		rsp.Fail(e)
+		return
+	}
+	for _, brick := range bricks {
+		rsp.Entries += brick.Entries
+	}
+	rsp.Bricks = bricks
+	rsp.Result = "OK"
+}
+
+/*
+[example]
+docker exec glusterfs gluster volume heal test info --xml
+
+curl -X POST http://127.0.0.1:7030/gluster/volume/heal/info -H 'Content-Type: application/json' -d '{
+"volname": "test"
+}'
+<- {"result":"OK","bricks":[{"name":"node1:/data/brick1/test","status":"Connected","connected":true,"entries":2,...,"files":[{"gfid":"8f0b1c3e-...","path":"/dir/a.txt"},{"gfid":"1a2b3c4d-..."}]},...],"entries":2}
+*/
+func ProcessVolumeHealInfo(w http.ResponseWriter, r *http.Request) {
+	processHealInfo(w, r)
+}
+
+/*
+[example]
+docker exec glusterfs gluster volume heal test info summary --xml
+
+curl -X POST http://127.0.0.1:7030/gluster/volume/heal/info/summary -H 'Content-Type: application/json' -d '{
+"volname": "test"
+}'
+<- {"result":"OK","bricks":[{"name":"node1:/data/brick1/test","status":"Connected","connected":true,"entries":3,"heal_pending":2,"split_brain":1,"possibly_healing":0},...],"entries":3}
+*/
+func ProcessVolumeHealInfoSummary(w http.ResponseWriter, r *http.Request) {
+	processHealInfo(w, r, "summary")
+}
+
+/*
+[example]
+docker exec glusterfs gluster volume heal test info split-brain --xml
+
+curl -X POST http://127.0.0.1:7030/gluster/volume/heal/info/split-brain -H 'Content-Type: application/json' -d '{
+"volname": "test"
+}'
+<- {"result":"OK","bricks":[{"name":"node1:/data/brick1/test","status":"Connected","connected":true,"entries":1,...,"files":[{"gfid":"8f0b1c3e-...","path":"/dir/a.txt"}]},...],"entries":1}
+*/
+func ProcessVolumeHealInfoSplitBrain(w http.ResponseWriter, r *http.Request) {
+	processHealInfo(w, r, "split-brain")
+}
+
+// healJob launches `volume heal <vol> [full]` and follows it until no entries
+// are pending on any brick. The volume lock is only held while launching.
+func healJob(volname string, args []string, release func()) JobFunc {
+	return func(ctx context.Context, update func(JobProgress)) (string, error) {
+		before := healCrawlsBefore(ctx, volname)
+		output, e := runGluster(ctx, append([]string{"volume", "heal", volname}, args...)...)
+		release()
+		if e != nil {
+			L.Gluster.Error(string(output))
+			return string(output), glusterError(output, e)
+		}
+		L.Gluster.Debug(string(output))
+		return string(output), waitHealed(ctx, volname, before, update)
+	}
+}
+
+// healSettle is how many zero readings in a row stand for healed when the
+// volume reports no crawl statistics, e.g. disperse.
+const healSettle = 3
+
+// errNoStatistics is returned by healCrawled when the volume lists no crawls.
+var errNoStatistics = errors.New("no crawl statistics")
+
+// crawlKey identifies a crawl across statistics readings. The start time is
+// the wall clock of the brick's node, it is only compared for equality.
+func crawlKey(crawl parser.HealCrawl) string {
+	return fmt.Sprintf("%d %s %s %s", crawl.Brick, crawl.Host, crawl.Type, crawl.Start.Format(time.ANSIC))
+}
+
+// healCrawlsBefore records the crawls listed before a heal is launched, see
+// healCrawled. Without statistics it returns nil.
+func healCrawlsBefore(ctx context.Context, volname string) map[string]bool {
+	crawls, e := healCrawls(ctx, volname)
+	if e != nil {
+		L.Gluster.Debug("heal statistics: " + e.Error())
+		return nil
+	}
+	before := make(map[string]bool, len(crawls))
+	for _, crawl := range crawls {
+		before[crawlKey(crawl)] = true
+	}
+	return before
+}
+
+// waitHealed polls the heal summary until no entries are pending on any brick.
+// Right after a heal is launched the counters may not be filled yet, so zero
+// only counts once entries were seen pending, or once every brick finished a
+// crawl not among the crawls listed before the launch. Volumes without
+// statistics settle after healSettle zero readings.
+func waitHealed(ctx context.Context, volname string, before map[string]bool, update func(JobProgress)) error {
+	seen, zeros := false, 0
+	for {
+		if e := sleepContext(ctx, pollInterval); e != nil {
+			return e
+		}
+		bricks, e := healInfo(ctx, volname, "summary")
+		if e != nil {
+			return e
+		}
+		// an unreachable brick reports no entries, it may still need heal
+		pending, unreachable := 0, 0
+		for _, brick := range bricks {
+			pending += brick.Entries
+			if !brick.Connected {
+				unreachable++
+			}
+		}
+		status := "healing"
+		if unreachable > 0 {
+			status = "waiting for unreachable bricks"
+		}
+		if pending > 0 {
+			seen, zeros = true, 0
+		}
+		if pending == 0 && unreachable == 0 && !seen {
+			zeros++
+			crawled, e := healCrawled(ctx, volname, before)
+			if e != nil {
+				L.Gluster.Debug("heal statistics: " + e.Error())
+				crawled = zeros >= healSettle
+			}
+			if !crawled {
+				status = "waiting for the heal crawl"
+			}
+			seen = crawled
+		}
+		update(JobProgress{Status: status, Pending: pending})
+		if pending == 0 && unreachable == 0 && seen {
+			return nil
+		}
+	}
+}
+
+// healCrawls returns `volume heal <vol> statistics`, errNoStatistics when it
+// lists no crawl.
+func healCrawls(ctx context.Context, volname string) ([]parser.HealCrawl, error) {
+	output, e := runGluster(ctx, "volume", "heal", volname, "statistics")
+	if e != nil {
+		return nil, glusterError(output, e)
+	}
+	crawls := parser.ParseHealStatistics(output)
+	if len(crawls) == 0 {
+		return nil, errNoStatistics
+	}
+	return crawls, nil
+}
+
+// healCrawled reports whether every brick listed by `volume heal <vol>
+// statistics` finished a crawl that is not in before. Without the crawls of
+// before the launch an old crawl cannot be told from a new one, so it returns
+// errNoStatistics too.
+func healCrawled(ctx context.Context, volname string, before map[string]bool) (bool, error) {
+	if before == nil {
+		return false, errNoStatistics
+	}
+	crawls, e := healCrawls(ctx, volname)
+	if e != nil {
+		return false, e
+	}
+	done := make(map[int]bool)
+	for _, crawl := range crawls {
+		if _, ok := done[crawl.Brick]; !ok {
+			done[crawl.Brick] = false
+		}
+		if !crawl.InProgress && !before[crawlKey(crawl)] {
+			done[crawl.Brick] = true
+		}
+	}
+	for _, ok := range done {
+		if !ok {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// startHeal checks the volume and starts a heal job under its lock.
+func startHeal(r *http.Request, volname, op string, args ...string) (Job, error) {
+	if _, e := healableVolume(r.Context(), volname); e != nil {
+		return Job{}, e
+	}
+	release, e := lockForRequest(r, volumeLock(volname), op)
+	if e != nil {
+		return Job{}, e
+	}
+	return jobs.Start(op, volname, healJob(volname, args, release)), nil
+}
+
+/*
+[example]
+docker exec glusterfs gluster volume heal test
+
+curl -X POST http://127.0.0.1:7030/gluster/volume/heal/index -H 'Content-Type: application/json' -d '{
+"volname": "test"
+}'
+<- {"result":"OK","job_id":"9f2c6a1e0b7d4c35"}
+*/
+func ProcessVolumeHealIndex(w http.ResponseWriter, r *http.Request) {
+	var rsp CommonVolumeResponse
+	defer writeResponse(w, &rsp)
+
+	// analyze request
+	var req CommonVolumeRequest
+	if e := readRequest(r, &req); e != nil {
+		L.Gluster.Error(e.Error())
+		rsp.Fail(e)
+		return
+	}
+
+	job, e := startHeal(r, req.Volname, "heal-index")
+	if e != nil {
+		L.Gluster.Error(e.Error())
+		rsp.Fail(e)
+		return
+	}
+	rsp.JobID = job.ID
+	rsp.Result = "OK"
+}
+
+/*
+[example]
+docker exec glusterfs gluster volume heal test full
+
+curl -X POST http://127.0.0.1:7030/gluster/volume/heal/full -H 'Content-Type: application/json' -d '{
+"volname": "test"
+}'
+<- {"result":"OK","job_id":"9f2c6a1e0b7d4c35"}
+*/
+func ProcessVolumeHealFull(w http.ResponseWriter, r *http.Request) {
+	var rsp CommonVolumeResponse
+	defer writeResponse(w, &rsp)
+
+	// analyze request
+	var req CommonVolumeRequest
+	if e := readRequest(r, &req); e != nil {
+		L.Gluster.Error(e.Error())
+		rsp.Fail(e)
+		return
+	}
+
+	job, e := startHeal(r, req.Volname, "heal-full", "full")
+	if e != nil {
+		L.Gluster.Error(e.Error())
+		rsp.Fail(e)
+		return
+	}
+	rsp.JobID = job.ID
+	rsp.Result = "OK"
+}
+
+// ProcessVolumeHealth is /gluster/volume/health, kept for existing clients of
+// /gluster/volume/heal/full.
+func ProcessVolumeHealth(w http.ResponseWriter, r *http.Request) {
+	ProcessVolumeHealFull(w, r)
+}
+
+// healDaemon runs `volume heal <vol> enable|disable`.
+func healDaemon(w http.ResponseWriter, r *http.Request, op string) {
+	var rsp CommonVolumeResponse
+	defer writeResponse(w, &rsp)
+
+	// analyze request
+	var req CommonVolumeRequest
+	if e := readRequest(r, &req); e != nil {
+		L.Gluster.Error(e.Error())
+		rsp.Fail(e)
+		return
+	}
+
+	if _, e := healableVolume(r.Context(), req.Volname); e != nil {
+		L.Gluster.Error(e.Error())
+		rsp.Fail(e)
+		return
+	}
+	release, e := lockForRequest(r, volumeLock(req.Volname), "heal "+op)
+	if e != nil {
+		L.Gluster.Error(e.Error())
+		rsp.Fail(e)
+		return
+	}
+	defer release()
+
+	output, e := runGluster(r.Context(), "volume", "heal", req.Volname, op)
+	if e != nil {
+		L.Gluster.Error(string(output))
+		rsp.Fail(glusterError(output, e))
+		return
+	}
+	rsp.Result = "OK"
+}
+
+/*
+[example]
+docker exec glusterfs gluster volume heal test enable
+
+curl -X POST http://127.0.0.1:7030/gluster/volume/heal/enable -H 'Content-Type: application/json' -d '{
+"volname": "test"
+}'
+<- {"result":"OK"}
+*/
+func ProcessVolumeHealEnable(w http.ResponseWriter, r *http.Request) {
+	healDaemon(w, r, "enable")
+}
+
+/*
+[example]
+docker exec glusterfs gluster volume heal test disable
+
+curl -X POST http://127.0.0.1:7030/gluster/volume/heal/disable -H 'Content-Type: application/json' -d '{
+"volname": "test"
+}'
+<- {"result":"OK"}
+*/
+func ProcessVolumeHealDisable(w http.ResponseWriter, r *http.Request) {
+	healDaemon(w, r, "disable")
+}
+
+// healOptionKeys maps the fields of VolumeHealOptionsRequest to the options
+// of the translator healing volume.
+func healOptionKeys(volume parser.Volume) (window, timeout, threads string) {
+	prefix := "cluster."
+	if volume.DisperseCount > 0 {
+		prefix = "disperse."
+	}
+	return prefix + "self-heal-window-size", "cluster.heal-timeout", prefix + "shd-max-threads"
+}
+
+/*
+[example]
+docker exec glusterfs gluster volume set test cluster.shd-max-threads 4
+
+curl -X POST http://127.0.0.1:7030/gluster/volume/heal/options -H 'Content-Type: application/json' -d '{
+"volname": "test",
+"shd_max_threads": "4"
+}'
+<- {"result":"OK","options":[{"name":"cluster.shd-max-threads","value":"4","default":"1","result":"OK"},
+{"name":"cluster.self-heal-window-size","value":"1","default":"1"},{"name":"cluster.heal-timeout","value":"600","default":"600"}]}
+*/
+func ProcessVolumeHealOptions(w http.ResponseWriter, r *http.Request) {
+	var rsp VolumeOptionResponse
+	defer writeResponse(w, &rsp)
+
+	// analyze request
+	var req VolumeHealOptionsRequest
+	if e := readRequest(r, &req); e != nil {
+		L.Gluster.Error(e.Error())
+		rsp.Fail(e)
+		return
+	}
+
+	volume, e := healableVolume(r.Context(), req.Volname)
+	if e != nil {
+		L.Gluster.Error(e.Error())
+		rsp.Fail(e)
+		return
+	}
+	window, timeout, threads := healOptionKeys(volume)
+
+	release, e := lockForRequest(r, volumeLock(req.Volname), "volume set")
+	if e != nil {
+		L.Gluster.Error(e.Error())
+		rsp.Fail(e)
+		return
+	}
+	defer release()
+
+	// set the given options, describe all three
+	var results []OptionResult
+	var keys []string
+	for _, option := range []Option{{window, req.WindowSize}, {timeout, req.HealTimeout}, {threads, req.MaxThreads}} {
+		if option.Value == "" {
+			keys = append(keys, option.Name)
+			continue
+		}
+		result := OptionResult{Name: option.Name, Result: "OK"}
+		output, e := runGluster(r.Context(), "volume", "set", req.Volname, option.Name, option.Value)
+		if e != nil {
+			L.Gluster.Error(string(output))
+			result.Result = "ERROR"
+			result.Error = glusterError(output, e)
+		}
+		results = append(results, result)
+	}
+
+	rsp.Options, e = describeOptions(r.Context(), req.Volname, results, keys)
+	if e != nil {
+		L.Gluster.Error(e.Error())
+		rsp.Fail(e)
+		return
+	}
+	if e := optionsError(rsp.Options); e != nil {
+		rsp.Fail(e)
+		return
+	}
+	rsp.Result = "OK"
+}
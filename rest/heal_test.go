@@ -0,0 +1,160 @@
+package gluster
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+// healSummaryXML is `volume heal test info summary --xml` with entries
+// pending on node1 and node2, node3 unreachable.
+const healSummaryXML = `<cliOutput><healInfo><bricks>` +
+	`<brick hostUuid="node1"><name>node1:/data/brick1/test</name><status>Connected</status><totalNumberOfEntries>3</totalNumberOfEntries>` +
+	`<numberOfEntriesInHealPending>2</numberOfEntriesInHealPending><numberOfEntriesInSplitBrain>1</numberOfEntriesInSplitBrain><numberOfEntriesPossiblyHealing>0</numberOfEntriesPossiblyHealing></brick>` +
+	`<brick hostUuid="node2"><name>node2:/data/brick1/test</name><status>Connected</status><totalNumberOfEntries>2</totalNumberOfEntries>` +
+	`<numberOfEntriesInHealPending>2</numberOfEntriesInHealPending><numberOfEntriesInSplitBrain>0</numberOfEntriesInSplitBrain><numberOfEntriesPossiblyHealing>0</numberOfEntriesPossiblyHealing></brick>` +
+	`<brick hostUuid="node3"><name>node3:/data/brick1/test</name><status>Transport endpoint is not connected</status><totalNumberOfEntries>-</totalNumberOfEntries></brick>` +
+	`</bricks></healInfo><opRet>0</opRet><opErrno>0</opErrno><opErrstr/></cliOutput>`
+
+func TestVolumeHealInfo(t *testing.T) {
+	tests := []struct {
+		name    string
+		handler http.HandlerFunc
+		want    string
+	}{
+		{"info", ProcessVolumeHealInfo, "gluster volume heal test info --xml"},
+		{"summary", ProcessVolumeHealInfoSummary, "gluster volume heal test info summary --xml"},
+		{"split-brain", ProcessVolumeHealInfoSplitBrain, "gluster volume heal test info split-brain --xml"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			f := fakeGluster(t).
+				On(volumeInfoXML("test", "3", "node1:/data/brick1/test", "node2:/data/brick1/test", "node3:/data/brick1/test"), nil, "gluster", "volume", "info").
+				On(healSummaryXML, nil, "gluster", "volume", "heal", "test", "info")
+			var rsp VolumeHealInfoResponse
+			if status := serve(t, test.handler, `{"volname": "test"}`, &rsp); status != http.StatusOK {
+				t.Fatalf("status %d: %+v", status, rsp.Error)
+			}
+			if rsp.Entries != 5 || len(rsp.Bricks) != 3 || rsp.Bricks[2].Connected {
+				t.Errorf("entries %d, bricks %+v", rsp.Entries, rsp.Bricks)
+			}
+			checkCalls(t, f, []string{"gluster volume info test --xml", test.want})
+		})
+	}
+}
+
+// healZeroXML is `volume heal test info summary --xml` with nothing pending.
+const healZeroXML = `<cliOutput><healInfo><bricks>` +
+	`<brick hostUuid="node1"><name>node1:/data/brick1/test</name><status>Connected</status><totalNumberOfEntries>0</totalNumberOfEntries></brick>` +
+	`<brick hostUuid="node2"><name>node2:/data/brick1/test</name><status>Connected</status><totalNumberOfEntries>0</totalNumberOfEntries></brick>` +
+	`</bricks></healInfo><opRet>0</opRet><opErrno>0</opErrno><opErrstr/></cliOutput>`
+
+// healStatistics is `volume heal test statistics` where brick i finished one
+// crawl started at starts[i].
+func healStatistics(starts ...string) string {
+	var b strings.Builder
+	b.WriteString("Gathering crawl statistics on volume test has been successful\n")
+	for i, start := range starts {
+		fmt.Fprintf(&b, "\nCrawl statistics for brick no %d\nHostname of brick node%d\n\n", i, i+1)
+		fmt.Fprintf(&b, "Starting time of crawl: %s\n\nEnding time of crawl: %s\n\nType of crawl: INDEX\n", start, start)
+		b.WriteString("No. of entries healed: 0\nNo. of entries in split-brain: 0\nNo. of heal failed entries: 0\n")
+	}
+	return b.String()
+}
+
+func TestWaitHealed(t *testing.T) {
+	old := healStatistics("Thu Apr 11 08:25:34 2019", "Thu Apr 11 08:25:36 2019")
+	// node2 keeps a clock far behind, its new crawl looks older than node1's
+	crawled := healStatistics("Thu Apr 11 08:35:34 2019", "Mon Apr  1 06:00:00 2019")
+	tests := []struct {
+		name    string
+		before  string // statistics at launch
+		after   string // statistics from the third poll on
+		summary string
+		polls   int
+	}{
+		{"crawled since the launch", old, crawled, healZeroXML, 3},
+		{"no statistics", "", "", healZeroXML, healSettle},
+		{"no statistics at launch", "", crawled, healZeroXML, healSettle},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			fastPoll(t)
+			f := fakeGluster(t).
+				On(test.summary, nil, "gluster", "volume", "heal", "test", "info", "summary").
+				On(test.before, nil, "gluster", "volume", "heal", "test", "statistics")
+			before := healCrawlsBefore(context.Background(), "test")
+
+			polls := 0
+			e := waitHealed(context.Background(), "test", before, func(p JobProgress) {
+				polls++
+				if polls == 2 {
+					f.On(test.after, nil, "gluster", "volume", "heal", "test", "statistics")
+				}
+				if polls < test.polls && p.Status != "waiting for the heal crawl" {
+					t.Errorf("poll %d: %s", polls, p.Status)
+				}
+			})
+			if e != nil {
+				t.Fatal(e)
+			}
+			if polls != test.polls {
+				t.Errorf("healed after %d polls, want %d", polls, test.polls)
+			}
+		})
+	}
+}
+
+// TestWaitHealedPending checks entries seen pending and then gone need no crawl.
+func TestWaitHealedPending(t *testing.T) {
+	fastPoll(t)
+	f := fakeGluster(t).On(healSummaryXML, nil, "gluster", "volume", "heal", "test", "info", "summary")
+	polls := 0
+	e := waitHealed(context.Background(), "test", nil, func(p JobProgress) {
+		polls++
+		if polls == 1 {
+			if p.Pending != 5 || p.Status != "waiting for unreachable bricks" {
+				t.Errorf("progress = %+v", p)
+			}
+			f.On(healZeroXML, nil, "gluster", "volume", "heal", "test", "info", "summary")
+		}
+	})
+	if e != nil || polls != 2 {
+		t.Errorf("%v after %d polls", e, polls)
+	}
+}
+
+func TestVolumeHealIndex(t *testing.T) {
+	bricks := []string{"node1:/data/brick1/test", "node2:/data/brick1/test", "node3:/data/brick1/test"}
+	disperse := strings.Replace(volumeInfoXML("test", "3", bricks...), "<replicaCount>3</replicaCount>",
+		"<replicaCount>1</replicaCount><disperseCount>3</disperseCount><redundancyCount>1</redundancyCount>", 1)
+
+	fastPoll(t)
+	f := fakeGluster(t).
+		On(volumeInfoXML("test", "1", bricks...), nil, "gluster", "volume", "info").
+		On(healZeroXML, nil, "gluster", "volume", "heal", "test", "info", "summary")
+	var rsp CommonVolumeResponse
+	if status := serve(t, ProcessVolumeHealIndex, `{"volname": "test"}`, &rsp); status != http.StatusBadRequest || rsp.Error == nil || rsp.Error.Code != CodeNotSupported {
+		t.Errorf("distribute: status %d, %+v", status, rsp.Error)
+	}
+	checkCalls(t, f, []string{"gluster volume info test --xml"})
+
+	// disperse volumes list no crawls, the heal settles on zero readings
+	f = fakeGluster(t).
+		On(disperse, nil, "gluster", "volume", "info").
+		On(healZeroXML, nil, "gluster", "volume", "heal", "test", "info", "summary")
+	rsp = CommonVolumeResponse{}
+	if status := serve(t, ProcessVolumeHealIndex, `{"volname": "test"}`, &rsp); status != http.StatusOK {
+		t.Fatalf("disperse: status %d, %+v", status, rsp.Error)
+	}
+	if job := waitJob(t, rsp.JobID); job.State != JobSucceeded {
+		t.Errorf("job = %+v", job)
+	}
+	want := []string{"gluster volume info test --xml", "gluster volume heal test statistics", "gluster volume heal test"}
+	for i := 0; i < healSettle; i++ {
+		want = append(want, "gluster volume heal test info summary --xml")
+	}
+	checkCalls(t, f, want)
+}
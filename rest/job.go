@@ -0,0 +1,265 @@
+package gluster
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+	L "hualu.com/logger"
+)
+
+type JobState string
+
+const (
+	JobRunning   JobState = "running"
+	JobSucceeded JobState = "succeeded"
+	JobFailed    JobState = "failed"
+	JobCancelled JobState = "cancelled"
+)
+
+// JobProgress is filled from the rebalance / remove-brick aggregates or heal counters.
+type JobProgress struct {
+	Status     string    `json:"status,omitempty"`
+	Files      int       `json:"files"`
+	Size       int       `json:"size"`
+	Failures   int       `json:"failures"`
+	Skipped    int       `json:"skipped"`
+	Runtime    string    `json:"runtime,omitempty"`
+	NodesDone  int       `json:"nodes_done"`
+	NodesTotal int       `json:"nodes_total"`
+	Pending    int       `json:"pending,omitempty"` // heal entries left
+	Steps      []JobStep `json:"steps,omitempty"`   // jobs chaining several operations
+}
+
+type Job struct {
+	ID        string       `json:"id"`
+	Op        string       `json:"op"`
+	Volname   string       `json:"volname,omitempty"`
+	State     JobState     `json:"state"`
+	Progress  *JobProgress `json:"progress,omitempty"`
+	StartTime time.Time    `json:"start_time"`
+	EndTime   *time.Time   `json:"end_time,omitempty"`
+	Output    string       `json:"output,omitempty"`
+	Error     *Error       `json:"error,omitempty"`
+
+	cancel context.CancelFunc
+}
+
+// JobFunc is the body of a background job. It reports progress through update
+// and must return once ctx is cancelled.
+type JobFunc func(ctx context.Context, update func(JobProgress)) (output string, err error)
+
+// jobsKept bounds how many finished jobs are remembered.
+const jobsKept = 1000
+
+type JobManager struct {
+	mu   sync.Mutex
+	jobs map[string]*Job
+}
+
+func NewJobManager() *JobManager {
+	return &JobManager{jobs: make(map[string]*Job)}
+}
+
+var jobs = NewJobManager()
+
+// Start runs fn in the background and returns the new job immediately.
+func (m *JobManager) Start(op, volname string, fn JobFunc) Job {
+	ctx, cancel := context.WithCancel(context.Background())
+	job := &Job{
+		ID:        newJobID(),
+		Op:        op,
+		Volname:   volname,
+		State:     JobRunning,
+		StartTime: time.Now(),
+		cancel:    cancel,
+	}
+
+	m.mu.Lock()
+	m.prune()
+	m.jobs[job.ID] = job
+	snapshot := *job
+	m.mu.Unlock()
+
+	L.Gluster.Infof("job %s started: %s %s", job.ID, op, volname)
+	go func() {
+		defer cancel()
+		output, e := fn(ctx, func(p JobProgress) {
+			m.mu.Lock()
+			job.Progress = &p
+			m.mu.Unlock()
+		})
+
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		now := time.Now()
+		job.EndTime = &now
+		job.Output = output
+		switch {
+		case ctx.Err() != nil:
+			job.State = JobCancelled
+			if e != nil {
+				job.Error = toError(e)
+			}
+		case e != nil:
+			job.State = JobFailed
+			job.Error = toError(e)
+		default:
+			job.State = JobSucceeded
+		}
+		L.Gluster.Infof("job %s %s", job.ID, job.State)
+	}()
+	return snapshot
+}
+
+func (m *JobManager) Get(id string) (Job, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	job, ok := m.jobs[id]
+	if !ok {
+		return Job{}, false
+	}
+	return *job, true
+}
+
+// List returns the jobs matching state and volname (empty matches all), newest first.
+func (m *JobManager) List(state JobState, volname string) []Job {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	list := make([]Job, 0, len(m.jobs))
+	for _, job := range m.jobs {
+		if state != "" && job.State != state {
+			continue
+		}
+		if volname != "" && job.Volname != volname {
+			continue
+		}
+		list = append(list, *job)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].StartTime.After(list[j].StartTime) })
+	return list
+}
+
+var (
+	ErrJobNotFound   = NewError(http.StatusNotFound, CodeNotFound, "job not found")
+	ErrJobNotRunning = NewError(http.StatusConflict, "job_not_running", "job is not running")
+)
+
+// Cancel stops a running job. The job moves to "cancelled" once its function returns.
+func (m *JobManager) Cancel(id string) (Job, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	job, ok := m.jobs[id]
+	if !ok {
+		return Job{}, ErrJobNotFound
+	}
+	if job.State != JobRunning {
+		return *job, ErrJobNotRunning
+	}
+	job.cancel()
+	return *job, nil
+}
+
+// prune drops the oldest finished jobs beyond jobsKept, m.mu must be held.
+func (m *JobManager) prune() {
+	if len(m.jobs) < jobsKept {
+		return
+	}
+	var finished []*Job
+	for _, job := range m.jobs {
+		if job.State != JobRunning {
+			finished = append(finished, job)
+		}
+	}
+	sort.Slice(finished, func(i, j int) bool { return finished[i].StartTime.Before(finished[j].StartTime) })
+	for i := 0; i < len(finished) && len(m.jobs) >= jobsKept; i++ {
+		delete(m.jobs, finished[i].ID)
+	}
+}
+
+func newJobID() string {
+	buf := make([]byte, 8)
+	rand.Read(buf)
+	return hex.EncodeToString(buf)
+}
+
+// pollInterval is how often background jobs query gluster for progress.
+var pollInterval = 5 * time.Second
+
+// sleepContext waits for d or until ctx is done.
+func sleepContext(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
+
+type JobResponse struct {
+	CommonResponse
+	Job *Job `json:"job,omitempty"`
+}
+
+type JobListResponse struct {
+	CommonResponse
+	Jobs []Job `json:"jobs"`
+}
+
+/*
+[example]
+curl -X GET 'http://127.0.0.1:7030/gluster/jobs?state=running&volname=vol1'
+<- {"result":"OK","jobs":[{"id":"9f2c...","op":"rebalance","volname":"vol1","state":"running",...}]}
+*/
+func ProcessJobList(w http.ResponseWriter, r *http.Request) {
+	var rsp JobListResponse
+	defer writeResponse(w, &rsp)
+
+	query := r.URL.Query()
+	rsp.Jobs = jobs.List(JobState(query.Get("state")), query.Get("volname"))
+	rsp.Result = "OK"
+}
+
+/*
+[example]
+curl -X GET http://127.0.0.1:7030/gluster/jobs/9f2c6a1e0b7d4c35
+<- {"result":"OK","job":{"id":"9f2c6a1e0b7d4c35","op":"rebalance","volname":"vol1","state":"running","progress":{...}}}
+*/
+func ProcessJobGet(w http.ResponseWriter, r *http.Request) {
+	var rsp JobResponse
+	defer writeResponse(w, &rsp)
+
+	job, ok := jobs.Get(mux.Vars(r)["id"])
+	if !ok {
+		rsp.Fail(ErrJobNotFound)
+		return
+	}
+	rsp.Job = &job
+	rsp.Result = "OK"
+}
+
+/*
+[example]
+curl -X POST http://127.0.0.1:7030/gluster/jobs/9f2c6a1e0b7d4c35/cancel
+<- {"result":"OK","job":{"id":"9f2c6a1e0b7d4c35","state":"running",...}}
+*/
+func ProcessJobCancel(w http.ResponseWriter, r *http.Request) {
+	var rsp JobResponse
+	defer writeResponse(w, &rsp)
+
+	job, e := jobs.Cancel(mux.Vars(r)["id"])
+	if e != nil {
+		L.Gluster.Error(e.Error())
+		rsp.Fail(e)
+		return
+	}
+	rsp.Job = &job
+	rsp.Result = "OK"
+}
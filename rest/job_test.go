@@ -0,0 +1,133 @@
+package gluster
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+)
+
+// fastPoll makes background jobs poll gluster without waiting.
+func fastPoll(t *testing.T) {
+	previous := pollInterval
+	pollInterval = time.Millisecond
+	t.Cleanup(func() { pollInterval = previous })
+}
+
+func TestJobManager(t *testing.T) {
+	m := NewJobManager()
+	ok := m.Start("create", "a", func(ctx context.Context, update func(JobProgress)) (string, error) {
+		update(JobProgress{Status: "half", Files: 1})
+		return "done", nil
+	})
+	failed := m.Start("create", "b", func(ctx context.Context, update func(JobProgress)) (string, error) {
+		return "", errors.New("broken")
+	})
+	started := make(chan struct{})
+	cancelled := m.Start("heal", "a", func(ctx context.Context, update func(JobProgress)) (string, error) {
+		close(started)
+		<-ctx.Done()
+		return "", ctx.Err()
+	})
+	<-started
+	if _, e := m.Cancel(cancelled.ID); e != nil {
+		t.Fatal(e)
+	}
+
+	wait := func(id string) Job {
+		for {
+			job, found := m.Get(id)
+			if !found {
+				t.Fatalf("job %s not found", id)
+			}
+			if job.State != JobRunning {
+				return job
+			}
+			time.Sleep(time.Millisecond)
+		}
+	}
+	if job := wait(ok.ID); job.State != JobSucceeded || job.Output != "done" || job.Progress == nil || job.Progress.Files != 1 || job.EndTime == nil {
+		t.Errorf("succeeded job = %+v", job)
+	}
+	if job := wait(failed.ID); job.State != JobFailed || job.Error == nil || job.Error.Code != CodeInternal {
+		t.Errorf("failed job = %+v", job)
+	}
+	if job := wait(cancelled.ID); job.State != JobCancelled {
+		t.Errorf("cancelled job = %+v", job)
+	}
+
+	if _, e := m.Cancel(ok.ID); e != ErrJobNotRunning {
+		t.Errorf("cancel finished job: %v", e)
+	}
+	if _, e := m.Cancel("nope"); e != ErrJobNotFound {
+		t.Errorf("cancel unknown job: %v", e)
+	}
+	if list := m.List("", "a"); len(list) != 2 {
+		t.Errorf("jobs of a: %d, want 2", len(list))
+	}
+	if list := m.List(JobFailed, ""); len(list) != 1 || list[0].ID != failed.ID {
+		t.Errorf("failed jobs: %+v", list)
+	}
+}
+
+func rebalanceStatusXML(status string) string {
+	return `<cliOutput><opRet>0</opRet><volRebalance><task-id>1d2c</task-id><op>3</op><nodeCount>2</nodeCount>` +
+		`<node><nodeName>node1</nodeName><files>7</files><statusStr>` + status + `</statusStr></node>` +
+		`<node><nodeName>node2</nodeName><files>5</files><statusStr>completed</statusStr></node>` +
+		`<aggregate><files>12</files><size>4096</size><statusStr>` + status + `</statusStr></aggregate>` +
+		`</volRebalance></cliOutput>`
+}
+
+func TestRebalanceJob(t *testing.T) {
+	tests := []struct {
+		name   string
+		status string
+		state  JobState
+	}{
+		{"completed", "completed", JobSucceeded},
+		{"failed", "failed", JobFailed},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			fastPoll(t)
+			f := fakeGluster(t).
+				On(`<cliOutput><opRet>0</opRet></cliOutput>`, nil, "gluster", "volume", "rebalance", "test", "start").
+				On(rebalanceStatusXML(test.status), nil, "gluster", "volume", "rebalance", "test", "status")
+			var rsp VolumeReBalanceResponse
+			if status := serve(t, ProcessVolumeReBalance, `{"volname": "test", "options": "start"}`, &rsp); status != http.StatusOK || rsp.JobID == "" {
+				t.Fatalf("status %d: %+v", status, rsp.Error)
+			}
+			job := waitJob(t, rsp.JobID)
+			if job.State != test.state {
+				t.Errorf("job %s, want %s", job.State, test.state)
+			}
+			if job.Progress == nil || job.Progress.Files != 12 || job.Progress.NodesTotal != 2 || job.Progress.NodesDone != 2 {
+				t.Errorf("progress = %+v", job.Progress)
+			}
+			checkCalls(t, f, []string{
+				"gluster volume rebalance test start --xml",
+				"gluster volume rebalance test status --xml",
+			})
+		})
+	}
+}
+
+func TestRebalanceJobCancel(t *testing.T) {
+	fastPoll(t)
+	f := fakeGluster(t).On(rebalanceStatusXML("in progress"), nil, "gluster", "volume", "rebalance")
+	job := jobs.Start("rebalance", "test", rebalanceJob("test"))
+	for len(f.Invocations()) < 3 {
+		time.Sleep(time.Millisecond)
+	}
+	if _, e := jobs.Cancel(job.ID); e != nil {
+		t.Fatal(e)
+	}
+	if job := waitJob(t, job.ID); job.State != JobCancelled {
+		t.Errorf("job %s, want cancelled", job.State)
+	}
+	calls := f.Invocations()
+	if last := calls[len(calls)-1]; last != "gluster volume rebalance test stop" {
+		t.Errorf("last command %q, want the rebalance stopped", last)
+	}
+}
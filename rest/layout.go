@@ -0,0 +1,146 @@
+package gluster
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"hualu.com/gluster-rest/parser"
+)
+
+// VolumeLayout is how the bricks of a volume are grouped into sets: replica
+// or disperse sets, or single bricks for a pure distribute volume. Bricks are
+// grouped in the order given, the first SetSize bricks form the first set.
+type VolumeLayout struct {
+	Type    parser.SubvolumeType `json:"type"`
+	SetSize int                  `json:"set_size"`
+	Arbiter bool                 `json:"arbiter,omitempty"` // the last brick of each set only holds metadata
+	// DataBricks multiplies the smallest data brick into the capacity of a
+	// set: 1 for replicate and distribute, the data bricks of disperse. 0 when
+	// glusterd picks the redundancy.
+	DataBricks int      `json:"data_bricks"`
+	Args       []string `json:"-"` // replica 3 arbiter 1, disperse 6 redundancy 2 ...
+}
+
+// legacyLayoutFields maps the old Type of VolumeCreateRequest to its field.
+var legacyLayoutFields = map[string]func(req *VolumeCreateRequest, n int){
+	"replica":       func(req *VolumeCreateRequest, n int) { req.Replica = n },
+	"arbiter":       func(req *VolumeCreateRequest, n int) { req.Arbiter = n },
+	"disperse":      func(req *VolumeCreateRequest, n int) { req.Disperse = n },
+	"disperse-data": func(req *VolumeCreateRequest, n int) { req.DisperseData = n },
+	"redundancy":    func(req *VolumeCreateRequest, n int) { req.Redundancy = n },
+}
+
+// Layout checks the counts of req and returns the layout they describe.
+func (req VolumeCreateRequest) Layout() (VolumeLayout, *ValidationError) {
+	if req.Type != "" {
+		set, ok := legacyLayoutFields[req.Type]
+		if !ok {
+			return VolumeLayout{}, invalid("type", req.Type, "must be one of replica, arbiter, disperse, disperse-data, redundancy")
+		}
+		n, e := strconv.Atoi(req.Count)
+		if e != nil || n < 1 {
+			return VolumeLayout{}, invalid("count", req.Count, "must be an integer >= 1")
+		}
+		set(&req, n)
+	}
+	for field, n := range map[string]int{"replica": req.Replica, "arbiter": req.Arbiter,
+		"disperse": req.Disperse, "disperse_data": req.DisperseData, "redundancy": req.Redundancy} {
+		if n < 0 {
+			return VolumeLayout{}, invalid(field, strconv.Itoa(n), "cannot be negative")
+		}
+	}
+	dispersed := req.Disperse > 0 || req.DisperseData > 0 || req.Redundancy > 0
+
+	switch {
+	case req.Replica > 0 && dispersed:
+		return VolumeLayout{}, invalid("replica", strconv.Itoa(req.Replica), "cannot be combined with disperse, disperse_data or redundancy")
+
+	case req.Replica > 0 || req.Arbiter > 0:
+		if req.Arbiter > 0 && (req.Arbiter != 1 || req.Replica != 3) {
+			return VolumeLayout{}, invalid("arbiter", strconv.Itoa(req.Arbiter), "only replica 3 arbiter 1 is supported")
+		}
+		if req.Replica < 2 {
+			return VolumeLayout{}, invalid("replica", strconv.Itoa(req.Replica), "must be at least 2")
+		}
+		layout := VolumeLayout{Type: parser.SubvolumeReplicate, SetSize: req.Replica, DataBricks: 1, Args: []string{"replica", strconv.Itoa(req.Replica)}}
+		if req.Arbiter > 0 {
+			layout.Arbiter = true
+			layout.Args = append(layout.Args, "arbiter", "1")
+		}
+		return layout, nil
+
+	case dispersed:
+		// any two of disperse = disperse-data + redundancy give the third,
+		// glusterd picks the redundancy when only disperse is given
+		size, data, redundancy := req.Disperse, req.DisperseData, req.Redundancy
+		switch {
+		case size == 0 && (data == 0 || redundancy == 0):
+			return VolumeLayout{}, invalid("disperse", "", "is required unless both disperse_data and redundancy are given")
+		case size == 0:
+			size = data + redundancy
+		case data > 0 && redundancy > 0 && data+redundancy != size:
+			return VolumeLayout{}, invalid("disperse", strconv.Itoa(size), fmt.Sprintf("must equal disperse_data + redundancy (%d)", data+redundancy))
+		case data > 0:
+			redundancy = size - data
+		case redundancy > 0:
+			data = size - redundancy
+		}
+		if size < 3 {
+			return VolumeLayout{}, invalid("disperse", strconv.Itoa(size), "must be at least 3")
+		}
+		if (data > 0 || redundancy > 0) && (redundancy < 1 || 2*redundancy >= size) {
+			return VolumeLayout{}, invalid("redundancy", strconv.Itoa(redundancy), fmt.Sprintf("must be between 1 and %d, less than half of disperse", (size-1)/2))
+		}
+
+		layout := VolumeLayout{Type: parser.SubvolumeDisperse, SetSize: size, Args: []string{"disperse", strconv.Itoa(size)}}
+		if redundancy > 0 {
+			layout.DataBricks = data
+			layout.Args = append(layout.Args, "redundancy", strconv.Itoa(redundancy))
+		}
+		return layout, nil
+	}
+	return VolumeLayout{Type: parser.SubvolumeDistribute, SetSize: 1, DataBricks: 1}, nil
+}
+
+// CheckBricks checks the bricks fill whole sets and, unless force is set, that
+// no set has two bricks on one host, which losing that host would take down
+// together. Hosts are compared by name, an IP and a hostname of the same node
+// are not matched.
+func (layout VolumeLayout) CheckBricks(field string, bricks []string, force bool) *ValidationError {
+	if len(bricks)%layout.SetSize != 0 {
+		return invalid(field, strconv.Itoa(len(bricks)),
+			fmt.Sprintf("brick count must be a multiple of the %s set size %d", layout.Type, layout.SetSize))
+	}
+	if force || layout.SetSize == 1 {
+		return nil
+	}
+	for start := 0; start < len(bricks); start += layout.SetSize {
+		hosts := make(map[string]int)
+		for i := start; i < start+layout.SetSize; i++ {
+			host, _ := parser.SplitBrick(bricks[i])
+			if j, ok := hosts[host]; ok {
+				return invalid(fmt.Sprintf("%s[%d]", field, i), bricks[i],
+					fmt.Sprintf("is on the same host as %s[%d] in %s set %d, set force to allow it", field, j, layout.Type, start/layout.SetSize))
+			}
+			hosts[host] = i
+		}
+	}
+	return nil
+}
+
+// volumeLayout returns the layout of an existing volume, bricks added to it
+// must fill whole sets of the same size.
+func volumeLayout(volume parser.Volume) (VolumeLayout, error) {
+	switch {
+	case volume.DisperseCount > 0:
+		return VolumeLayout{Type: parser.SubvolumeDisperse, SetSize: volume.DisperseCount,
+			DataBricks: volume.DisperseCount - volume.RedundancyCount}, nil
+	case volume.ReplicaCount > 1:
+		return VolumeLayout{Type: parser.SubvolumeReplicate, SetSize: volume.ReplicaCount,
+			Arbiter: volume.ArbiterCount > 0, DataBricks: 1}, nil
+	case volume.SubvolumeSize > 1:
+		return VolumeLayout{}, NewError(http.StatusBadRequest, CodeNotSupported, "volume %s is %s, striped volumes cannot be changed", volume.Name, volume.Type)
+	}
+	return VolumeLayout{Type: parser.SubvolumeDistribute, SetSize: 1, DataBricks: 1}, nil
+}
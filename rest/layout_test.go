@@ -0,0 +1,96 @@
+package gluster
+
+import (
+	"strings"
+	"testing"
+
+	"hualu.com/gluster-rest/parser"
+)
+
+func TestVolumeCreateLayout(t *testing.T) {
+	tests := []struct {
+		name  string
+		req   VolumeCreateRequest
+		typ   parser.SubvolumeType
+		size  int
+		data  int
+		args  string
+		field string // the invalid field
+	}{
+		{"distribute", VolumeCreateRequest{}, parser.SubvolumeDistribute, 1, 1, "", ""},
+		{"replica", VolumeCreateRequest{Replica: 3}, parser.SubvolumeReplicate, 3, 1, "replica 3", ""},
+		{"arbiter", VolumeCreateRequest{Replica: 3, Arbiter: 1}, parser.SubvolumeReplicate, 3, 1, "replica 3 arbiter 1", ""},
+		{"arbiter without replica 3", VolumeCreateRequest{Replica: 2, Arbiter: 1}, "", 0, 0, "", "arbiter"},
+		{"replica 1", VolumeCreateRequest{Replica: 1}, "", 0, 0, "", "replica"},
+		{"negative count", VolumeCreateRequest{Disperse: -3}, "", 0, 0, "", "disperse"},
+		{"replica and disperse", VolumeCreateRequest{Replica: 2, Disperse: 3}, "", 0, 0, "", "replica"},
+		{"disperse", VolumeCreateRequest{Disperse: 6}, parser.SubvolumeDisperse, 6, 0, "disperse 6", ""},
+		{"disperse redundancy", VolumeCreateRequest{Disperse: 6, Redundancy: 2}, parser.SubvolumeDisperse, 6, 4, "disperse 6 redundancy 2", ""},
+		{"disperse data", VolumeCreateRequest{Disperse: 6, DisperseData: 4}, parser.SubvolumeDisperse, 6, 4, "disperse 6 redundancy 2", ""},
+		{"data and redundancy", VolumeCreateRequest{DisperseData: 4, Redundancy: 2}, parser.SubvolumeDisperse, 6, 4, "disperse 6 redundancy 2", ""},
+		{"counts disagree", VolumeCreateRequest{Disperse: 6, DisperseData: 4, Redundancy: 1}, "", 0, 0, "", "disperse"},
+		{"redundancy alone", VolumeCreateRequest{Redundancy: 2}, "", 0, 0, "", "disperse"},
+		{"redundancy half", VolumeCreateRequest{Disperse: 4, Redundancy: 2}, "", 0, 0, "", "redundancy"},
+		{"disperse 2", VolumeCreateRequest{Disperse: 2}, "", 0, 0, "", "disperse"},
+		{"legacy replica", VolumeCreateRequest{Type: "replica", Count: "2"}, parser.SubvolumeReplicate, 2, 1, "replica 2", ""},
+		{"legacy type", VolumeCreateRequest{Type: "stripe", Count: "2"}, "", 0, 0, "", "type"},
+		{"legacy count", VolumeCreateRequest{Type: "replica", Count: "two"}, "", 0, 0, "", "count"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			layout, e := test.req.Layout()
+			if test.field != "" {
+				if e == nil || e.Field != test.field {
+					t.Errorf("got %+v, %v, want invalid %s", layout, e, test.field)
+				}
+				return
+			}
+			if e != nil {
+				t.Fatal(e)
+			}
+			if layout.Type != test.typ || layout.SetSize != test.size || layout.DataBricks != test.data {
+				t.Errorf("layout = %+v", layout)
+			}
+			if args := strings.Join(layout.Args, " "); args != test.args {
+				t.Errorf("args = %q, want %q", args, test.args)
+			}
+		})
+	}
+}
+
+func TestCheckBricks(t *testing.T) {
+	replica3 := VolumeLayout{Type: parser.SubvolumeReplicate, SetSize: 3, DataBricks: 1}
+	tests := []struct {
+		name   string
+		layout VolumeLayout
+		bricks []string
+		force  bool
+		field  string // the invalid field
+	}{
+		{"distribute on one host", VolumeLayout{Type: parser.SubvolumeDistribute, SetSize: 1, DataBricks: 1},
+			[]string{"node1:/data/b1", "node1:/data/b2"}, false, ""},
+		{"two sets", replica3,
+			[]string{"node1:/data/b1", "node2:/data/b1", "node3:/data/b1", "node2:/data/b2", "node3:/data/b2", "node1:/data/b2"}, false, ""},
+		{"partial set", replica3,
+			[]string{"node1:/data/b1", "node2:/data/b1", "node3:/data/b1", "node1:/data/b2"}, false, "bricks"},
+		{"set on one host", replica3,
+			[]string{"node1:/data/b1", "node2:/data/b1", "node3:/data/b1", "node1:/data/b2", "node2:/data/b2", "node1:/data/b3"}, false, "bricks[5]"},
+		{"set on one host forced", replica3,
+			[]string{"node1:/data/b1", "node1:/data/b2", "node1:/data/b3"}, true, ""},
+		{"partial set forced", replica3,
+			[]string{"node1:/data/b1", "node2:/data/b1"}, true, "bricks"},
+		{"disperse", VolumeLayout{Type: parser.SubvolumeDisperse, SetSize: 3, DataBricks: 2},
+			[]string{"node1:/data/b1", "node2:/data/b1", "node2:/data/b2"}, false, "bricks[2]"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			e := test.layout.CheckBricks("bricks", test.bricks, test.force)
+			if test.field == "" && e != nil {
+				t.Errorf("got %v", e)
+			}
+			if test.field != "" && (e == nil || e.Field != test.field) {
+				t.Errorf("got %v, want invalid %s", e, test.field)
+			}
+		})
+	}
+}
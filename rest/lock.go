@@ -0,0 +1,156 @@
+package gluster
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// LockHolder describes the operation currently holding a lock.
+type LockHolder struct {
+	Key   string    `json:"key"`
+	Op    string    `json:"op"`
+	Since time.Time `json:"since"`
+}
+
+// LockBusyError is returned when a lock is held by another operation.
+type LockBusyError struct {
+	Holder LockHolder
+}
+
+func (e *LockBusyError) Error() string {
+	return fmt.Sprintf("%s is locked by %q since %s", e.Holder.Key, e.Holder.Op, e.Holder.Since.Format(time.RFC3339))
+}
+
+type lockEntry struct {
+	holder LockHolder
+	done   chan struct{}
+}
+
+// LockManager serializes mutating operations in this process, keyed by volume
+// name or the cluster lock.
+type LockManager struct {
+	mu   sync.Mutex
+	held map[string]*lockEntry
+}
+
+func NewLockManager() *LockManager {
+	return &LockManager{held: make(map[string]*lockEntry)}
+}
+
+var locks = NewLockManager()
+
+const clusterLock = "cluster"
+
+func volumeLock(volname string) string {
+	return "volume/" + volname
+}
+
+func snapshotLock(snapname string) string {
+	return "snapshot/" + snapname
+}
+
+// MaxLockWait caps the wait a client may ask for, the HTTP server must give a
+// request the wait plus its command before cutting the response off.
+const MaxLockWait = 120 * time.Second
+
+// Acquire takes key for op. If the lock is busy it waits up to wait for it to
+// be released, and returns a *LockBusyError when it is still held. The
+// returned release function may be called more than once.
+func (m *LockManager) Acquire(ctx context.Context, key, op string, wait time.Duration) (func(), error) {
+	var timeout <-chan time.Time
+	if wait > 0 {
+		t := time.NewTimer(wait)
+		defer t.Stop()
+		timeout = t.C
+	}
+
+	for {
+		m.mu.Lock()
+		entry, busy := m.held[key]
+		if !busy {
+			entry = &lockEntry{
+				holder: LockHolder{Key: key, Op: op, Since: time.Now()},
+				done:   make(chan struct{}),
+			}
+			m.held[key] = entry
+			m.mu.Unlock()
+
+			var once sync.Once
+			return func() {
+				once.Do(func() {
+					m.mu.Lock()
+					delete(m.held, key)
+					m.mu.Unlock()
+					close(entry.done)
+				})
+			}, nil
+		}
+		holder := entry.holder
+		m.mu.Unlock()
+
+		if wait <= 0 {
+			return nil, &LockBusyError{Holder: holder}
+		}
+		select {
+		case <-entry.done:
+		case <-timeout:
+			return nil, &LockBusyError{Holder: holder}
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// Holders lists the locks currently held.
+func (m *LockManager) Holders() []LockHolder {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	holders := make([]LockHolder, 0, len(m.held))
+	for _, entry := range m.held {
+		holders = append(holders, entry.holder)
+	}
+	return holders
+}
+
+// lockForRequest acquires key for op, waiting as long as the "wait" query
+// parameter asks (e.g. ?wait=30s, default no wait).
+func lockForRequest(r *http.Request, key, op string) (func(), error) {
+	var wait time.Duration
+	if s := r.URL.Query().Get("wait"); s != "" {
+		d, e := time.ParseDuration(s)
+		if e != nil || d < 0 || d > MaxLockWait {
+			return nil, invalid("wait", s, fmt.Sprintf("must be a duration between 0s and %s", MaxLockWait))
+		}
+		wait = d
+	}
+	return locks.Acquire(r.Context(), key, op, wait)
+}
+
+// locked releases the lock once fn returns.
+func locked(release func(), fn JobFunc) JobFunc {
+	return func(ctx context.Context, update func(JobProgress)) (string, error) {
+		defer release()
+		return fn(ctx, update)
+	}
+}
+
+type LockListResponse struct {
+	CommonResponse
+	Locks []LockHolder `json:"locks"`
+}
+
+/*
+[example]
+curl -X GET http://127.0.0.1:7030/gluster/locks
+<- {"result":"OK","locks":[{"key":"volume/vol1","op":"rebalance","since":"2019-08-01T10:00:00+08:00"}]}
+*/
+func ProcessLockList(w http.ResponseWriter, r *http.Request) {
+	var rsp LockListResponse
+	defer writeResponse(w, &rsp)
+
+	rsp.Locks = locks.Holders()
+	rsp.Result = "OK"
+}
@@ -0,0 +1,79 @@
+package gluster
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestLockAcquire(t *testing.T) {
+	m := NewLockManager()
+	release, e := m.Acquire(context.Background(), "volume/test", "create", 0)
+	if e != nil {
+		t.Fatal(e)
+	}
+	if _, e := m.Acquire(context.Background(), "volume/test", "start", 0); e == nil {
+		t.Fatal("acquired a held lock")
+	} else if busy, ok := e.(*LockBusyError); !ok || busy.Holder.Op != "create" {
+		t.Errorf("got %v, want busy by create", e)
+	}
+	if _, e := m.Acquire(context.Background(), "volume/other", "start", 0); e != nil {
+		t.Errorf("other volume: %v", e)
+	}
+
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		release()
+	}()
+	again, e := m.Acquire(context.Background(), "volume/test", "start", time.Second)
+	if e != nil {
+		t.Fatalf("waiting for the release: %v", e)
+	}
+	release() // released twice is harmless
+	again()
+	if holders := m.Holders(); len(holders) != 1 || holders[0].Key != "volume/other" {
+		t.Errorf("holders = %+v", holders)
+	}
+}
+
+func TestLockWait(t *testing.T) {
+	tests := []struct {
+		query string
+		ok    bool
+	}{
+		{"", true},
+		{"?wait=30s", true},
+		{"?wait=-1s", false},
+		{"?wait=10m", false},
+		{"?wait=soon", false},
+	}
+	for _, test := range tests {
+		release, e := lockForRequest(httptest.NewRequest(http.MethodPost, "/"+test.query, nil), volumeLock("wait"), "test")
+		if (e == nil) != test.ok {
+			t.Errorf("%q: %v, want ok %v", test.query, e, test.ok)
+		}
+		if release != nil {
+			release()
+		}
+	}
+}
+
+func TestVolumeLocked(t *testing.T) {
+	f := fakeGluster(t)
+	release, e := locks.Acquire(context.Background(), volumeLock("busy"), "create", 0)
+	if e != nil {
+		t.Fatal(e)
+	}
+	defer release()
+
+	var rsp CommonVolumeResponse
+	if status := serve(t, ProcessVolumeStart, `{"volname": "busy"}`, &rsp); status != http.StatusConflict {
+		t.Errorf("status %d, want 409", status)
+	}
+	if rsp.Error == nil || rsp.Error.Code != CodeLocked || rsp.Error.Holder == nil || rsp.Error.Holder.Op != "create" {
+		t.Errorf("got %+v", rsp.Error)
+	}
+	checkCalls(t, f, nil)
+}
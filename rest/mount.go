@@ -8,7 +8,6 @@ import (
 	"io/ioutil"
 	"net/http"
 	"os"
-	"os/exec"
 	"strconv"
 	"strings"
 	"syscall"
@@ -41,10 +40,7 @@ type Mount struct {
 	FSStats       FSStats // Filesystem data, may be nil.
 }
 
-type CommonMountResponse struct {
-	Result string `json:"result"`
-	Errors string `json:"errors,omitempty"`
-}
+type CommonMountResponse = CommonResponse
 
 type CommonMountRequest struct {
 	Volname string `json:"volname"`
@@ -75,41 +71,20 @@ type MountList struct {
 func ProcessMountAdd(w http.ResponseWriter, r *http.Request) {
 
 	var rsp CommonMountResponse
-	defer func() {
-		buf, e := json.Marshal(&rsp)
-		if e != nil {
-			w.WriteHeader(500)
-			return
-		}
-		w.Write(buf)
-	}()
+	defer writeResponse(w, &rsp)
 
 	// analyses request
-	body, e := ioutil.ReadAll(r.Body)
-	if e != nil {
-		L.Gluster.Error(e.Error())
-		rsp.Result = "ERROR"
-		rsp.Errors = e.Error()
-		return
-	}
-	defer r.Body.Close()
-
 	var mountAddRequest CommonMountRequest
-	e = json.Unmarshal(body, &mountAddRequest)
-	if e != nil {
+	if e := readRequest(r, &mountAddRequest); e != nil {
 		L.Gluster.Error(e.Error())
-		rsp.Result = "ERROR"
-		rsp.Errors = e.Error()
+		rsp.Fail(e)
 		return
 	}
 
 	L.Gluster.Debugf("After Unmarshall > mountAddRequest is: %+v", mountAddRequest)
 
 	//mkdir
-	cmdString := fmt.Sprintf("mkdir -p %s", mountAddRequest.Mount)
-	L.Gluster.Info(cmdString)
-	cmd := exec.Command("sh", "-c", cmdString)
-	output, e := cmd.CombinedOutput()
+	output, e := run(r.Context(), Command{Name: "mkdir", Args: []string{"-p", mountAddRequest.Mount}})
 	if e != nil {
 		L.Gluster.Error(string(output))
 		//rsp.Result = "ERROR"
@@ -119,14 +94,13 @@ func ProcessMountAdd(w http.ResponseWriter, r *http.Request) {
 
 	//run command in docker
 	//cmdString = fmt.Sprintf("docker exec glusterfs mount -t %s localhost:/%s %s", mountAddRequest.Type, mountAddRequest.Volname, mountAddRequest.Mount)
-	cmdString = fmt.Sprintf("mount -t %s localhost:%s %s", mountAddRequest.Type, mountAddRequest.Volname, mountAddRequest.Mount)
-	L.Gluster.Info(cmdString)
-	cmd = exec.Command("sh", "-c", cmdString)
-	output, e = cmd.CombinedOutput()
+	output, e = run(r.Context(), Command{
+		Name: "mount",
+		Args: []string{"-t", mountAddRequest.Type, "localhost:" + mountAddRequest.Volname, mountAddRequest.Mount},
+	})
 	if e != nil {
 		L.Gluster.Error(string(output))
-		rsp.Result = "ERROR"
-		rsp.Errors = string(output)
+		rsp.Fail(glusterError(output, e))
 		return
 	}
 
@@ -136,31 +110,13 @@ func ProcessMountAdd(w http.ResponseWriter, r *http.Request) {
 func ProcessMountDelete(w http.ResponseWriter, r *http.Request) {
 
 	var rsp CommonMountResponse
-	defer func() {
-		buf, e := json.Marshal(&rsp)
-		if e != nil {
-			w.WriteHeader(500)
-			return
-		}
-		w.Write(buf)
-	}()
+	defer writeResponse(w, &rsp)
 
 	// analyse request
-	body, e := ioutil.ReadAll(r.Body)
-	if e != nil {
-		L.Gluster.Error(e.Error())
-		rsp.Result = "ERROR"
-		rsp.Errors = e.Error()
-		return
-	}
-	defer r.Body.Close()
-
 	var mountDeleteReq MountDeleteRequest
-	e = json.Unmarshal(body, &mountDeleteReq)
-	if e != nil {
+	if e := readRequest(r, &mountDeleteReq); e != nil {
 		L.Gluster.Error(e.Error())
-		rsp.Result = "ERROR"
-		rsp.Errors = e.Error()
+		rsp.Fail(e)
 		return
 	}
 	L.Gluster.Debugf("After Unmarshall > mountDeleteReq is: %+v", mountDeleteReq)
@@ -168,25 +124,19 @@ func ProcessMountDelete(w http.ResponseWriter, r *http.Request) {
 	//run command in docker
 	//var cmdString string
 	//cmdString = fmt.Sprintf("docker exec glusterfs umount %s", mountDeleteReq.Mount)
-	cmdString := fmt.Sprintf("umount %s", mountDeleteReq.Mount)
+	umount := Command{Name: "umount", Args: []string{mountDeleteReq.Mount}}
 	if mountDeleteReq.Force == "true" {
 		//cmdString = fmt.Sprintf("docker exec glusterfs umount -fl %s", mountDeleteReq.Mount)
-		cmdString = fmt.Sprintf("umount -fl %s", mountDeleteReq.Mount)
+		umount.Args = []string{"-fl", mountDeleteReq.Mount}
 	}
-	L.Gluster.Info(cmdString)
-	cmd := exec.Command("sh", "-c", cmdString)
-	output, e := cmd.CombinedOutput()
+	output, e := run(r.Context(), umount)
 	if e != nil {
 		L.Gluster.Error(string(output))
-		rsp.Result = "ERROR"
-		rsp.Errors = string(output)
+		rsp.Fail(glusterError(output, e))
 		return
 	}
 
-	cmdString = fmt.Sprintf("rm -fr %s", mountDeleteReq.Mount)
-	L.Gluster.Info(cmdString)
-	cmd = exec.Command("sh", "-c", cmdString)
-	cmd.CombinedOutput()
+	run(r.Context(), Command{Name: "rm", Args: []string{"-fr", mountDeleteReq.Mount}})
 
 	rsp.Result = "OK"
 }
@@ -251,21 +201,13 @@ func BlockSizeToString(blockSize uint64) (rtn string) {
 func ProcessMountList(w http.ResponseWriter, r *http.Request) {
 
 	var rsp MountListResponse
-	defer func() {
-		buf, e := json.Marshal(&rsp)
-		if e != nil {
-			w.WriteHeader(500)
-			return
-		}
-		w.Write(buf)
-	}()
+	defer writeResponse(w, &rsp)
 
 	// analyse request
 	body, e := ioutil.ReadAll(r.Body)
 	if e != nil {
 		L.Gluster.Error(e.Error())
-		rsp.Result = "ERROR"
-		rsp.Errors = e.Error()
+		rsp.Fail(e)
 		return
 	}
 	defer r.Body.Close()
@@ -274,15 +216,15 @@ func ProcessMountList(w http.ResponseWriter, r *http.Request) {
 	e = json.Unmarshal(body, &mountListReq)
 	if e != nil {
 		L.Gluster.Error(e.Error())
-		rsp.Result = "ERROR"
-		rsp.Errors = e.Error()
+		rsp.Fail(e)
 		return
 	}
 	L.Gluster.Debugf("After Unmarshall > mountListReq is: %+v", mountListReq)
 
 	f, err := os.Open("/etc/mtab")
 	if err != nil {
-		fmt.Println(err.Error())
+		L.Gluster.Error(err.Error())
+		rsp.Fail(err)
 		return
 	}
 	defer f.Close()
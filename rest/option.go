@@ -0,0 +1,396 @@
+package gluster
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"net/http"
+
+	L "hualu.com/logger"
+)
+
+type VolumeOptionSetRequest struct {
+	CommonVolumeRequest
+	Options []Option `json:"options"` // applied in order
+	Force   string   `json:"force"`   // skip the catalog check, for options help-xml does not list
+}
+
+// VolumeOptionRequest selects options by name, all of them when Keys is empty.
+type VolumeOptionRequest struct {
+	CommonVolumeRequest
+	Keys  []string `json:"keys"`
+	Force string   `json:"force"` // reset only
+}
+
+type VolumeOptionResponse struct {
+	CommonVolumeResponse
+	Options []OptionResult `json:"options"`
+}
+
+// OptionResult is the effective value and default of an option, and for
+// set/reset whether the change of this key succeeded.
+type OptionResult struct {
+	Name    string `json:"name"`
+	Value   string `json:"value"`
+	Default string `json:"default"`
+	Result  string `json:"result,omitempty"`
+	Error   *Error `json:"error,omitempty"`
+	// set or reset, but volume get does not describe the key, e.g. group
+	ValueUnknown bool `json:"value_unknown,omitempty"`
+}
+
+// volume get <vol> all --xml
+type VolumeGetOptsXML struct {
+	XMLName    xml.Name   `xml:"cliOutput" json:"-"`
+	VolGetopts VolGetopts `xml:"volGetopts"`
+}
+
+type VolGetopts struct {
+	Count int   `xml:"count"`
+	Opts  []Opt `xml:"Opt"`
+}
+
+type Opt struct {
+	Option string `xml:"Option"`
+	Value  string `xml:"Value"`
+}
+
+// volume set help-xml
+type VolumeOptionsDefaultsXML struct {
+	XMLName       xml.Name       `xml:"volumeOptionsDefaults"`
+	VolumeOptions []VolumeOption `xml:"volumeOption"`
+}
+
+type VolumeOption struct {
+	Name         string `xml:"name" json:"name"`
+	DefaultValue string `xml:"defaultValue" json:"default"`
+	Description  string `xml:"description" json:"description"`
+}
+
+// volumeOptions returns the effective value of every option of volname.
+func volumeOptions(ctx context.Context, volname string) ([]Opt, error) {
+	output, e := runGluster(ctx, "volume", "get", volname, "all", "--xml")
+	if e != nil {
+		return nil, glusterError(output, e)
+	}
+	var volumeGetOptsXML VolumeGetOptsXML
+	if e := xml.Unmarshal(output, &volumeGetOptsXML); e != nil {
+		return nil, e
+	}
+	return volumeGetOptsXML.VolGetopts.Opts, nil
+}
+
+// optionDefaults reads every settable option by name, uncached, see catalogs.
+func optionDefaults(ctx context.Context) (map[string]VolumeOption, error) {
+	output, e := runGluster(ctx, "volume", "set", "help-xml")
+	if e != nil {
+		return nil, glusterError(output, e)
+	}
+	var defaultsXML VolumeOptionsDefaultsXML
+	if e := xml.Unmarshal(output, &defaultsXML); e != nil {
+		return nil, e
+	}
+	defaults := make(map[string]VolumeOption, len(defaultsXML.VolumeOptions))
+	for _, option := range defaultsXML.VolumeOptions {
+		defaults[option.Name] = option
+	}
+	return defaults, nil
+}
+
+// describeOptions fills value and default of results after appending one result
+// per key. With neither results nor keys it describes every option of the
+// volume. Keys volume get does not know are marked as failed, results already
+// given only as having an unknown value: their command decided the outcome.
+func describeOptions(ctx context.Context, volname string, results []OptionResult, keys []string) ([]OptionResult, error) {
+	opts, e := volumeOptions(ctx, volname)
+	if e != nil {
+		return results, e
+	}
+	catalog, e := catalogs.Get(ctx)
+	if e != nil {
+		return results, e
+	}
+
+	values := make(map[string]string, len(opts))
+	for _, opt := range opts {
+		values[opt.Option] = opt.Value
+	}
+	if len(keys) == 0 && results == nil {
+		for _, opt := range opts {
+			keys = append(keys, opt.Option)
+		}
+	}
+	given := len(results)
+	for _, key := range keys {
+		results = append(results, OptionResult{Name: key})
+	}
+
+	for i := range results {
+		name := results[i].Name
+		if option, ok := catalog.Lookup(name); ok {
+			name = option.Name // the CLI accepts the part after the dot
+		}
+		value, ok := values[name]
+		switch {
+		case ok:
+			results[i].Value = value
+			results[i].Default = catalog.Options[name].Default
+		case i < given:
+			results[i].ValueUnknown = results[i].Error == nil
+		case results[i].Error == nil:
+			results[i].Result = "ERROR"
+			results[i].Error = NewError(http.StatusNotFound, CodeNotFound, "option %s does not exist", results[i].Name)
+		}
+	}
+	return results, nil
+}
+
+// optionsError summarizes the failed keys of results, nil when all succeeded.
+func optionsError(results []OptionResult) *Error {
+	var first *Error
+	var failed []string
+	for _, result := range results {
+		if result.Error != nil {
+			if first == nil {
+				first = result.Error
+			}
+			failed = append(failed, result.Name)
+		}
+	}
+	if first == nil {
+		return nil
+	}
+	err := *first
+	err.Message = fmt.Sprintf("%d of %d options failed %v: %s", len(failed), len(results), failed, first.Message)
+	return &err
+}
+
+/*
+[example]
+docker exec glusterfs gluster volume set test performance.cache-size 256MB
+
+curl -X POST http://127.0.0.1:7030/gluster/volume/options/set -H 'Content-Type: application/json' -d '{
+"volname": "test",
+"options": [{"name": "performance.cache-size", "value": "256MB"}, {"name": "nfs.disable", "value": "on"}]
+}'
+<- {"result":"OK","options":[{"name":"performance.cache-size","value":"256MB","default":"32MB","result":"OK"},...]}
+*/
+func ProcessVolumeOptionSet(w http.ResponseWriter, r *http.Request) {
+	var rsp VolumeOptionResponse
+	defer writeResponse(w, &rsp)
+
+	// analyze request
+	var volumeOptionSetReq VolumeOptionSetRequest
+	if e := readRequest(r, &volumeOptionSetReq); e != nil {
+		L.Gluster.Error(e.Error())
+		rsp.Fail(e)
+		return
+	}
+
+	if volumeOptionSetReq.Force != "true" {
+		catalog, e := catalogs.Get(r.Context())
+		if e != nil {
+			L.Gluster.Error(e.Error())
+			rsp.Fail(e)
+			return
+		}
+		for i, option := range volumeOptionSetReq.Options {
+			if e := catalog.Validate(fmt.Sprintf("options[%d]", i), option.Name, option.Value); e != nil {
+				L.Gluster.Error(e.Error())
+				rsp.Fail(e)
+				return
+			}
+		}
+	}
+
+	release, e := lockForRequest(r, volumeLock(volumeOptionSetReq.Volname), "volume set")
+	if e != nil {
+		L.Gluster.Error(e.Error())
+		rsp.Fail(e)
+		return
+	}
+	defer release()
+
+	// every key is set on its own so one bad key does not hold back the others
+	var results []OptionResult
+	for _, option := range volumeOptionSetReq.Options {
+		result := OptionResult{Name: option.Name, Result: "OK"}
+		output, e := runGluster(r.Context(), "volume", "set", volumeOptionSetReq.Volname, option.Name, option.Value)
+		if e != nil {
+			L.Gluster.Error(string(output))
+			result.Result = "ERROR"
+			result.Error = glusterError(output, e)
+		}
+		results = append(results, result)
+	}
+
+	rsp.Options, e = describeOptions(r.Context(), volumeOptionSetReq.Volname, results, nil)
+	if e != nil {
+		L.Gluster.Error(e.Error())
+		rsp.Fail(e)
+		return
+	}
+	if e := optionsError(rsp.Options); e != nil {
+		rsp.Fail(e)
+		return
+	}
+	rsp.Result = "OK"
+}
+
+/*
+[example]
+docker exec glusterfs gluster volume get test all --xml
+
+curl -X POST http://127.0.0.1:7030/gluster/volume/options/get -H 'Content-Type: application/json' -d '{
+"volname": "test",
+"keys": ["performance.cache-size"]
+}'
+<- {"result":"OK","options":[{"name":"performance.cache-size","value":"256MB","default":"32MB"}]}
+*/
+func ProcessVolumeOptionGet(w http.ResponseWriter, r *http.Request) {
+	var rsp VolumeOptionResponse
+	defer writeResponse(w, &rsp)
+
+	// analyze request
+	var volumeOptionReq VolumeOptionRequest
+	if e := readRequest(r, &volumeOptionReq); e != nil {
+		L.Gluster.Error(e.Error())
+		rsp.Fail(e)
+		return
+	}
+
+	var e error
+	rsp.Options, e = describeOptions(r.Context(), volumeOptionReq.Volname, nil, volumeOptionReq.Keys)
+	if e != nil {
+		L.Gluster.Error(e.Error())
+		rsp.Fail(e)
+		return
+	}
+	if e := optionsError(rsp.Options); e != nil {
+		rsp.Fail(e)
+		return
+	}
+	rsp.Result = "OK"
+}
+
+/*
+list returns the options reconfigured on the volume, /gluster/volume/options/get returns every option.
+
+[example]
+docker exec glusterfs gluster volume info test --xml
+
+curl -X POST http://127.0.0.1:7030/gluster/volume/options/list -H 'Content-Type: application/json' -d '{
+"volname": "test"
+}'
+<- {"result":"OK","options":[{"name":"nfs.disable","value":"on","default":"on"},...]}
+*/
+func ProcessVolumeOptionList(w http.ResponseWriter, r *http.Request) {
+	var rsp VolumeOptionResponse
+	defer writeResponse(w, &rsp)
+
+	// analyze request
+	var volumeOptionReq VolumeOptionRequest
+	if e := readRequest(r, &volumeOptionReq); e != nil {
+		L.Gluster.Error(e.Error())
+		rsp.Fail(e)
+		return
+	}
+
+	output, e := runGluster(r.Context(), "volume", "info", volumeOptionReq.Volname, "--xml")
+	if e != nil {
+		L.Gluster.Error(string(output))
+		rsp.Fail(glusterError(output, e))
+		return
+	}
+	var volinfoXML VolumeInfoXML
+	if e := xml.Unmarshal(output, &volinfoXML); e != nil {
+		L.Gluster.Error(e.Error())
+		rsp.Fail(e)
+		return
+	}
+
+	keys := []string{}
+	for _, volume := range volinfoXML.VolInfo.Volumes.Volume {
+		for _, option := range volume.Options {
+			keys = append(keys, option.Name)
+		}
+	}
+	rsp.Options, e = describeOptions(r.Context(), volumeOptionReq.Volname, []OptionResult{}, keys)
+	if e != nil {
+		L.Gluster.Error(e.Error())
+		rsp.Fail(e)
+		return
+	}
+	rsp.Result = "OK"
+}
+
+/*
+[example]
+docker exec glusterfs gluster volume reset test performance.cache-size
+
+curl -X POST http://127.0.0.1:7030/gluster/volume/options/reset -H 'Content-Type: application/json' -d '{
+"volname": "test",
+"keys": ["performance.cache-size"]
+}'
+<- {"result":"OK","options":[{"name":"performance.cache-size","value":"32MB","default":"32MB","result":"OK"}]}
+*/
+func ProcessVolumeOptionReset(w http.ResponseWriter, r *http.Request) {
+	var rsp VolumeOptionResponse
+	defer writeResponse(w, &rsp)
+
+	// analyze request
+	var volumeOptionReq VolumeOptionRequest
+	if e := readRequest(r, &volumeOptionReq); e != nil {
+		L.Gluster.Error(e.Error())
+		rsp.Fail(e)
+		return
+	}
+
+	release, e := lockForRequest(r, volumeLock(volumeOptionReq.Volname), "volume reset")
+	if e != nil {
+		L.Gluster.Error(e.Error())
+		rsp.Fail(e)
+		return
+	}
+	defer release()
+
+	keys := volumeOptionReq.Keys
+	if len(keys) == 0 {
+		keys = []string{"all"}
+	}
+
+	var results []OptionResult
+	for _, key := range keys {
+		args := []string{"volume", "reset", volumeOptionReq.Volname}
+		if key != "all" {
+			args = append(args, key)
+		}
+		if volumeOptionReq.Force == "true" {
+			args = append(args, "force")
+		}
+		result := OptionResult{Name: key, Result: "OK"}
+		output, e := runGluster(r.Context(), args...)
+		if e != nil {
+			L.Gluster.Error(string(output))
+			result.Result = "ERROR"
+			result.Error = glusterError(output, e)
+		}
+		results = append(results, result)
+	}
+
+	if len(volumeOptionReq.Keys) > 0 {
+		rsp.Options, e = describeOptions(r.Context(), volumeOptionReq.Volname, results, nil)
+		if e != nil {
+			L.Gluster.Error(e.Error())
+			rsp.Fail(e)
+			return
+		}
+	} else {
+		rsp.Options = results
+	}
+	if e := optionsError(rsp.Options); e != nil {
+		rsp.Fail(e)
+		return
+	}
+	rsp.Result = "OK"
+}
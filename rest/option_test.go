@@ -0,0 +1,121 @@
+package gluster
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+)
+
+const (
+	optionHelpXML = `<volumeOptionsDefaults>` +
+		`<volumeOption><name>performance.cache-size</name><defaultValue>32MB</defaultValue><description>Size of the read cache.</description></volumeOption>` +
+		`<volumeOption><name>nfs.disable</name><defaultValue>on</defaultValue><description>Disable the NFS export.</description></volumeOption>` +
+		`<volumeOption><name>network.ping-timeout</name><defaultValue>42</defaultValue><description>Time to wait for a brick.</description></volumeOption>` +
+		`<volumeOption><name>cluster.min-free-disk</name><defaultValue>10%</defaultValue><description>Free space kept on a brick.</description></volumeOption>` +
+		`<volumeOption><name>diagnostics.brick-log-level</name><defaultValue>INFO</defaultValue><description>Log level of the bricks.</description></volumeOption>` +
+		`</volumeOptionsDefaults>`
+	volumeGetXML = `<cliOutput><opRet>0</opRet><volGetopts><count>3</count>` +
+		`<Opt><Option>performance.cache-size</Option><Value>256MB</Value></Opt>` +
+		`<Opt><Option>nfs.disable</Option><Value>on</Value></Opt>` +
+		`<Opt><Option>network.ping-timeout</Option><Value>42</Value></Opt>` +
+		`</volGetopts></cliOutput>`
+)
+
+// fakeOptions answers the commands the option catalog and volume get run.
+func fakeOptions(t *testing.T) *FakeRunner {
+	return fakeGluster(t).
+		On("glusterfs 9.4\nRepository revision: git://git.gluster.org/glusterfs.git", nil, "gluster", "--version").
+		On(optionHelpXML, nil, "gluster", "volume", "set", "help-xml").
+		On(volumeGetXML, nil, "gluster", "volume", "get", "test", "all", "--xml")
+}
+
+func TestVolumeOptionSet(t *testing.T) {
+	f := fakeOptions(t).
+		On("volume set: success", nil, "gluster", "volume", "set", "test", "performance.cache-size").
+		On("volume set: failed: Another transaction is in progress for test.", errExit, "gluster", "volume", "set", "test", "nfs.disable")
+	var rsp VolumeOptionResponse
+	body := `{"volname": "test", "options": [{"name": "performance.cache-size", "value": "256MB"}, {"name": "nfs.disable", "value": "off"}]}`
+	if status := serve(t, ProcessVolumeOptionSet, body, &rsp); status != http.StatusConflict {
+		t.Errorf("status %d, want 409", status)
+	}
+	if len(rsp.Options) != 2 {
+		t.Fatalf("options = %+v", rsp.Options)
+	}
+	if o := rsp.Options[0]; o.Result != "OK" || o.Value != "256MB" || o.Default != "32MB" {
+		t.Errorf("cache-size = %+v", o)
+	}
+	if o := rsp.Options[1]; o.Result != "ERROR" || o.Error == nil || o.Error.Code != CodeTransactionInProgress || o.Value != "on" {
+		t.Errorf("nfs.disable = %+v", o)
+	}
+	if rsp.Error == nil || rsp.Error.Code != CodeTransactionInProgress {
+		t.Errorf("error = %+v", rsp.Error)
+	}
+	want := map[string]bool{
+		"gluster volume set test performance.cache-size 256MB": true,
+		"gluster volume set test nfs.disable off":              true,
+	}
+	for _, call := range f.Invocations() {
+		delete(want, call)
+	}
+	if len(want) != 0 {
+		t.Errorf("did not run %v", want)
+	}
+}
+
+func TestVolumeOptionGet(t *testing.T) {
+	fakeOptions(t)
+	var rsp VolumeOptionResponse
+	if status := serve(t, ProcessVolumeOptionGet, `{"volname": "test", "keys": ["cache-size", "nfs.disable"]}`, &rsp); status != http.StatusOK {
+		t.Fatalf("status %d: %+v", status, rsp.Error)
+	}
+	if len(rsp.Options) != 2 || rsp.Options[0].Name != "cache-size" || rsp.Options[0].Value != "256MB" || rsp.Options[0].Default != "32MB" {
+		t.Errorf("options = %+v", rsp.Options)
+	}
+
+	rsp = VolumeOptionResponse{}
+	if status := serve(t, ProcessVolumeOptionGet, `{"volname": "test", "keys": ["cache-sizes"]}`, &rsp); status != http.StatusNotFound {
+		t.Errorf("unknown key: status %d, want 404", status)
+	}
+
+	rsp = VolumeOptionResponse{}
+	if status := serve(t, ProcessVolumeOptionGet, `{"volname": "test"}`, &rsp); status != http.StatusOK || len(rsp.Options) != 3 {
+		t.Errorf("all options: status %d, %d options", status, len(rsp.Options))
+	}
+}
+
+func TestVolumeOptionReset(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		want []string
+	}{
+		{"all", `{"volname": "test"}`, []string{"gluster volume reset test"}},
+		{"keys", `{"volname": "test", "keys": ["performance.cache-size", "nfs.disable"], "force": "true"}`, []string{
+			"gluster volume reset test performance.cache-size force",
+			"gluster volume reset test nfs.disable force",
+		}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			f := fakeOptions(t).On("volume reset: success", nil, "gluster", "volume", "reset")
+			var rsp VolumeOptionResponse
+			if status := serve(t, ProcessVolumeOptionReset, test.body, &rsp); status != http.StatusOK {
+				t.Fatalf("status %d: %+v", status, rsp.Error)
+			}
+			var resets []string
+			for _, call := range f.Invocations() {
+				if strings.HasPrefix(call, "gluster volume reset") {
+					resets = append(resets, call)
+				}
+			}
+			if len(resets) != len(test.want) {
+				t.Fatalf("ran %q, want %q", resets, test.want)
+			}
+			for i := range test.want {
+				if resets[i] != test.want[i] {
+					t.Errorf("reset %d = %q, want %q", i, resets[i], test.want[i])
+				}
+			}
+		})
+	}
+}
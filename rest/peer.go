@@ -1,12 +1,8 @@
 package gluster
 
 import (
-	"encoding/json"
 	"encoding/xml"
-	"fmt"
-	"io/ioutil"
 	"net/http"
-	"os/exec"
 	"strings"
 
 	L "hualu.com/logger"
@@ -16,10 +12,7 @@ type CommonPeerRequest struct {
 	Hostname string `json:"hostname,omitempty"`
 }
 
-type CommonPeerResponse struct {
-	Result string `json:"result"`
-	Errors string `json:"errors,omitempty"`
-}
+type CommonPeerResponse = CommonResponse
 
 type PeerInfo struct {
 	UUID      string `json:"uuid"`
@@ -48,11 +41,12 @@ type PeerStatus struct {
 }
 
 type Peer struct {
-	UUID      string `xml:"uuid" json:"uuid"`
-	HostName  string `xml:"hostname" json:"hostname"`
-	Connected int    `xml:"connected" json:"connected"`
-	State     int    `xml:"state" json:"state"`
-	StateStr  string `xml:"stateStr" json:"status"`
+	UUID      string   `xml:"uuid" json:"uuid"`
+	HostName  string   `xml:"hostname" json:"hostname"`
+	HostNames []string `xml:"hostnames>hostname" json:"hostnames,omitempty"` // every name and address the peer was probed or is known by
+	Connected int      `xml:"connected" json:"connected"`
+	State     int      `xml:"state" json:"state"`
+	StateStr  string   `xml:"stateStr" json:"status"`
 }
 
 /*
@@ -66,45 +60,30 @@ curl -X POST http://127.0.0.1:7030/gluster/peer/add  -H 'Content-Type: applicati
 */
 func ProcessPeerAdd(w http.ResponseWriter, r *http.Request) {
 	var rsp CommonPeerResponse
-	body, e := ioutil.ReadAll(r.Body)
-	defer r.Body.Close()
-	if e != nil {
-		w.WriteHeader(500)
-		return
-	}
-	defer func() {
-		buf, e := json.Marshal(&rsp)
-		if e != nil {
-			w.WriteHeader(500)
-		}
-		w.Write([]byte(buf))
-	}()
+	defer writeResponse(w, &rsp)
 
 	// request
 	var req CommonPeerRequest
-	if e := json.Unmarshal(body, &req); e != nil {
-		rsp.Result = "ERROR"
-		rsp.Errors = e.Error()
+	if e := readRequest(r, &req); e != nil {
+		L.Gluster.Error(e.Error())
+		rsp.Fail(e)
 		return
 	}
 
-	// cmd
-	if len(req.Hostname) <= 0 {
-		rsp.Result = "ERROR"
-		rsp.Errors = "parameter is not valid"
+	release, e := lockForRequest(r, clusterLock, "peer probe")
+	if e != nil {
+		L.Gluster.Error(e.Error())
+		rsp.Fail(e)
 		return
 	}
+	defer release()
 
+	// cmd
 	//cmdString := fmt.Sprintf(`docker exec glusterfs sh -c "gluster peer probe %s" `, req.Hostname)
-	cmdString := fmt.Sprintf(`gluster peer probe %s `, req.Hostname)
-	L.Gluster.Info(cmdString)
-
-	cmd := exec.Command("sh", "-c", cmdString)
-	output, e := cmd.CombinedOutput()
+	output, e := runGluster(r.Context(), "peer", "probe", req.Hostname)
 	if e != nil {
 		L.Gluster.Error(string(output))
-		rsp.Result = "ERROR"
-		rsp.Errors = string(output)
+		rsp.Fail(glusterError(output, e))
 		return
 	}
 
@@ -124,44 +103,30 @@ curl -X POST http://127.0.0.1:7030/gluster/peer/delete -H 'Content-Type: applica
 */
 func ProcessPeerDelete(w http.ResponseWriter, r *http.Request) {
 	var rsp CommonPeerResponse
-	body, e := ioutil.ReadAll(r.Body)
-	defer r.Body.Close()
-	if e != nil {
-		w.WriteHeader(500)
-		return
-	}
-	defer func() {
-		buf, e := json.Marshal(&rsp)
-		if e != nil {
-			w.WriteHeader(500)
-		}
-		w.Write([]byte(buf))
-	}()
+	defer writeResponse(w, &rsp)
 
 	// request
 	var req CommonPeerRequest
-	if e := json.Unmarshal(body, &req); e != nil {
-		rsp.Result = "ERROR"
-		rsp.Errors = e.Error()
+	if e := readRequest(r, &req); e != nil {
+		L.Gluster.Error(e.Error())
+		rsp.Fail(e)
 		return
 	}
 
-	// cmd
-	if len(req.Hostname) <= 0 {
-		rsp.Result = "ERROR"
-		rsp.Errors = "parameter is not valid"
+	release, e := lockForRequest(r, clusterLock, "peer detach")
+	if e != nil {
+		L.Gluster.Error(e.Error())
+		rsp.Fail(e)
 		return
 	}
-	//cmdString := fmt.Sprintf(`/usr/bin/docker exec glusterfs sh -c "gluster peer detach %s" `, req.Hostname)
-	cmdString := fmt.Sprintf(`gluster peer detach %s <<<y`, req.Hostname)
-	L.Gluster.Info(cmdString)
+	defer release()
 
-	cmd := exec.Command("sh", "-c", cmdString)
-	output, e := cmd.CombinedOutput()
+	// cmd
+	//cmdString := fmt.Sprintf(`/usr/bin/docker exec glusterfs sh -c "gluster peer detach %s" `, req.Hostname)
+	output, e := runGlusterConfirm(r.Context(), "peer", "detach", req.Hostname)
 	if e != nil {
 		L.Gluster.Error(string(output))
-		rsp.Result = "ERROR"
-		rsp.Errors = string(output)
+		rsp.Fail(glusterError(output, e))
 		return
 	}
 
@@ -180,28 +145,14 @@ curl -X GET http://127.0.0.1:7030/gluster/peer/list
 func ProcessPeerList(w http.ResponseWriter, r *http.Request) {
 	var rsp PeerListResponse
 	var peers []PeerInfo
-
-	defer func() {
-		buf, e := json.Marshal(&rsp)
-		if e != nil {
-			w.WriteHeader(500)
-		}
-		w.Write([]byte(buf))
-	}()
-
-	// request
+	defer writeResponse(w, &rsp)
 
 	// cmd
 	//cmdString := fmt.Sprintf(`docker exec glusterfs sh -c "gluster pool list<<<y|awk NR!=1"`)
-	cmdString := fmt.Sprintf(`gluster pool list<<<y|awk NR!=1`)
-	L.Gluster.Info(cmdString)
-
-	cmd := exec.Command("sh", "-c", cmdString)
-	output, e := cmd.CombinedOutput()
+	output, e := runGlusterConfirm(r.Context(), "pool", "list")
 	if e != nil {
 		L.Gluster.Error(string(output))
-		rsp.Result = "ERROR"
-		rsp.Errors = string(output)
+		rsp.Fail(glusterError(output, e))
 		return
 	}
 
@@ -210,20 +161,17 @@ func ProcessPeerList(w http.ResponseWriter, r *http.Request) {
 	s := string(output)
 	s = strings.TrimSpace(s)
 	lines := strings.Split(s, "\n")
-	for _, line := range lines {
+	for _, line := range lines[1:] { // skip the header line
 		parts := strings.Fields(line)
 		if len(parts) != 3 { // Here is the pool info line
 			break
 		}
 		peerInfo = &PeerInfo{UUID: parts[0], Hostname: parts[1], State: parts[2], Localhost: false}
 		if parts[1] == "localhost" {
-			cmdString = fmt.Sprintf("hostname")
-			cmd := exec.Command("sh", "-c", "hostname")
-			output, e := cmd.Output()
+			output, e := run(r.Context(), Command{Name: "hostname"})
 			if e != nil {
 				L.Gluster.Error(e.Error())
-				rsp.Result = "ERROR"
-				rsp.Errors = e.Error()
+				rsp.Fail(glusterError(output, e))
 				return
 			}
 			hostname := string(output)
@@ -239,25 +187,14 @@ func ProcessPeerList(w http.ResponseWriter, r *http.Request) {
 
 func ProcessPeerStatus(w http.ResponseWriter, r *http.Request) {
 	var rsp PeerStatusResponse
-	defer func() {
-		buf, e := json.Marshal(&rsp)
-		if e != nil {
-			w.WriteHeader(500)
-			return
-		}
-		w.Write([]byte(buf))
-	}()
+	defer writeResponse(w, &rsp)
 
 	// run command in docker
 	//cmdString := fmt.Sprintf("docker exec glusterfs gluster peer status --xml")
-	cmdString := fmt.Sprintf("gluster peer status --xml")
-	L.Gluster.Info(cmdString)
-	cmd := exec.Command("sh", "-c", cmdString)
-	output, e := cmd.CombinedOutput()
+	output, e := runGluster(r.Context(), "peer", "status", "--xml")
 	if e != nil {
 		L.Gluster.Error(string(output))
-		rsp.Result = "ERROR"
-		rsp.Errors = string(output)
+		rsp.Fail(glusterError(output, e))
 		return
 	}
 
@@ -267,8 +204,8 @@ func ProcessPeerStatus(w http.ResponseWriter, r *http.Request) {
 	e = xml.Unmarshal(output, &peerStatusXML)
 	if e != nil {
 		L.Gluster.Error(e.Error())
-		rsp.Result = "ERROR"
-		rsp.Errors = e.Error()
+		rsp.Fail(e)
+		return
 	}
 
 	L.Gluster.Debug(peerStatusXML)
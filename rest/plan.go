@@ -0,0 +1,320 @@
+package gluster
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	L "hualu.com/logger"
+)
+
+// PlanCandidate is a directory that may hold a brick.
+type PlanCandidate struct {
+	Host string `json:"host"` // every connected peer when empty
+	Path string `json:"path"`
+	Size string `json:"size"` // capacity such as 4TB, needed to plan for a size
+}
+
+// VolumePlanRequest asks for a brick list for the layout of the embedded
+// create request. Its bricks are left empty, they are what gets planned.
+type VolumePlanRequest struct {
+	VolumeCreateRequest
+	Size          string                       `json:"size"` // usable size wanted, one set when empty
+	Candidates    []PlanCandidate              `json:"candidates"`
+	HostLabels    map[string]map[string]string `json:"host_labels"`    // e.g. {"node1": {"rack": "r1"}}
+	FailureDomain string                       `json:"failure_domain"` // label no set may repeat a value of, the host by default
+	Create        string                       `json:"create"`         // create the volume from the plan
+}
+
+// VolumePlan lists the bricks set by set, Bricks is the same list flattened
+// for /gluster/volume/create.
+type VolumePlan struct {
+	Layout     VolumeLayout `json:"layout"`
+	Sets       [][]string   `json:"sets"`
+	Bricks     []string     `json:"bricks"`
+	SizeUsable uint64       `json:"size_usable,omitempty"` // when the candidate sizes are known
+	Excluded   []string     `json:"excluded,omitempty"`    // candidates left out and why
+}
+
+type VolumePlanResponse struct {
+	CommonVolumeResponse
+	Plan *VolumePlan `json:"plan,omitempty"`
+}
+
+var sizeUnits = map[string]uint64{"": 1, "B": 1, "KB": SIZE_KB, "MB": SIZE_MB, "GB": SIZE_GB, "TB": SIZE_TB, "PB": SIZE_PB}
+
+// parseSize parses 512MB, 1.5TB or a byte count, units are powers of 1024.
+func parseSize(s string) (uint64, error) {
+	s = strings.ToUpper(strings.TrimSpace(s))
+	i := strings.IndexFunc(s, func(c rune) bool { return (c < '0' || c > '9') && c != '.' })
+	if i < 0 {
+		i = len(s)
+	}
+	n, e := strconv.ParseFloat(s[:i], 64)
+	unit, ok := sizeUnits[s[i:]]
+	if e != nil || !ok || n <= 0 {
+		return 0, fmt.Errorf("%q is not a size such as 512MB or 4TB", s)
+	}
+	return uint64(n * float64(unit)), nil
+}
+
+// connectedHosts returns the names of the connected peers, this node included.
+func connectedHosts(ctx context.Context) ([]string, error) {
+	output, e := runGluster(ctx, "pool", "list", "--xml")
+	if e != nil {
+		return nil, glusterError(output, e)
+	}
+	var pool PeerStatusXML
+	if e := xml.Unmarshal(output, &pool); e != nil {
+		return nil, glusterError(output, e)
+	}
+	var hosts []string
+	for _, peer := range pool.PeerStatus.Peers {
+		if peer.Connected != 1 {
+			continue
+		}
+		host := peer.HostName
+		if host == "localhost" {
+			output, e := run(ctx, Command{Name: "hostname"})
+			if e != nil {
+				return nil, glusterError(output, e)
+			}
+			host = strings.TrimSpace(string(output))
+		}
+		hosts = append(hosts, host)
+	}
+	return hosts, nil
+}
+
+type planBrick struct {
+	name   string
+	host   string
+	domain string
+	size   uint64
+}
+
+// planBricks groups bricks into sets on distinct failure domains. Each set
+// takes a brick from the domains with the most bricks left, which keeps as
+// many sets possible as long as it can, and within a domain from its least
+// used host. It stops once the sets reach size, or after one set when size
+// is 0.
+func planBricks(layout VolumeLayout, bricks []planBrick, size uint64) ([][]planBrick, uint64, error) {
+	domains := make(map[string][]planBrick)
+	var names []string
+	for _, b := range bricks {
+		if _, ok := domains[b.domain]; !ok {
+			names = append(names, b.domain)
+		}
+		domains[b.domain] = append(domains[b.domain], b)
+	}
+	hostUse := make(map[string]int)
+
+	var sets [][]planBrick
+	var usable uint64
+	for {
+		sort.SliceStable(names, func(i, j int) bool {
+			if len(domains[names[i]]) != len(domains[names[j]]) {
+				return len(domains[names[i]]) > len(domains[names[j]])
+			}
+			return names[i] < names[j]
+		})
+		if len(names) < layout.SetSize || len(domains[names[layout.SetSize-1]]) == 0 {
+			break
+		}
+
+		set := make([]planBrick, 0, layout.SetSize)
+		for _, domain := range names[:layout.SetSize] {
+			candidates := domains[domain]
+			pick := 0
+			for i, c := range candidates {
+				if hostUse[c.host] < hostUse[candidates[pick].host] {
+					pick = i
+				}
+			}
+			set = append(set, candidates[pick])
+			hostUse[candidates[pick].host]++
+			domains[domain] = append(candidates[:pick:pick], candidates[pick+1:]...)
+		}
+		sets = append(sets, set)
+		usable += setUsable(layout, set)
+
+		if size == 0 || usable >= size {
+			return sets, usable, nil
+		}
+	}
+
+	if size == 0 {
+		return nil, 0, NewError(http.StatusConflict, CodeInsufficientBricks,
+			"a %s set of %d bricks needs as many failure domains, the candidates are in %d",
+			layout.Type, layout.SetSize, len(names))
+	}
+	return nil, 0, NewError(http.StatusConflict, CodeInsufficientBricks,
+		"the candidates fit %d %s sets of %d bytes, %d bytes were asked", len(sets), layout.Type, usable, size)
+}
+
+// setUsable is the capacity of a set, its smallest data brick times the data
+// bricks of the layout.
+func setUsable(layout VolumeLayout, set []planBrick) uint64 {
+	data := set
+	if layout.Arbiter {
+		data = set[:len(set)-1]
+	}
+	min := data[0].size
+	for _, b := range data[1:] {
+		if b.size < min {
+			min = b.size
+		}
+	}
+	return min * uint64(layout.DataBricks)
+}
+
+// planVolume expands the candidates onto the connected peers, leaves out the
+// bricks of existing volumes and plans the sets.
+func planVolume(ctx context.Context, req VolumePlanRequest) (*VolumePlan, error) {
+	layout, err := req.Layout()
+	if err != nil {
+		return nil, err
+	}
+	plan := &VolumePlan{Layout: layout, Sets: [][]string{}, Bricks: []string{}}
+
+	hosts, e := connectedHosts(ctx)
+	if e != nil {
+		return nil, e
+	}
+	connected := make(map[string]bool)
+	for _, host := range hosts {
+		connected[host] = true
+	}
+	volumes, e := volumesInfo(ctx, "all")
+	if e != nil {
+		return nil, e
+	}
+	used := make(map[string]string)
+	for _, volume := range volumes {
+		for _, b := range volume.Bricks {
+			used[b.Name] = volume.Name
+		}
+	}
+
+	var bricks []planBrick
+	seen := make(map[string]bool)
+	for _, c := range req.Candidates {
+		candidateHosts := []string{c.Host}
+		if c.Host == "" {
+			candidateHosts = hosts
+		}
+		var size uint64
+		if c.Size != "" {
+			size, _ = parseSize(c.Size) // checked by Validate
+		}
+		for _, host := range candidateHosts {
+			name := host + ":" + c.Path
+			switch {
+			case seen[name]:
+				continue
+			case !connected[host]:
+				plan.Excluded = append(plan.Excluded, name+": host is not a connected peer")
+				continue
+			case used[name] != "":
+				plan.Excluded = append(plan.Excluded, name+": brick of volume "+used[name])
+				continue
+			}
+			seen[name] = true
+
+			domain := "host " + host
+			if req.FailureDomain != "" {
+				if value, ok := req.HostLabels[host][req.FailureDomain]; ok {
+					domain = req.FailureDomain + " " + value
+				}
+			}
+			bricks = append(bricks, planBrick{name: name, host: host, domain: domain, size: size})
+		}
+	}
+
+	var wanted uint64
+	if req.Size != "" {
+		wanted, _ = parseSize(req.Size)
+		if layout.DataBricks == 0 {
+			return nil, invalid("redundancy", "", "is needed to plan a disperse volume for a size")
+		}
+		for _, b := range bricks {
+			if b.size == 0 {
+				return nil, invalid("candidates", b.name, "has no size, every candidate needs one to plan for a size")
+			}
+		}
+	}
+
+	sets, usable, e := planBricks(layout, bricks, wanted)
+	if e != nil {
+		return nil, e
+	}
+	for _, set := range sets {
+		var names []string
+		for _, b := range set {
+			names = append(names, b.name)
+		}
+		plan.Sets = append(plan.Sets, names)
+		plan.Bricks = append(plan.Bricks, names...)
+	}
+	if wanted > 0 {
+		plan.SizeUsable = usable
+	}
+	return plan, nil
+}
+
+/*
+[example]
+curl -X POST http://127.0.0.1:7030/gluster/volume/plan -H 'Content-Type: application/json' -d '{
+"volname": "test",
+"replica": 3,
+"size": "8TB",
+"candidates": [{"path": "/data/brick1/test", "size": "4TB"}, {"path": "/data/brick2/test", "size": "4TB"}],
+"host_labels": {"node1": {"rack": "r1"}, "node2": {"rack": "r2"}, "node3": {"rack": "r3"}},
+"failure_domain": "rack"
+}'
+<- {"result":"OK","plan":{"layout":{"type":"replicate","set_size":3,"data_bricks":1},
+"sets":[["node1:/data/brick1/test","node2:/data/brick1/test","node3:/data/brick1/test"],["node1:/data/brick2/test","node2:/data/brick2/test","node3:/data/brick2/test"]],
+"bricks":["node1:/data/brick1/test",...],"size_usable":8796093022208}}
+*/
+func ProcessVolumePlan(w http.ResponseWriter, r *http.Request) {
+	var rsp VolumePlanResponse
+	defer writeResponse(w, &rsp)
+
+	// analyze request
+	var req VolumePlanRequest
+	if e := readRequest(r, &req); e != nil {
+		L.Gluster.Error(e.Error())
+		rsp.Fail(e)
+		return
+	}
+
+	plan, e := planVolume(r.Context(), req)
+	if e != nil {
+		L.Gluster.Error(e.Error())
+		rsp.Fail(e)
+		return
+	}
+	rsp.Plan = plan
+
+	if req.Create == "true" {
+		createReq := req.VolumeCreateRequest
+		createReq.Bricks = plan.Bricks
+		if e := createReq.Validate(); e != nil {
+			L.Gluster.Error(e.Error())
+			rsp.Fail(e)
+			return
+		}
+		job, e := startCreate(r, createReq)
+		if e != nil {
+			L.Gluster.Error(e.Error())
+			rsp.Fail(e)
+			return
+		}
+		rsp.JobID = job.ID
+	}
+	rsp.Result = "OK"
+}
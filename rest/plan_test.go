@@ -0,0 +1,163 @@
+package gluster
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+
+	"hualu.com/gluster-rest/parser"
+)
+
+func TestParseSize(t *testing.T) {
+	tests := []struct {
+		s    string
+		size uint64
+		ok   bool
+	}{
+		{"512MB", 512 * SIZE_MB, true},
+		{"1.5tb", SIZE_TB + SIZE_TB/2, true},
+		{"4096", 4096, true},
+		{" 2GB ", 2 * SIZE_GB, true},
+		{"0GB", 0, false},
+		{"4 TB", 0, false},
+		{"4XB", 0, false},
+		{"TB", 0, false},
+	}
+	for _, test := range tests {
+		size, e := parseSize(test.s)
+		if (e == nil) != test.ok || size != test.size {
+			t.Errorf("%q = %d, %v, want %d ok %v", test.s, size, e, test.size, test.ok)
+		}
+	}
+}
+
+// candidates returns a brick of size on each host for each path, the
+// failure domain of a host is its rack when racks has it.
+func candidates(hosts []string, racks map[string]string, size uint64, paths ...string) []planBrick {
+	var bricks []planBrick
+	for _, path := range paths {
+		for _, host := range hosts {
+			domain := "host " + host
+			if rack, ok := racks[host]; ok {
+				domain = "rack " + rack
+			}
+			bricks = append(bricks, planBrick{name: host + ":" + path, host: host, domain: domain, size: size})
+		}
+	}
+	return bricks
+}
+
+func TestPlanBricks(t *testing.T) {
+	replica3 := VolumeLayout{Type: parser.SubvolumeReplicate, SetSize: 3, DataBricks: 1}
+	arbiter := VolumeLayout{Type: parser.SubvolumeReplicate, SetSize: 3, Arbiter: true, DataBricks: 1}
+	disperse := VolumeLayout{Type: parser.SubvolumeDisperse, SetSize: 3, DataBricks: 2}
+	three := []string{"node1", "node2", "node3"}
+	tests := []struct {
+		name   string
+		layout VolumeLayout
+		bricks []planBrick
+		size   uint64
+		sets   string // sets separated by "; "
+		usable uint64
+		code   string
+	}{
+		{"one set", replica3, candidates(three, nil, 0, "/data/b1", "/data/b2"), 0,
+			"node1:/data/b1 node2:/data/b1 node3:/data/b1", 0, ""},
+		{"sets for a size", replica3, candidates(three, nil, 4*SIZE_TB, "/data/b1", "/data/b2", "/data/b3"), 6 * SIZE_TB,
+			"node1:/data/b1 node2:/data/b1 node3:/data/b1; node1:/data/b2 node2:/data/b2 node3:/data/b2", 8 * SIZE_TB, ""},
+		{"hosts spread", replica3, candidates([]string{"node1", "node2", "node3", "node4"}, nil, SIZE_TB, "/data/b1"), 0,
+			"node1:/data/b1 node2:/data/b1 node3:/data/b1", 0, ""},
+		{"racks spread", replica3, candidates([]string{"node1", "node2", "node3", "node4"}, map[string]string{"node1": "r1", "node2": "r1", "node3": "r2", "node4": "r3"}, 0, "/data/b1"), 0,
+			"node1:/data/b1 node3:/data/b1 node4:/data/b1", 0, ""},
+		{"least used host of a rack", replica3, candidates([]string{"node1", "node2", "node3", "node4"}, map[string]string{"node1": "r1", "node2": "r1", "node3": "r2", "node4": "r3"}, SIZE_TB, "/data/b1", "/data/b2"), 2 * SIZE_TB,
+			"node1:/data/b1 node3:/data/b1 node4:/data/b1; node2:/data/b1 node3:/data/b2 node4:/data/b2", 2 * SIZE_TB, ""},
+		{"too few racks", replica3, candidates(three, map[string]string{"node1": "r1", "node2": "r1", "node3": "r2"}, 0, "/data/b1", "/data/b2"), 0,
+			"", 0, CodeInsufficientBricks},
+		{"too few hosts", replica3, candidates([]string{"node1", "node2"}, nil, 0, "/data/b1", "/data/b2"), 0,
+			"", 0, CodeInsufficientBricks},
+		{"too small", replica3, candidates(three, nil, SIZE_TB, "/data/b1", "/data/b2"), 3 * SIZE_TB,
+			"", 0, CodeInsufficientBricks},
+		{"arbiter brick left out of the size", arbiter, []planBrick{
+			{name: "node1:/data/b1", host: "node1", domain: "host node1", size: 4 * SIZE_TB},
+			{name: "node2:/data/b1", host: "node2", domain: "host node2", size: 2 * SIZE_TB},
+			{name: "node3:/data/b1", host: "node3", domain: "host node3", size: SIZE_GB},
+		}, SIZE_TB, "node1:/data/b1 node2:/data/b1 node3:/data/b1", 2 * SIZE_TB, ""},
+		{"disperse data bricks", disperse, candidates(three, nil, SIZE_TB, "/data/b1"), SIZE_TB,
+			"node1:/data/b1 node2:/data/b1 node3:/data/b1", 2 * SIZE_TB, ""},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			sets, usable, e := planBricks(test.layout, test.bricks, test.size)
+			if test.code != "" {
+				if err, ok := e.(*Error); !ok || err.Code != test.code {
+					t.Errorf("got %v, want %s", e, test.code)
+				}
+				return
+			}
+			if e != nil {
+				t.Fatal(e)
+			}
+			var got []string
+			for _, set := range sets {
+				var names []string
+				for _, b := range set {
+					names = append(names, b.name)
+				}
+				got = append(got, strings.Join(names, " "))
+			}
+			if strings.Join(got, "; ") != test.sets {
+				t.Errorf("sets = %q, want %q", strings.Join(got, "; "), test.sets)
+			}
+			if test.size > 0 && usable != test.usable {
+				t.Errorf("usable = %d, want %d", usable, test.usable)
+			}
+		})
+	}
+}
+
+// planPoolXML is `pool list --xml` of node1, listed as localhost, node2 and
+// node3 connected and node4 not.
+const planPoolXML = `<cliOutput><opRet>0</opRet><peerStatus>` +
+	`<peer><uuid>p2</uuid><hostname>node2</hostname><connected>1</connected></peer>` +
+	`<peer><uuid>p3</uuid><hostname>node3</hostname><connected>1</connected></peer>` +
+	`<peer><uuid>p4</uuid><hostname>node4</hostname><connected>0</connected></peer>` +
+	`<peer><uuid>p1</uuid><hostname>localhost</hostname><connected>1</connected></peer>` +
+	`</peerStatus></cliOutput>`
+
+func TestVolumePlan(t *testing.T) {
+	body := `{"volname": "test", "replica": 2, "candidates": [{"path": "/data/brick1/test"}, {"host": "node4", "path": "/data/brick1/test"}], "create": "%s"}`
+	for _, create := range []string{"false", "true"} {
+		t.Run("create "+create, func(t *testing.T) {
+			f := fakeGluster(t).
+				On(planPoolXML, nil, "gluster", "pool", "list").
+				On("node1\n", nil, "hostname").
+				On(volumeInfoXML("other", "1", "node3:/data/brick1/test"), nil, "gluster", "volume", "info", "all")
+			var rsp VolumePlanResponse
+			if status := serve(t, ProcessVolumePlan, fmt.Sprintf(body, create), &rsp); status != http.StatusOK {
+				t.Fatalf("status %d: %+v", status, rsp.Error)
+			}
+			plan := rsp.Plan
+			if plan == nil || strings.Join(plan.Bricks, " ") != "node1:/data/brick1/test node2:/data/brick1/test" || len(plan.Sets) != 1 {
+				t.Fatalf("plan = %+v", plan)
+			}
+			if want := []string{"node3:/data/brick1/test: brick of volume other", "node4:/data/brick1/test: host is not a connected peer"}; strings.Join(plan.Excluded, ", ") != strings.Join(want, ", ") {
+				t.Errorf("excluded = %q, want %q", plan.Excluded, want)
+			}
+
+			if create == "false" {
+				if rsp.JobID != "" {
+					t.Errorf("started job %s for a plan", rsp.JobID)
+				}
+				return
+			}
+			if job := waitJob(t, rsp.JobID); job.State != JobSucceeded {
+				t.Errorf("create job = %+v", job)
+			}
+			calls := f.Invocations()
+			if len(calls) < 2 || calls[len(calls)-2] != "gluster volume create test replica 2 node1:/data/brick1/test node2:/data/brick1/test" {
+				t.Errorf("ran %q", calls)
+			}
+		})
+	}
+}
@@ -0,0 +1,412 @@
+package gluster
+
+import (
+	"context"
+	"net/http"
+
+	"hualu.com/gluster-rest/parser"
+	L "hualu.com/logger"
+)
+
+// VolumeQuotaListRequest lists the limits of Paths, or every limit when empty.
+type VolumeQuotaListRequest struct {
+	CommonVolumeRequest
+	Paths []string `json:"paths"`
+}
+
+// Paths of quota requests are directories inside the volume, "/" is its root.
+type VolumeQuotaPathRequest struct {
+	CommonVolumeRequest
+	Path string `json:"path"`
+}
+
+type VolumeQuotaLimitRequest struct {
+	VolumeQuotaPathRequest
+	SoftLimit string `json:"soft_limit"` // percent of the limit, the volume default when empty
+}
+
+type VolumeQuotaUsageRequest struct {
+	VolumeQuotaLimitRequest
+	Size string `json:"size"` // 10GB, 512MB, bytes when no unit
+}
+
+type VolumeQuotaObjectsRequest struct {
+	VolumeQuotaLimitRequest
+	Count string `json:"count"` // files and directories
+}
+
+type VolumeQuotaRemoveRequest struct {
+	VolumeQuotaPathRequest
+	Objects string `json:"objects"` // true removes the object limit instead of the usage limit
+}
+
+type VolumeQuotaSoftLimitRequest struct {
+	CommonVolumeRequest
+	SoftLimit string `json:"soft_limit"` // percent
+}
+
+type VolumeQuotaAlertTimeRequest struct {
+	CommonVolumeRequest
+	AlertTime string `json:"alert_time"` // 30s, 10m, 2h, 1d, 1w
+}
+
+type VolumeQuotaResponse struct {
+	CommonResponse
+	Limits []parser.QuotaLimit `json:"limits,omitempty"`
+}
+
+type VolumeQuotaObjectsResponse struct {
+	CommonResponse
+	Limits []parser.QuotaObjectLimit `json:"limits,omitempty"`
+}
+
+// runQuota runs `volume quota <vol> <args...> --xml` under the lock of the volume.
+func runQuota(r *http.Request, volname string, confirm bool, args ...string) error {
+	release, e := lockForRequest(r, volumeLock(volname), "quota "+args[0])
+	if e != nil {
+		return e
+	}
+	defer release()
+
+	args = append(append([]string{"volume", "quota", volname}, args...), "--xml")
+	var output []byte
+	if confirm {
+		output, e = runGlusterConfirm(r.Context(), args...)
+	} else {
+		output, e = runGluster(r.Context(), args...)
+	}
+	if e != nil {
+		L.Gluster.Error(string(output))
+		return glusterError(output, e)
+	}
+	return nil
+}
+
+// quotaLimits returns the usage limits of paths, every limit when empty.
+func quotaLimits(ctx context.Context, volname string, paths ...string) ([]parser.QuotaLimit, error) {
+	args := append([]string{"volume", "quota", volname, "list"}, paths...)
+	output, e := runGluster(ctx, append(args, "--xml")...)
+	if e != nil {
+		L.Gluster.Error(string(output))
+		return nil, glusterError(output, e)
+	}
+	limits, e := parser.ParseQuotaList(output)
+	if e != nil {
+		return nil, glusterError(output, e)
+	}
+	return limits, nil
+}
+
+// quotaObjectLimits returns the object limits of paths, every limit when empty.
+func quotaObjectLimits(ctx context.Context, volname string, paths ...string) ([]parser.QuotaObjectLimit, error) {
+	args := append([]string{"volume", "quota", volname, "list-objects"}, paths...)
+	output, e := runGluster(ctx, append(args, "--xml")...)
+	if e != nil {
+		L.Gluster.Error(string(output))
+		return nil, glusterError(output, e)
+	}
+	limits, e := parser.ParseQuotaObjects(output)
+	if e != nil {
+		return nil, glusterError(output, e)
+	}
+	return limits, nil
+}
+
+/*
+[example]
+docker exec glusterfs gluster volume quota test enable --xml
+
+curl -X POST http://127.0.0.1:7030/gluster/volume/quota/enable -H 'Content-Type: application/json' -d '{
+"volname": "test"
+}'
+<- {"result":"OK"}
+*/
+func ProcessVolumeQuotaEnable(w http.ResponseWriter, r *http.Request) {
+	var rsp CommonVolumeResponse
+	defer writeResponse(w, &rsp)
+
+	// analyze request
+	var req CommonVolumeRequest
+	if e := readRequest(r, &req); e != nil {
+		L.Gluster.Error(e.Error())
+		rsp.Fail(e)
+		return
+	}
+
+	if e := runQuota(r, req.Volname, false, "enable"); e != nil {
+		L.Gluster.Error(e.Error())
+		rsp.Fail(e)
+		return
+	}
+	rsp.Result = "OK"
+}
+
+/*
+[example]
+docker exec glusterfs sh -c "gluster volume quota test disable --xml <<< y"
+
+curl -X POST http://127.0.0.1:7030/gluster/volume/quota/disable -H 'Content-Type: application/json' -d '{
+"volname": "test"
+}'
+<- {"result":"OK"}
+*/
+func ProcessVolumeQuotaDisable(w http.ResponseWriter, r *http.Request) {
+	var rsp CommonVolumeResponse
+	defer writeResponse(w, &rsp)
+
+	// analyze request
+	var req CommonVolumeRequest
+	if e := readRequest(r, &req); e != nil {
+		L.Gluster.Error(e.Error())
+		rsp.Fail(e)
+		return
+	}
+
+	// disabling drops every limit of the volume, the CLI asks first
+	if e := runQuota(r, req.Volname, true, "disable"); e != nil {
+		L.Gluster.Error(e.Error())
+		rsp.Fail(e)
+		return
+	}
+	rsp.Result = "OK"
+}
+
+/*
+[example]
+docker exec glusterfs gluster volume quota test limit-usage /data 10GB 80% --xml
+
+curl -X POST http://127.0.0.1:7030/gluster/volume/quota/limit-usage -H 'Content-Type: application/json' -d '{
+"volname": "test",
+"path": "/data",
+"size": "10GB",
+"soft_limit": "80%"
+}'
+<- {"result":"OK","limits":[{"path":"/data","hard_limit":10737418240,"soft_limit_percent":"80%","soft_limit":8589934592,"used":0,"available":10737418240,"soft_limit_exceeded":false,"exceeded":false}]}
+*/
+func ProcessVolumeQuotaLimitUsage(w http.ResponseWriter, r *http.Request) {
+	var rsp VolumeQuotaResponse
+	defer writeResponse(w, &rsp)
+
+	// analyze request
+	var req VolumeQuotaUsageRequest
+	if e := readRequest(r, &req); e != nil {
+		L.Gluster.Error(e.Error())
+		rsp.Fail(e)
+		return
+	}
+
+	args := []string{"limit-usage", req.Path, req.Size}
+	if req.SoftLimit != "" {
+		args = append(args, req.SoftLimit)
+	}
+	if e := runQuota(r, req.Volname, false, args...); e != nil {
+		L.Gluster.Error(e.Error())
+		rsp.Fail(e)
+		return
+	}
+
+	// the limit is set, only the report is missing if listing fails
+	limits, e := quotaLimits(r.Context(), req.Volname, req.Path)
+	if e != nil {
+		L.Gluster.Error(e.Error())
+	}
+	rsp.Limits = limits
+	rsp.Result = "OK"
+}
+
+/*
+[example]
+docker exec glusterfs gluster volume quota test limit-objects /data 100000 --xml
+
+curl -X POST http://127.0.0.1:7030/gluster/volume/quota/limit-objects -H 'Content-Type: application/json' -d '{
+"volname": "test",
+"path": "/data",
+"count": "100000"
+}'
+<- {"result":"OK","limits":[{"path":"/data","hard_limit":100000,"soft_limit_percent":"80%","soft_limit":80000,"file_count":0,"dir_count":1,"available":99999,"soft_limit_exceeded":false,"exceeded":false}]}
+*/
+func ProcessVolumeQuotaLimitObjects(w http.ResponseWriter, r *http.Request) {
+	var rsp VolumeQuotaObjectsResponse
+	defer writeResponse(w, &rsp)
+
+	// analyze request
+	var req VolumeQuotaObjectsRequest
+	if e := readRequest(r, &req); e != nil {
+		L.Gluster.Error(e.Error())
+		rsp.Fail(e)
+		return
+	}
+
+	args := []string{"limit-objects", req.Path, req.Count}
+	if req.SoftLimit != "" {
+		args = append(args, req.SoftLimit)
+	}
+	if e := runQuota(r, req.Volname, false, args...); e != nil {
+		L.Gluster.Error(e.Error())
+		rsp.Fail(e)
+		return
+	}
+
+	limits, e := quotaObjectLimits(r.Context(), req.Volname, req.Path)
+	if e != nil {
+		L.Gluster.Error(e.Error())
+	}
+	rsp.Limits = limits
+	rsp.Result = "OK"
+}
+
+/*
+[example]
+docker exec glusterfs gluster volume quota test remove /data --xml
+
+curl -X POST http://127.0.0.1:7030/gluster/volume/quota/remove -H 'Content-Type: application/json' -d '{
+"volname": "test",
+"path": "/data"
+}'
+<- {"result":"OK"}
+*/
+func ProcessVolumeQuotaRemove(w http.ResponseWriter, r *http.Request) {
+	var rsp CommonVolumeResponse
+	defer writeResponse(w, &rsp)
+
+	// analyze request
+	var req VolumeQuotaRemoveRequest
+	if e := readRequest(r, &req); e != nil {
+		L.Gluster.Error(e.Error())
+		rsp.Fail(e)
+		return
+	}
+
+	op := "remove"
+	if req.Objects == "true" {
+		op = "remove-objects"
+	}
+	if e := runQuota(r, req.Volname, false, op, req.Path); e != nil {
+		L.Gluster.Error(e.Error())
+		rsp.Fail(e)
+		return
+	}
+	rsp.Result = "OK"
+}
+
+/*
+[example]
+docker exec glusterfs gluster volume quota test list --xml
+
+curl -X POST http://127.0.0.1:7030/gluster/volume/quota/list -H 'Content-Type: application/json' -d '{
+"volname": "test"
+}'
+<- {"result":"OK","limits":[{"path":"/data","hard_limit":10737418240,"soft_limit_percent":"80%","soft_limit":8589934592,"used":9663676416,"available":1073741824,"soft_limit_exceeded":true,"exceeded":false}]}
+*/
+func ProcessVolumeQuotaList(w http.ResponseWriter, r *http.Request) {
+	var rsp VolumeQuotaResponse
+	defer writeResponse(w, &rsp)
+
+	// analyze request
+	var req VolumeQuotaListRequest
+	if e := readRequest(r, &req); e != nil {
+		L.Gluster.Error(e.Error())
+		rsp.Fail(e)
+		return
+	}
+
+	limits, e := quotaLimits(r.Context(), req.Volname, req.Paths...)
+	if e != nil {
+		L.Gluster.Error(e.Error())
+		rsp.Fail(e)
+		return
+	}
+	rsp.Limits = limits
+	rsp.Result = "OK"
+}
+
+/*
+[example]
+docker exec glusterfs gluster volume quota test list-objects --xml
+
+curl -X POST http://127.0.0.1:7030/gluster/volume/quota/list-objects -H 'Content-Type: application/json' -d '{
+"volname": "test"
+}'
+<- {"result":"OK","limits":[{"path":"/data","hard_limit":100000,"soft_limit_percent":"80%","soft_limit":80000,"file_count":120,"dir_count":4,"available":99876,"soft_limit_exceeded":false,"exceeded":false}]}
+*/
+func ProcessVolumeQuotaListObjects(w http.ResponseWriter, r *http.Request) {
+	var rsp VolumeQuotaObjectsResponse
+	defer writeResponse(w, &rsp)
+
+	// analyze request
+	var req VolumeQuotaListRequest
+	if e := readRequest(r, &req); e != nil {
+		L.Gluster.Error(e.Error())
+		rsp.Fail(e)
+		return
+	}
+
+	limits, e := quotaObjectLimits(r.Context(), req.Volname, req.Paths...)
+	if e != nil {
+		L.Gluster.Error(e.Error())
+		rsp.Fail(e)
+		return
+	}
+	rsp.Limits = limits
+	rsp.Result = "OK"
+}
+
+/*
+[example]
+docker exec glusterfs gluster volume quota test default-soft-limit 90% --xml
+
+curl -X POST http://127.0.0.1:7030/gluster/volume/quota/default-soft-limit -H 'Content-Type: application/json' -d '{
+"volname": "test",
+"soft_limit": "90%"
+}'
+<- {"result":"OK"}
+*/
+func ProcessVolumeQuotaDefaultSoftLimit(w http.ResponseWriter, r *http.Request) {
+	var rsp CommonVolumeResponse
+	defer writeResponse(w, &rsp)
+
+	// analyze request
+	var req VolumeQuotaSoftLimitRequest
+	if e := readRequest(r, &req); e != nil {
+		L.Gluster.Error(e.Error())
+		rsp.Fail(e)
+		return
+	}
+
+	if e := runQuota(r, req.Volname, false, "default-soft-limit", req.SoftLimit); e != nil {
+		L.Gluster.Error(e.Error())
+		rsp.Fail(e)
+		return
+	}
+	rsp.Result = "OK"
+}
+
+/*
+[example]
+docker exec glusterfs gluster volume quota test alert-time 1d --xml
+
+curl -X POST http://127.0.0.1:7030/gluster/volume/quota/alert-time -H 'Content-Type: application/json' -d '{
+"volname": "test",
+"alert_time": "1d"
+}'
+<- {"result":"OK"}
+*/
+func ProcessVolumeQuotaAlertTime(w http.ResponseWriter, r *http.Request) {
+	var rsp CommonVolumeResponse
+	defer writeResponse(w, &rsp)
+
+	// analyze request
+	var req VolumeQuotaAlertTimeRequest
+	if e := readRequest(r, &req); e != nil {
+		L.Gluster.Error(e.Error())
+		rsp.Fail(e)
+		return
+	}
+
+	if e := runQuota(r, req.Volname, false, "alert-time", req.AlertTime); e != nil {
+		L.Gluster.Error(e.Error())
+		rsp.Fail(e)
+		return
+	}
+	rsp.Result = "OK"
+}
@@ -0,0 +1,94 @@
+package gluster
+
+import (
+	"net/http"
+	"testing"
+)
+
+const quotaListXML = `<cliOutput><opRet>0</opRet><opErrno>0</opErrno><opErrstr/><volQuota><limit>` +
+	`<path>/data</path><hard_limit>10737418240</hard_limit><soft_limit_percent>80%</soft_limit_percent>` +
+	`<soft_limit_value>8589934592</soft_limit_value><used_space>0</used_space><avail_space>10737418240</avail_space>` +
+	`<sl_exceeded>No</sl_exceeded><hl_exceeded>No</hl_exceeded></limit></volQuota></cliOutput>`
+
+func TestVolumeQuota(t *testing.T) {
+	tests := []struct {
+		name    string
+		handler http.HandlerFunc
+		body    string
+		want    []string
+		confirm bool
+	}{
+		{"enable", ProcessVolumeQuotaEnable, `{"volname": "test"}`, []string{
+			"gluster volume quota test enable --xml",
+		}, false},
+		{"disable", ProcessVolumeQuotaDisable, `{"volname": "test"}`, []string{
+			"gluster volume quota test disable --xml",
+		}, true},
+		{"limit-usage", ProcessVolumeQuotaLimitUsage, `{"volname": "test", "path": "/data", "size": "10GB", "soft_limit": "80%"}`, []string{
+			"gluster volume quota test limit-usage /data 10GB 80% --xml",
+			"gluster volume quota test list /data --xml",
+		}, false},
+		{"limit-usage default soft limit", ProcessVolumeQuotaLimitUsage, `{"volname": "test", "path": "/", "size": "512MB"}`, []string{
+			"gluster volume quota test limit-usage / 512MB --xml",
+			"gluster volume quota test list / --xml",
+		}, false},
+		{"limit-objects", ProcessVolumeQuotaLimitObjects, `{"volname": "test", "path": "/data", "count": "100000"}`, []string{
+			"gluster volume quota test limit-objects /data 100000 --xml",
+			"gluster volume quota test list-objects /data --xml",
+		}, false},
+		{"remove", ProcessVolumeQuotaRemove, `{"volname": "test", "path": "/data"}`, []string{
+			"gluster volume quota test remove /data --xml",
+		}, false},
+		{"remove-objects", ProcessVolumeQuotaRemove, `{"volname": "test", "path": "/data", "objects": "true"}`, []string{
+			"gluster volume quota test remove-objects /data --xml",
+		}, false},
+		{"list", ProcessVolumeQuotaList, `{"volname": "test", "paths": ["/data", "/home"]}`, []string{
+			"gluster volume quota test list /data /home --xml",
+		}, false},
+		{"default-soft-limit", ProcessVolumeQuotaDefaultSoftLimit, `{"volname": "test", "soft_limit": "90%"}`, []string{
+			"gluster volume quota test default-soft-limit 90% --xml",
+		}, false},
+		{"alert-time", ProcessVolumeQuotaAlertTime, `{"volname": "test", "alert_time": "1d"}`, []string{
+			"gluster volume quota test alert-time 1d --xml",
+		}, false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			f := fakeGluster(t)
+			f.Default = FakeResponse{Output: `<cliOutput><opRet>0</opRet><opErrno>0</opErrno><opErrstr/></cliOutput>`}
+			f.On(quotaListXML, nil, "gluster", "volume", "quota", "test", "list")
+			var rsp CommonResponse
+			if status := serve(t, test.handler, test.body, &rsp); status != http.StatusOK {
+				t.Fatalf("status %d: %+v", status, rsp.Error)
+			}
+			checkCalls(t, f, test.want)
+			if confirm := f.Calls[0].Stdin == "y\n"; confirm != test.confirm {
+				t.Errorf("confirm %v, want %v", confirm, test.confirm)
+			}
+		})
+	}
+}
+
+func TestVolumeQuotaLimitUsageReport(t *testing.T) {
+	f := fakeGluster(t)
+	f.Default = FakeResponse{Output: `<cliOutput><opRet>0</opRet><opErrno>0</opErrno><opErrstr/></cliOutput>`}
+	f.On(quotaListXML, nil, "gluster", "volume", "quota", "test", "list")
+	var rsp VolumeQuotaResponse
+	if status := serve(t, ProcessVolumeQuotaLimitUsage, `{"volname": "test", "path": "/data", "size": "10GB"}`, &rsp); status != http.StatusOK {
+		t.Fatalf("status %d: %+v", status, rsp.Error)
+	}
+	if len(rsp.Limits) != 1 || rsp.Limits[0].Path != "/data" || rsp.Limits[0].HardLimit != 10737418240 || rsp.Limits[0].SoftLimit != 8589934592 {
+		t.Errorf("limits = %+v", rsp.Limits)
+	}
+}
+
+func TestVolumeQuotaDisabled(t *testing.T) {
+	fakeGluster(t).On("quota command failed : Quota is disabled, please enable quota", errExit, "gluster", "volume", "quota")
+	var rsp CommonResponse
+	if status := serve(t, ProcessVolumeQuotaLimitUsage, `{"volname": "test", "path": "/data", "size": "10GB"}`, &rsp); status != http.StatusConflict {
+		t.Errorf("status %d, want 409", status)
+	}
+	if rsp.Error == nil || rsp.Error.Code != CodeQuotaDisabled {
+		t.Errorf("got %+v", rsp.Error)
+	}
+}
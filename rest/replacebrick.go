@@ -0,0 +1,634 @@
+package gluster
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"hualu.com/gluster-rest/parser"
+	L "hualu.com/logger"
+)
+
+type VolumeReplaceBrickRequest struct {
+	CommonVolumeRequest
+	Brick    string `json:"brick"`     // host:/path being replaced
+	NewBrick string `json:"new_brick"` // host:/path, an empty directory on a connected peer
+	Force    string `json:"force"`     // skip the check that new_brick is empty
+}
+
+type VolumeResetBrickRequest struct {
+	CommonVolumeRequest
+	Brick    string `json:"brick"`     // host:/path being reset
+	NewBrick string `json:"new_brick"` // commit only: the same path, possibly under another name of its host; brick when empty
+	Force    string `json:"force"`     // commit only: the brick still holds data, e.g. it was not reformatted
+}
+
+// PreCheck is one condition verified before a brick is brought in. Checks that
+// can only run on the peer holding the brick are skipped for remote bricks.
+type PreCheck struct {
+	Check   string `json:"check"`
+	Result  string `json:"result"` // OK, a failed check fails the request
+	Message string `json:"message,omitempty"`
+}
+
+type VolumeBrickReplaceResponse struct {
+	CommonVolumeResponse
+	Checks []PreCheck `json:"checks,omitempty"`
+}
+
+// isLocalHost reports whether host names this node.
+func isLocalHost(ctx context.Context, host string) (bool, error) {
+	output, e := run(ctx, Command{Name: "hostname"})
+	if e != nil {
+		return false, glusterError(output, e)
+	}
+	if strings.TrimSpace(string(output)) == host {
+		return true, nil
+	}
+	output, e = run(ctx, Command{Name: "hostname", Args: []string{"-I"}})
+	if e != nil {
+		return false, glusterError(output, e)
+	}
+	for _, addr := range strings.Fields(string(output)) {
+		if addr == host {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// peerHasName reports whether host is one of the names or addresses of peer.
+// A peer probed by address and a brick given by hostname, or the reverse,
+// are matched by resolving both.
+func peerHasName(peer Peer, host string) bool {
+	names := append([]string{peer.HostName}, peer.HostNames...)
+	for _, name := range names {
+		if name == host {
+			return true
+		}
+	}
+	addrs, e := net.LookupHost(host)
+	if e != nil {
+		return false
+	}
+	for _, name := range names {
+		peerAddrs, e := net.LookupHost(name)
+		if e != nil {
+			continue
+		}
+		for _, a := range peerAddrs {
+			for _, b := range addrs {
+				if a == b {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+// checkNewBrick verifies the host of brick is a connected peer and, when empty
+// is set, that its directory is missing or empty. The directory of a remote
+// peer is checked by the gluster-rest service of that peer, when it cannot be
+// reached the check fails and only force, which clears empty, skips it.
+func checkNewBrick(ctx context.Context, brick string, empty bool) ([]PreCheck, error) {
+	host, path := parser.SplitBrick(brick)
+
+	local, e := brickPeer(ctx, host)
+	if e != nil {
+		return nil, e
+	}
+	checks := []PreCheck{{Check: "peer connected", Result: "OK", Message: host}}
+
+	if !empty {
+		return checks, nil
+	}
+	var pathEmpty bool
+	if local {
+		pathEmpty, e = localPathEmpty(ctx, path)
+	} else {
+		pathEmpty, e = remotePathEmpty(ctx, host, path)
+	}
+	if e != nil {
+		return nil, e
+	}
+	if !pathEmpty {
+		return nil, NewError(http.StatusConflict, CodeBrickNotEmpty, "brick directory %s is not empty", brick)
+	}
+	return append(checks, PreCheck{Check: "brick empty", Result: "OK", Message: path}), nil
+}
+
+// brickPeer verifies host is a connected peer of the cluster and reports
+// whether it is this node.
+func brickPeer(ctx context.Context, host string) (bool, error) {
+	output, e := runGluster(ctx, "pool", "list", "--xml")
+	if e != nil {
+		return false, glusterError(output, e)
+	}
+	var pool PeerStatusXML
+	if e := xml.Unmarshal(output, &pool); e != nil {
+		return false, glusterError(output, e)
+	}
+	for _, peer := range pool.PeerStatus.Peers {
+		if peer.HostName == "localhost" || !peerHasName(peer, host) {
+			continue
+		}
+		if peer.Connected != 1 {
+			return false, NewError(http.StatusServiceUnavailable, CodePeerNotConnected, "peer %s is not connected", host)
+		}
+		return false, nil
+	}
+	// this node is listed as localhost
+	local, e := isLocalHost(ctx, host)
+	if e != nil {
+		return false, e
+	}
+	if !local {
+		return false, NewError(http.StatusNotFound, CodePeerNotFound, "%s is not a peer of the cluster", host)
+	}
+	return true, nil
+}
+
+// localPathEmpty reports whether path on this node is missing, glusterd
+// creates it, or an empty directory.
+func localPathEmpty(ctx context.Context, path string) (bool, error) {
+	output, e := run(ctx, Command{Name: "ls", Args: []string{"-A", path}})
+	if e != nil && strings.Contains(string(output), "No such file or directory") {
+		return true, nil
+	}
+	if e != nil {
+		return false, glusterError(output, e)
+	}
+	return strings.TrimSpace(string(output)) == "", nil
+}
+
+// ServicePort is the port gluster-rest listens on, on every node.
+var ServicePort = "7030"
+
+// peerClient calls the gluster-rest service of other nodes.
+var peerClient = &http.Client{Timeout: 30 * time.Second}
+
+// callPeer posts request to uri of the gluster-rest service of host and
+// decodes the reply into response. Errors the peer reports are left in
+// response.
+func callPeer(ctx context.Context, host, uri string, request, response interface{}) error {
+	body, _ := json.Marshal(request)
+	url := "http://" + net.JoinHostPort(host, ServicePort) + uri
+	req, e := http.NewRequest("POST", url, bytes.NewReader(body))
+	if e != nil {
+		return e
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, e := peerClient.Do(req.WithContext(ctx))
+	if e != nil {
+		return e
+	}
+	defer resp.Body.Close()
+	return json.NewDecoder(resp.Body).Decode(response)
+}
+
+// remotePathEmpty asks the gluster-rest service of host whether path is empty
+// there, see ProcessBrickPathCheck.
+func remotePathEmpty(ctx context.Context, host, path string) (bool, error) {
+	var rsp BrickPathResponse
+	if e := callPeer(ctx, host, "/gluster/brick/path/check", BrickPathRequest{Path: path}, &rsp); e != nil {
+		return false, NewError(http.StatusServiceUnavailable, CodePeerNotConnected,
+			"cannot check brick directory %s on %s: %s, set force to skip the check", path, host, e)
+	}
+	if rsp.Error != nil {
+		return false, rsp.Error
+	}
+	return rsp.Empty, nil
+}
+
+type BrickPathRequest struct {
+	Path string `json:"path"`
+}
+
+type BrickPathResponse struct {
+	CommonResponse
+	Empty bool `json:"empty"` // missing or an empty directory
+}
+
+/*
+[example]
+curl -X POST http://node3:7030/gluster/brick/path/check -H 'Content-Type: application/json' -d '{
+"path": "/data/brick1/test"
+}'
+<- {"result":"OK","empty":true}
+*/
+func ProcessBrickPathCheck(w http.ResponseWriter, r *http.Request) {
+	var rsp BrickPathResponse
+	defer writeResponse(w, &rsp)
+
+	// analyze request
+	var req BrickPathRequest
+	if e := readRequest(r, &req); e != nil {
+		L.Gluster.Error(e.Error())
+		rsp.Fail(e)
+		return
+	}
+
+	empty, e := localPathEmpty(r.Context(), req.Path)
+	if e != nil {
+		L.Gluster.Error(e.Error())
+		rsp.Fail(e)
+		return
+	}
+	rsp.Empty = empty
+	rsp.Result = "OK"
+}
+
+// volumeBrick returns the volume after checking brick is one of its bricks and
+// the volume heals, a brick of a pure distribute volume has no copy to heal from.
+func volumeBrick(ctx context.Context, volname, brick string) (parser.Volume, error) {
+	volume, e := healableVolume(ctx, volname)
+	if e != nil {
+		return volume, e
+	}
+	for _, b := range volume.Bricks {
+		if b.Name == brick {
+			return volume, nil
+		}
+	}
+	return volume, NewError(http.StatusNotFound, CodeNotFound, "brick %s is not part of volume %s", brick, volname)
+}
+
+// brickSwap runs a replace-brick or reset-brick commit under the volume lock
+// and hands the lock to an index heal job copying the data to the new brick.
+func brickSwap(r *http.Request, volname, op string, args ...string) (Job, error) {
+	release, e := lockForRequest(r, volumeLock(volname), op)
+	if e != nil {
+		return Job{}, e
+	}
+	output, e := runGluster(r.Context(), args...)
+	if e != nil {
+		release()
+		L.Gluster.Error(string(output))
+		return Job{}, glusterError(output, e)
+	}
+	L.Gluster.Info(strings.TrimSpace(string(output)))
+	return jobs.Start("heal-index", volname, healJob(volname, nil, release)), nil
+}
+
+/*
+[example]
+docker exec glusterfs gluster volume replace-brick test node2:/data/brick1/test node3:/data/brick1/test commit force
+
+curl -X POST http://127.0.0.1:7030/gluster/volume/brick/replace -H 'Content-Type: application/json' -d '{
+"volname": "test",
+"brick": "node2:/data/brick1/test",
+"new_brick": "node3:/data/brick1/test"
+}'
+<- {"result":"OK","job_id":"9f2c6a1e0b7d4c35","checks":[{"check":"peer connected","result":"OK","message":"node3"},{"check":"brick empty","result":"OK","message":"/data/brick1/test"}]}
+*/
+func ProcessVolumeReplaceBrick(w http.ResponseWriter, r *http.Request) {
+	var rsp VolumeBrickReplaceResponse
+	defer writeResponse(w, &rsp)
+
+	// analyze request
+	var req VolumeReplaceBrickRequest
+	if e := readRequest(r, &req); e != nil {
+		L.Gluster.Error(e.Error())
+		rsp.Fail(e)
+		return
+	}
+
+	if _, e := volumeBrick(r.Context(), req.Volname, req.Brick); e != nil {
+		L.Gluster.Error(e.Error())
+		rsp.Fail(e)
+		return
+	}
+	checks, e := checkNewBrick(r.Context(), req.NewBrick, req.Force != "true")
+	if e != nil {
+		L.Gluster.Error(e.Error())
+		rsp.Fail(e)
+		return
+	}
+	rsp.Checks = checks
+
+	// "commit force" is the only form of replace-brick glusterd still supports
+	job, e := brickSwap(r, req.Volname, "replace-brick",
+		"volume", "replace-brick", req.Volname, req.Brick, req.NewBrick, "commit", "force")
+	if e != nil {
+		L.Gluster.Error(e.Error())
+		rsp.Fail(e)
+		return
+	}
+	rsp.JobID = job.ID
+	rsp.Result = "OK"
+}
+
+/*
+[example]
+docker exec glusterfs gluster volume reset-brick test node2:/data/brick1/test start
+
+curl -X POST http://127.0.0.1:7030/gluster/volume/brick/reset/start -H 'Content-Type: application/json' -d '{
+"volname": "test",
+"brick": "node2:/data/brick1/test"
+}'
+<- {"result":"OK"}
+*/
+func ProcessVolumeResetBrickStart(w http.ResponseWriter, r *http.Request) {
+	var rsp CommonVolumeResponse
+	defer writeResponse(w, &rsp)
+
+	// analyze request
+	var req VolumeResetBrickRequest
+	if e := readRequest(r, &req); e != nil {
+		L.Gluster.Error(e.Error())
+		rsp.Fail(e)
+		return
+	}
+
+	if _, e := volumeBrick(r.Context(), req.Volname, req.Brick); e != nil {
+		L.Gluster.Error(e.Error())
+		rsp.Fail(e)
+		return
+	}
+	release, e := lockForRequest(r, volumeLock(req.Volname), "reset-brick start")
+	if e != nil {
+		L.Gluster.Error(e.Error())
+		rsp.Fail(e)
+		return
+	}
+	defer release()
+
+	// stops the brick process, the disk can be replaced until commit
+	output, e := runGluster(r.Context(), "volume", "reset-brick", req.Volname, req.Brick, "start")
+	if e != nil {
+		L.Gluster.Error(string(output))
+		rsp.Fail(glusterError(output, e))
+		return
+	}
+	rsp.Result = "OK"
+}
+
+/*
+[example]
+docker exec glusterfs gluster volume reset-brick test node2:/data/brick1/test node2:/data/brick1/test commit
+
+curl -X POST http://127.0.0.1:7030/gluster/volume/brick/reset/commit -H 'Content-Type: application/json' -d '{
+"volname": "test",
+"brick": "node2:/data/brick1/test"
+}'
+<- {"result":"OK","job_id":"9f2c6a1e0b7d4c35","checks":[{"check":"peer connected","result":"OK","message":"node2"},...]}
+*/
+func ProcessVolumeResetBrickCommit(w http.ResponseWriter, r *http.Request) {
+	var rsp VolumeBrickReplaceResponse
+	defer writeResponse(w, &rsp)
+
+	// analyze request
+	var req VolumeResetBrickRequest
+	if e := readRequest(r, &req); e != nil {
+		L.Gluster.Error(e.Error())
+		rsp.Fail(e)
+		return
+	}
+	newBrick := req.NewBrick
+	if newBrick == "" {
+		newBrick = req.Brick
+	}
+
+	if _, e := volumeBrick(r.Context(), req.Volname, req.Brick); e != nil {
+		L.Gluster.Error(e.Error())
+		rsp.Fail(e)
+		return
+	}
+	// with force the old data is kept and only healed up
+	checks, e := checkNewBrick(r.Context(), newBrick, req.Force != "true")
+	if e != nil {
+		L.Gluster.Error(e.Error())
+		rsp.Fail(e)
+		return
+	}
+	rsp.Checks = checks
+
+	args := []string{"volume", "reset-brick", req.Volname, req.Brick, newBrick, "commit"}
+	if req.Force == "true" {
+		args = append(args, "force")
+	}
+	job, e := brickSwap(r, req.Volname, "reset-brick commit", args...)
+	if e != nil {
+		L.Gluster.Error(e.Error())
+		rsp.Fail(e)
+		return
+	}
+	rsp.JobID = job.ID
+	rsp.Result = "OK"
+}
+
+// VolumeBrickFailureRequest replaces a failed brick with NewBrick, or rebuilds
+// it in place when NewBrick is empty, e.g. on a new disk. With Device set the
+// disk is prepared on the host of the new brick first: formatted with xfs and
+// mounted on the parent of the brick path, see prepareBrickDevice. Without it
+// the caller mounts the replacement beforehand and only the path is checked.
+type VolumeBrickFailureRequest struct {
+	CommonVolumeRequest
+	Brick    string `json:"brick"`     // the failed host:/path
+	NewBrick string `json:"new_brick"` // host:/path on a connected peer, empty to reuse brick
+	Device   string `json:"device"`    // optional unused block device on the host of the new brick, e.g. /dev/sdc
+	Force    string `json:"force"`     // replace the brick even though it is online, and skip the check that new_brick is empty
+}
+
+// steps of the failed brick replacement
+const (
+	stepCheckBrick    = "check failed brick"
+	stepCheckNewBrick = "check new brick"
+	stepPrepareDevice = "prepare device"
+	stepReplace       = "replace brick"
+	stepStartHeal     = "start heal"
+	stepWaitHeal      = "wait for heal"
+	stepVerify        = "verify"
+)
+
+// brickFailureJob replaces the brick, heals it and checks the subvolume is
+// healthy again. The volume lock is held until the heal is launched.
+func brickFailureJob(req VolumeBrickFailureRequest, release func()) JobFunc {
+	return func(ctx context.Context, update func(JobProgress)) (string, error) {
+		defer release()
+		names := []string{stepCheckBrick, stepCheckNewBrick}
+		if req.Device != "" {
+			names = append(names, stepPrepareDevice)
+		}
+		names = append(names, stepReplace, stepStartHeal, stepWaitHeal, stepVerify)
+		steps := newJobSteps(update, names...)
+		newBrick := req.NewBrick
+		if newBrick == "" {
+			newBrick = req.Brick
+		}
+
+		e := steps.Run(stepCheckBrick, func() (string, error) {
+			if _, e := volumeBrick(ctx, req.Volname, req.Brick); e != nil {
+				return "", e
+			}
+			bricks, e := bricksStatus(ctx, req.Volname)
+			if e != nil {
+				return "", e
+			}
+			if bricks[req.Brick].Online {
+				if req.Force != "true" {
+					return "", NewError(http.StatusConflict, CodeBrickOnline, "brick %s is online, set force to replace it anyway", req.Brick)
+				}
+				return "brick is online, replacing it as forced", nil
+			}
+			return "brick is offline", nil
+		})
+		if e != nil {
+			return "", e
+		}
+
+		e = steps.Run(stepCheckNewBrick, func() (string, error) {
+			checks, e := checkNewBrick(ctx, newBrick, req.Force != "true")
+			if e != nil {
+				return "", e
+			}
+			var messages []string
+			for _, check := range checks {
+				messages = append(messages, check.Check+": "+check.Result)
+			}
+			return strings.Join(messages, ", "), nil
+		})
+		if e != nil {
+			return "", e
+		}
+
+		if req.Device != "" {
+			e = steps.Run(stepPrepareDevice, func() (string, error) {
+				host, path := parser.SplitBrick(newBrick)
+				local, e := brickPeer(ctx, host)
+				if e != nil {
+					return "", e
+				}
+				var mount string
+				if local {
+					mount, e = prepareBrickDevice(ctx, req.Device, path)
+				} else {
+					mount, e = remotePrepareDevice(ctx, host, req.Device, path)
+				}
+				if e != nil {
+					return "", e
+				}
+				return fmt.Sprintf("%s mounted on %s:%s", req.Device, host, mount), nil
+			})
+			if e != nil {
+				return "", e
+			}
+		}
+
+		var output []byte
+		e = steps.Run(stepReplace, func() (string, error) {
+			var e error
+			if newBrick != req.Brick {
+				output, e = runGluster(ctx, "volume", "replace-brick", req.Volname, req.Brick, newBrick, "commit", "force")
+			} else {
+				// reset-brick takes the brick down first, a failed brick may already be
+				output, e = runGluster(ctx, "volume", "reset-brick", req.Volname, req.Brick, "start")
+				if e == nil {
+					output, e = runGluster(ctx, "volume", "reset-brick", req.Volname, req.Brick, newBrick, "commit")
+				}
+			}
+			if e != nil {
+				L.Gluster.Error(string(output))
+				return "", glusterError(output, e)
+			}
+			return strings.TrimSpace(string(output)), nil
+		})
+		if e != nil {
+			return string(output), e
+		}
+
+		before := healCrawlsBefore(ctx, req.Volname)
+		e = steps.Run(stepStartHeal, func() (string, error) {
+			output, e := runGluster(ctx, "volume", "heal", req.Volname)
+			release()
+			if e != nil {
+				L.Gluster.Error(string(output))
+				return "", glusterError(output, e)
+			}
+			return strings.TrimSpace(string(output)), nil
+		})
+		if e != nil {
+			return "", e
+		}
+
+		e = steps.Run(stepWaitHeal, func() (string, error) {
+			if e := waitHealed(ctx, req.Volname, before, steps.Progress); e != nil {
+				return "", e
+			}
+			return "no entries pending", nil
+		})
+		if e != nil {
+			return "", e
+		}
+
+		e = steps.Run(stepVerify, func() (string, error) {
+			volumes, e := volumesInfo(ctx, req.Volname)
+			if e != nil {
+				return "", e
+			}
+			bricks, e := bricksStatus(ctx, req.Volname)
+			if e != nil {
+				return "", e
+			}
+			if !bricks[newBrick].Online {
+				return "", NewError(http.StatusServiceUnavailable, CodeBrickDown, "brick %s is not online", newBrick)
+			}
+			for _, volume := range volumes {
+				volume.ApplyStatus(bricks)
+				for _, s := range volume.Subvolumes {
+					for _, b := range s.Bricks {
+						if b.Name == newBrick && s.Health.State != parser.Healthy {
+							return "", NewError(http.StatusServiceUnavailable, CodeBrickDown, "subvolume %s is %s", s.Name, s.Health.State)
+						}
+					}
+				}
+			}
+			return "brick online, subvolume healthy", nil
+		})
+		return "", e
+	}
+}
+
+/*
+[example]
+curl -X POST http://127.0.0.1:7030/gluster/volume/brick/replace-failed -H 'Content-Type: application/json' -d '{
+"volname": "test",
+"brick": "node2:/data/brick1/test",
+"new_brick": "node3:/data/brick1/test"
+}'
+<- {"result":"OK","job_id":"9f2c6a1e0b7d4c35"}
+
+curl -X GET http://127.0.0.1:7030/gluster/jobs/9f2c6a1e0b7d4c35
+<- {"result":"OK","job":{"id":"9f2c6a1e0b7d4c35","op":"replace failed brick","state":"running","progress":{"status":"healing","pending":1200,
+"steps":[{"name":"check failed brick","state":"done","message":"brick is offline",...},...,{"name":"wait for heal","state":"running",...},{"name":"verify","state":"pending"}]}}}
+*/
+func ProcessVolumeReplaceFailedBrick(w http.ResponseWriter, r *http.Request) {
+	var rsp CommonVolumeResponse
+	defer writeResponse(w, &rsp)
+
+	// analyze request
+	var req VolumeBrickFailureRequest
+	if e := readRequest(r, &req); e != nil {
+		L.Gluster.Error(e.Error())
+		rsp.Fail(e)
+		return
+	}
+
+	release, e := lockForRequest(r, volumeLock(req.Volname), "replace failed brick")
+	if e != nil {
+		L.Gluster.Error(e.Error())
+		rsp.Fail(e)
+		return
+	}
+
+	job := jobs.Start("replace failed brick", req.Volname, brickFailureJob(req, release))
+	rsp.JobID = job.ID
+	rsp.Result = "OK"
+}
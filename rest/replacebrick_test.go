@@ -0,0 +1,105 @@
+package gluster
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+)
+
+// poolListXML is `pool list --xml`: 127.0.0.1 connected, node2 not.
+const poolListXML = `<cliOutput><opRet>0</opRet><peerStatus>` +
+	`<peer><uuid>p1</uuid><hostname>127.0.0.1</hostname><connected>1</connected></peer>` +
+	`<peer><uuid>p2</uuid><hostname>node2</hostname><connected>0</connected></peer>` +
+	`<peer><uuid>p0</uuid><hostname>localhost</hostname><connected>1</connected></peer>` +
+	`</peerStatus></cliOutput>`
+
+// fakeBrickVolume answers the checks of a brick replacement in the replica 2
+// volume test and the heal following it, the peer service lists dir.
+func fakeBrickVolume(t *testing.T, dir string) *FakeRunner {
+	fastPoll(t)
+	return fakeGluster(t).
+		On(volumeInfoXML("test", "2", "node1:/data/brick1/test", "node2:/data/brick1/test"), nil, "gluster", "volume", "info").
+		On(poolListXML, nil, "gluster", "pool", "list").
+		On("node1\n", nil, "hostname").
+		On("10.0.0.1\n", nil, "hostname", "-I").
+		On(dir, nil, "ls", "-A").
+		On("volume replace-brick: success", nil, "gluster", "volume", "replace-brick").
+		On("volume reset-brick: success", nil, "gluster", "volume", "reset-brick").
+		On(healZeroXML, nil, "gluster", "volume", "heal", "test", "info", "summary")
+}
+
+func TestVolumeReplaceBrick(t *testing.T) {
+	tests := []struct {
+		name   string
+		body   string
+		dir    string
+		status int
+		code   string
+		checks int
+	}{
+		{"empty directory", `{"volname": "test", "brick": "node2:/data/brick1/test", "new_brick": "127.0.0.1:/data/brick1/test"}`, "", http.StatusOK, "", 2},
+		{"directory with data", `{"volname": "test", "brick": "node2:/data/brick1/test", "new_brick": "127.0.0.1:/data/brick1/test"}`, "a.txt\n", http.StatusConflict, CodeBrickNotEmpty, 0},
+		{"forced", `{"volname": "test", "brick": "node2:/data/brick1/test", "new_brick": "127.0.0.1:/data/brick1/test", "force": "true"}`, "a.txt\n", http.StatusOK, "", 1},
+		{"local peer", `{"volname": "test", "brick": "node2:/data/brick1/test", "new_brick": "node1:/data/brick3/test"}`, "", http.StatusOK, "", 2},
+		{"disconnected peer", `{"volname": "test", "brick": "node1:/data/brick1/test", "new_brick": "node2:/data/brick3/test"}`, "", http.StatusServiceUnavailable, CodePeerNotConnected, 0},
+		{"not a peer", `{"volname": "test", "brick": "node2:/data/brick1/test", "new_brick": "10.0.0.9:/data/brick1/test"}`, "", http.StatusNotFound, CodePeerNotFound, 0},
+		{"brick of another volume", `{"volname": "test", "brick": "node3:/data/brick1/test", "new_brick": "127.0.0.1:/data/brick1/test"}`, "", http.StatusNotFound, CodeNotFound, 0},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			peerService(t)
+			f := fakeBrickVolume(t, test.dir)
+			var rsp VolumeBrickReplaceResponse
+			if status := serve(t, ProcessVolumeReplaceBrick, test.body, &rsp); status != test.status {
+				t.Fatalf("status %d, want %d: %+v", status, test.status, rsp.Error)
+			}
+			if test.code != "" {
+				if rsp.Error == nil || rsp.Error.Code != test.code {
+					t.Errorf("got %+v, want %s", rsp.Error, test.code)
+				}
+				for _, call := range f.Invocations() {
+					if strings.HasPrefix(call, "gluster volume replace-brick") {
+						t.Errorf("ran %q after a failed check", call)
+					}
+				}
+				return
+			}
+			if len(rsp.Checks) != test.checks {
+				t.Errorf("checks = %+v", rsp.Checks)
+			}
+			if job := waitJob(t, rsp.JobID); job.State != JobSucceeded {
+				t.Errorf("heal job = %+v", job)
+			}
+		})
+	}
+}
+
+func TestVolumeResetBrickCommit(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		want string
+	}{
+		{"same brick", `{"volname": "test", "brick": "node1:/data/brick1/test"}`,
+			"gluster volume reset-brick test node1:/data/brick1/test node1:/data/brick1/test commit"},
+		{"data kept", `{"volname": "test", "brick": "node1:/data/brick1/test", "force": "true"}`,
+			"gluster volume reset-brick test node1:/data/brick1/test node1:/data/brick1/test commit force"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			f := fakeBrickVolume(t, "")
+			var rsp VolumeBrickReplaceResponse
+			if status := serve(t, ProcessVolumeResetBrickCommit, test.body, &rsp); status != http.StatusOK {
+				t.Fatalf("status %d: %+v", status, rsp.Error)
+			}
+			waitJob(t, rsp.JobID)
+			found := false
+			for _, call := range f.Invocations() {
+				found = found || call == test.want
+			}
+			if !found {
+				t.Errorf("did not run %q: %q", test.want, f.Invocations())
+			}
+		})
+	}
+}
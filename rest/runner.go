@@ -0,0 +1,190 @@
+package gluster
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"syscall"
+	"time"
+
+	L "hualu.com/logger"
+)
+
+// Command is one external program invocation, described as an argv array.
+type Command struct {
+	Name    string
+	Args    []string
+	Stdin   string        // fed to the process, e.g. "y\n" for confirmation prompts
+	Env     []string      // appended to the service environment, "KEY=value"
+	Timeout time.Duration // zero means ShortTimeout
+}
+
+func (c Command) String() string {
+	return strings.Join(append([]string{c.Name}, c.Args...), " ")
+}
+
+// Runner executes commands and returns their combined stdout and stderr.
+type Runner interface {
+	Run(ctx context.Context, cmd Command) ([]byte, error)
+}
+
+// ErrTimeout is returned when a command exceeded its timeout and was killed.
+var ErrTimeout = errors.New("command timed out")
+
+// ExecRunner runs commands as local processes. Each command gets its own
+// process group, which is killed as a whole when ctx is done.
+type ExecRunner struct{}
+
+func (ExecRunner) Run(ctx context.Context, c Command) ([]byte, error) {
+	if c.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.Timeout)
+		defer cancel()
+	}
+
+	cmd := exec.Command(c.Name, c.Args...)
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	if c.Stdin != "" {
+		cmd.Stdin = strings.NewReader(c.Stdin)
+	}
+	if len(c.Env) > 0 {
+		cmd.Env = append(os.Environ(), c.Env...)
+	}
+
+	var output bytes.Buffer
+	cmd.Stdout = &output
+	cmd.Stderr = &output
+	if e := cmd.Start(); e != nil {
+		return nil, e
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		done <- cmd.Wait()
+	}()
+
+	select {
+	case e := <-done:
+		return output.Bytes(), e
+	case <-ctx.Done():
+		// gluster may fork helpers, kill the whole group
+		syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+		<-done
+		L.Gluster.Errorf("killed %s: %s", c.String(), ctx.Err())
+		if ctx.Err() == context.DeadlineExceeded {
+			return output.Bytes(), ErrTimeout
+		}
+		return output.Bytes(), ctx.Err()
+	}
+}
+
+var runner Runner = ExecRunner{}
+
+// SetRunner replaces the runner used by all handlers, e.g. with a FakeRunner in tests.
+func SetRunner(r Runner) {
+	runner = r
+}
+
+// Timeouts of gluster operations. Operations not listed in LongOps or
+// OpTimeouts, and all other commands, get ShortTimeout.
+var (
+	ShortTimeout = 60 * time.Second
+	LongTimeout  = 10 * time.Minute
+
+	// OpTimeouts overrides the timeout of one operation, keyed like LongOps.
+	OpTimeouts = map[string]time.Duration{}
+)
+
+// LongOps are the gluster operations, keyed by their first two arguments,
+// that may legitimately take minutes.
+var LongOps = map[string]bool{
+	"peer probe":           true,
+	"peer detach":          true,
+	"volume create":        true,
+	"volume start":         true,
+	"volume stop":          true,
+	"volume delete":        true,
+	"volume add-brick":     true,
+	"volume remove-brick":  true,
+	"volume rebalance":     true,
+	"volume replace-brick": true,
+	"volume reset-brick":   true,
+	"volume heal":          true,
+	"snapshot create":      true,
+	"snapshot delete":      true,
+	"snapshot restore":     true,
+	"snapshot clone":       true,
+	"snapshot activate":    true,
+	"snapshot deactivate":  true,
+}
+
+// ParseOpTimeouts fills OpTimeouts from "volume create=20m,volume heal=1h".
+func ParseOpTimeouts(s string) error {
+	for _, item := range strings.Split(s, ",") {
+		if strings.TrimSpace(item) == "" {
+			continue
+		}
+		kv := strings.SplitN(item, "=", 2)
+		if len(kv) != 2 {
+			return fmt.Errorf("op timeout %q is not op=duration", item)
+		}
+		d, e := time.ParseDuration(strings.TrimSpace(kv[1]))
+		if e != nil {
+			return fmt.Errorf("op timeout %q: %s", item, e)
+		}
+		OpTimeouts[strings.TrimSpace(kv[0])] = d
+	}
+	return nil
+}
+
+// MaxTimeout is the longest a synchronous gluster command may run, the HTTP
+// server must not cut the response off before.
+func MaxTimeout() time.Duration {
+	max := ShortTimeout
+	if LongTimeout > max {
+		max = LongTimeout
+	}
+	for _, d := range OpTimeouts {
+		if d > max {
+			max = d
+		}
+	}
+	return max
+}
+
+func glusterTimeout(args []string) time.Duration {
+	if len(args) < 2 {
+		return ShortTimeout
+	}
+	op := args[0] + " " + args[1]
+	if d, ok := OpTimeouts[op]; ok {
+		return d
+	}
+	if LongOps[op] {
+		return LongTimeout
+	}
+	return ShortTimeout
+}
+
+// run logs and executes a command through the package runner.
+func run(ctx context.Context, c Command) ([]byte, error) {
+	if c.Timeout == 0 {
+		c.Timeout = ShortTimeout
+	}
+	L.Gluster.Info(c.String())
+	return runner.Run(ctx, c)
+}
+
+// runGluster executes the gluster CLI with the given arguments.
+func runGluster(ctx context.Context, args ...string) ([]byte, error) {
+	return run(ctx, Command{Name: "gluster", Args: args, Timeout: glusterTimeout(args)})
+}
+
+// runGlusterConfirm executes the gluster CLI answering "y" to its confirmation prompt.
+func runGlusterConfirm(ctx context.Context, args ...string) ([]byte, error) {
+	return run(ctx, Command{Name: "gluster", Args: args, Stdin: "y\n", Timeout: glusterTimeout(args)})
+}
@@ -0,0 +1,67 @@
+package gluster
+
+import (
+	"context"
+	"strings"
+	"sync"
+)
+
+// FakeResponse is the canned result of a FakeRunner invocation.
+type FakeResponse struct {
+	Output string
+	Err    error
+}
+
+// FakeRunner is an in-memory Runner. It records every invocation and answers
+// with the response registered for the longest matching command prefix.
+type FakeRunner struct {
+	mu        sync.Mutex
+	responses map[string]FakeResponse
+	Default   FakeResponse
+	Calls     []Command
+}
+
+func NewFakeRunner() *FakeRunner {
+	return &FakeRunner{responses: make(map[string]FakeResponse)}
+}
+
+// On registers the response for commands starting with argv, e.g.
+// On("gluster", "volume", "info").
+func (f *FakeRunner) On(output string, err error, argv ...string) *FakeRunner {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.responses[strings.Join(argv, " ")] = FakeResponse{Output: output, Err: err}
+	return f
+}
+
+func (f *FakeRunner) Run(ctx context.Context, c Command) ([]byte, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.Calls = append(f.Calls, c)
+
+	if e := ctx.Err(); e != nil {
+		if e == context.DeadlineExceeded {
+			return nil, ErrTimeout
+		}
+		return nil, e
+	}
+
+	argv := append([]string{c.Name}, c.Args...)
+	for i := len(argv); i > 0; i-- {
+		if rsp, ok := f.responses[strings.Join(argv[:i], " ")]; ok {
+			return []byte(rsp.Output), rsp.Err
+		}
+	}
+	return []byte(f.Default.Output), f.Default.Err
+}
+
+// Invocations returns the recorded commands as strings.
+func (f *FakeRunner) Invocations() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var calls []string
+	for _, c := range f.Calls {
+		calls = append(calls, c.String())
+	}
+	return calls
+}
@@ -0,0 +1,84 @@
+package gluster
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestFakeRunner(t *testing.T) {
+	f := fakeGluster(t).
+		On("all volumes", nil, "gluster", "volume", "info").
+		On("volume test", nil, "gluster", "volume", "info", "test").
+		On("", errExit, "gluster", "volume", "start")
+	f.Default = FakeResponse{Output: "default"}
+
+	tests := []struct {
+		args   []string
+		output string
+		err    error
+	}{
+		{[]string{"volume", "info", "test", "--xml"}, "volume test", nil}, // longest prefix wins
+		{[]string{"volume", "info", "other"}, "all volumes", nil},
+		{[]string{"volume", "start", "test"}, "", errExit},
+		{[]string{"peer", "status"}, "default", nil},
+	}
+	for _, test := range tests {
+		output, e := runGluster(context.Background(), test.args...)
+		if string(output) != test.output || e != test.err {
+			t.Errorf("%v = %q, %v, want %q, %v", test.args, output, e, test.output, test.err)
+		}
+	}
+
+	want := []string{
+		"gluster volume info test --xml",
+		"gluster volume info other",
+		"gluster volume start test",
+		"gluster peer status",
+	}
+	got := f.Invocations()
+	if len(got) != len(want) {
+		t.Fatalf("recorded %q, want %q", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("invocation %d = %q, want %q", i, got[i], want[i])
+		}
+	}
+}
+
+func TestFakeRunnerContext(t *testing.T) {
+	f := NewFakeRunner()
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, e := f.Run(ctx, Command{Name: "gluster"}); e != context.Canceled {
+		t.Errorf("cancelled: %v", e)
+	}
+	ctx, cancel = context.WithTimeout(context.Background(), -time.Second)
+	defer cancel()
+	if _, e := f.Run(ctx, Command{Name: "gluster"}); e != ErrTimeout {
+		t.Errorf("expired: %v", e)
+	}
+}
+
+// TestExecRunner checks arguments reach the process as they are, no shell
+// interprets them.
+func TestExecRunner(t *testing.T) {
+	output, e := ExecRunner{}.Run(context.Background(), Command{Name: "echo", Args: []string{"$HOME;", "`id`", "a b"}})
+	if e != nil {
+		t.Fatal(e)
+	}
+	if string(output) != "$HOME; `id` a b\n" {
+		t.Errorf("got %q", output)
+	}
+
+	output, e = ExecRunner{}.Run(context.Background(), Command{Name: "cat", Stdin: "y\n"})
+	if e != nil || string(output) != "y\n" {
+		t.Errorf("stdin: %q, %v", output, e)
+	}
+
+	_, e = ExecRunner{}.Run(context.Background(), Command{Name: "sleep", Args: []string{"5"}, Timeout: 10 * time.Millisecond})
+	if e != ErrTimeout {
+		t.Errorf("timeout: %v", e)
+	}
+}
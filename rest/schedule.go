@@ -0,0 +1,616 @@
+package gluster
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"hualu.com/gluster-rest/parser"
+	L "hualu.com/logger"
+)
+
+// SnapshotSchedule takes snapshots of a volume on a cron schedule and prunes
+// the ones its retention no longer keeps. Schedules live in gluster shared
+// storage so whichever node is up runs them, see ScheduleFile.
+type SnapshotSchedule struct {
+	ID            string        `json:"id"`
+	Volname       string        `json:"volname"`
+	Cron          string        `json:"cron"`   // server local time
+	Prefix        string        `json:"prefix"` // snapshots are named <prefix>_GMT-<time>
+	KeepLast      int           `json:"keep_last"`
+	KeepDaily     int           `json:"keep_daily"`
+	KeepWeekly    int           `json:"keep_weekly"`
+	Enabled       bool          `json:"enabled"`
+	LastScheduled time.Time     `json:"last_scheduled"` // claimed by a node, runs once cluster wide
+	NextRun       *time.Time    `json:"next_run,omitempty"`
+	LastRuns      []ScheduleRun `json:"last_runs"` // newest first
+}
+
+type ScheduleRun struct {
+	Scheduled time.Time  `json:"scheduled"`
+	Node      string     `json:"node"`
+	JobID     string     `json:"job_id"`
+	EndTime   *time.Time `json:"end_time,omitempty"`
+	Result    string     `json:"result"` // RUNNING, OK or ERROR
+	Snapshot  string     `json:"snapshot,omitempty"`
+	Pruned    []string   `json:"pruned,omitempty"`
+	Error     *Error     `json:"error,omitempty"`
+}
+
+// runsKept bounds the run history of a schedule.
+const runsKept = 10
+
+// ScheduleRunTimeout bounds a run. A run still RUNNING after it lost its node,
+// which died before recording the result, and is marked as failed.
+var ScheduleRunTimeout = time.Hour
+
+// ScheduleFile holds the schedules of the cluster. It must be on shared
+// storage (`gluster volume set all cluster.enable-shared-storage enable`)
+// for the schedules to survive the node that created them.
+var ScheduleFile = "/var/run/gluster/shared_storage/gluster-rest/snapshot_schedules.json"
+
+// ScheduleStore reads and writes ScheduleFile under an flock, which glusterfs
+// honours across nodes.
+type ScheduleStore struct {
+	mu sync.Mutex
+}
+
+var schedules = &ScheduleStore{}
+
+var ErrScheduleNotFound = &Error{Status: http.StatusNotFound, Code: CodeNotFound, Message: "schedule not found"}
+
+// storeLockWait bounds how long a store operation waits for another node.
+const storeLockWait = 10 * time.Second
+
+func (s *ScheduleStore) lock() (*os.File, error) {
+	return lockStore("schedule", ScheduleFile, CodeScheduleStore)
+}
+
+// lockStore takes the flock of a store file on shared storage, waiting up to
+// storeLockWait for other nodes. Closing the file releases it.
+func lockStore(name, file, code string) (*os.File, error) {
+	dir := filepath.Dir(file)
+	if _, e := os.Stat(filepath.Dir(dir)); e != nil {
+		return nil, NewError(http.StatusServiceUnavailable, code, "%s store %s is not available: %s", name, dir, e)
+	}
+	if e := os.MkdirAll(dir, 0755); e != nil {
+		return nil, NewError(http.StatusServiceUnavailable, code, "%s store: %s", name, e)
+	}
+	f, e := os.OpenFile(file+".lock", os.O_CREATE|os.O_RDWR, 0644)
+	if e != nil {
+		return nil, NewError(http.StatusServiceUnavailable, code, "%s store: %s", name, e)
+	}
+	deadline := time.Now().Add(storeLockWait)
+	for {
+		e = syscall.Flock(int(f.Fd()), syscall.LOCK_EX|syscall.LOCK_NB)
+		if e == nil {
+			return f, nil
+		}
+		if e != syscall.EWOULDBLOCK || time.Now().After(deadline) {
+			f.Close()
+			return nil, NewError(http.StatusServiceUnavailable, code, "%s store lock: %s", name, e)
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+}
+
+func (s *ScheduleStore) read() ([]SnapshotSchedule, error) {
+	list := []SnapshotSchedule{}
+	buf, e := ioutil.ReadFile(ScheduleFile)
+	if os.IsNotExist(e) {
+		return list, nil
+	}
+	if e != nil {
+		return nil, NewError(http.StatusServiceUnavailable, CodeScheduleStore, "schedule store: %s", e)
+	}
+	if e := json.Unmarshal(buf, &list); e != nil {
+		return nil, NewError(http.StatusInternalServerError, CodeScheduleStore, "schedule store %s is corrupt: %s", ScheduleFile, e)
+	}
+	return list, nil
+}
+
+// Update runs fn on the schedules and saves what it returns, all under the
+// store lock.
+func (s *ScheduleStore) Update(fn func([]SnapshotSchedule) ([]SnapshotSchedule, error)) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	f, e := s.lock()
+	if e != nil {
+		return e
+	}
+	defer f.Close() // releases the flock
+
+	list, e := s.read()
+	if e != nil {
+		return e
+	}
+	failLostRuns(list, time.Now())
+	list, e = fn(list)
+	if e != nil {
+		return e
+	}
+	for i := range list {
+		list[i].NextRun = nil
+	}
+	buf, e := json.MarshalIndent(list, "", "  ")
+	if e != nil {
+		return e
+	}
+	tmp := ScheduleFile + ".tmp"
+	if e := ioutil.WriteFile(tmp, buf, 0644); e != nil {
+		return NewError(http.StatusServiceUnavailable, CodeScheduleStore, "schedule store: %s", e)
+	}
+	if e := os.Rename(tmp, ScheduleFile); e != nil {
+		return NewError(http.StatusServiceUnavailable, CodeScheduleStore, "schedule store: %s", e)
+	}
+	return nil
+}
+
+// List returns the schedules of volname (empty matches all) with their next
+// run. Updates replace the file atomically, so it is read without the lock.
+func (s *ScheduleStore) List(volname string) ([]SnapshotSchedule, error) {
+	list, e := s.read()
+	if e != nil {
+		return nil, e
+	}
+	failLostRuns(list, time.Now())
+	result := make([]SnapshotSchedule, 0, len(list))
+	for _, schedule := range list {
+		if volname == "" || schedule.Volname == volname {
+			result = append(result, schedule)
+		}
+	}
+	now := time.Now()
+	for i := range result {
+		if cron, e := ParseCron(result[i].Cron); e == nil && result[i].Enabled {
+			if next := cron.Next(now); !next.IsZero() {
+				result[i].NextRun = &next
+			}
+		}
+	}
+	return result, nil
+}
+
+// failLostRuns marks the runs still RUNNING after ScheduleRunTimeout as failed.
+func failLostRuns(list []SnapshotSchedule, now time.Time) {
+	for i := range list {
+		for j := range list[i].LastRuns {
+			run := &list[i].LastRuns[j]
+			if run.Result != "RUNNING" || now.Sub(run.Scheduled) < ScheduleRunTimeout {
+				continue
+			}
+			run.Result = "ERROR"
+			run.Error = NewError(http.StatusGatewayTimeout, CodeTimeout,
+				"run did not finish within %s, node %s may be down", ScheduleRunTimeout, run.Node)
+		}
+	}
+}
+
+// scheduleConflict refuses a second schedule of a volume with the same prefix,
+// their retention would prune each other's snapshots. The schedule at index
+// skip, the one being updated, is not compared.
+func scheduleConflict(list []SnapshotSchedule, schedule SnapshotSchedule, skip int) error {
+	for i, s := range list {
+		if i != skip && s.Volname == schedule.Volname && s.Prefix == schedule.Prefix {
+			return NewError(http.StatusConflict, CodeScheduleExists, "volume %s already has a schedule with prefix %s", s.Volname, s.Prefix)
+		}
+	}
+	return nil
+}
+
+func findSchedule(list []SnapshotSchedule, id string) int {
+	for i := range list {
+		if list[i].ID == id {
+			return i
+		}
+	}
+	return -1
+}
+
+// recordRun replaces the run of schedule id scheduled at run.Scheduled.
+func recordRun(id string, run ScheduleRun) {
+	e := schedules.Update(func(list []SnapshotSchedule) ([]SnapshotSchedule, error) {
+		i := findSchedule(list, id)
+		if i < 0 {
+			return list, nil // deleted meanwhile
+		}
+		runs := []ScheduleRun{run}
+		for _, r := range list[i].LastRuns {
+			if !r.Scheduled.Equal(run.Scheduled) {
+				runs = append(runs, r)
+			}
+		}
+		if len(runs) > runsKept {
+			runs = runs[:runsKept]
+		}
+		list[i].LastRuns = runs
+		return list, nil
+	})
+	if e != nil {
+		L.Gluster.Error(e.Error())
+	}
+}
+
+var nodeName, _ = os.Hostname()
+
+// StartScheduler runs the due schedules at the start of every minute.
+func StartScheduler() {
+	go func() {
+		for {
+			now := time.Now()
+			time.Sleep(now.Truncate(time.Minute).Add(time.Minute).Sub(now))
+			scheduleTick(time.Now().Truncate(time.Minute))
+		}
+	}()
+}
+
+// scheduleTick claims the schedules due at minute and starts a job for each.
+// The claim is stored with the schedule, so of all nodes only the first to
+// get the store lock runs it.
+func scheduleTick(minute time.Time) {
+	// most minutes nothing is due, look before taking the store lock
+	list, e := schedules.List("")
+	if e != nil {
+		L.Gluster.Error(e.Error())
+		return
+	}
+	pending := false
+	for _, schedule := range list {
+		if cron, e := ParseCron(schedule.Cron); e == nil && schedule.Enabled && cron.Match(minute) {
+			pending = true
+		}
+	}
+	if !pending {
+		return
+	}
+
+	var due []SnapshotSchedule
+	e = schedules.Update(func(list []SnapshotSchedule) ([]SnapshotSchedule, error) {
+		for i := range list {
+			if !list[i].Enabled || !list[i].LastScheduled.Before(minute) {
+				continue
+			}
+			cron, e := ParseCron(list[i].Cron)
+			if e != nil || !cron.Match(minute) {
+				continue
+			}
+			list[i].LastScheduled = minute
+			due = append(due, list[i])
+		}
+		return list, nil
+	})
+	if e != nil {
+		L.Gluster.Error(e.Error())
+		return
+	}
+	for _, schedule := range due {
+		startScheduleRun(schedule, minute)
+	}
+}
+
+// startScheduleRun takes and prunes the snapshots of schedule as a job.
+func startScheduleRun(schedule SnapshotSchedule, scheduled time.Time) Job {
+	run := ScheduleRun{Scheduled: scheduled, Node: nodeName, Result: "RUNNING"}
+	recorded := make(chan struct{}) // the RUNNING record goes first
+	job := jobs.Start("snapshot schedule", schedule.Volname, func(ctx context.Context, update func(JobProgress)) (string, error) {
+		<-recorded
+		ctx, cancel := context.WithTimeout(ctx, ScheduleRunTimeout)
+		defer cancel()
+		e := runSchedule(ctx, schedule, &run)
+		now := time.Now()
+		run.EndTime = &now
+		run.Result = "OK"
+		if e != nil {
+			run.Result = "ERROR"
+			run.Error = toError(e)
+		}
+		recordRun(schedule.ID, run)
+		return fmt.Sprintf("snapshot %s, pruned %v", run.Snapshot, run.Pruned), e
+	})
+	run.JobID = job.ID
+	recordRun(schedule.ID, run)
+	close(recorded)
+	return job
+}
+
+func runSchedule(ctx context.Context, schedule SnapshotSchedule, run *ScheduleRun) error {
+	release, e := locks.Acquire(ctx, volumeLock(schedule.Volname), "snapshot schedule "+schedule.ID, MaxLockWait)
+	if e != nil {
+		return e
+	}
+	defer release()
+
+	snapshot, e := createSnapshot(ctx, SnapshotCreateRequest{
+		CommonSnapshotRequest: CommonSnapshotRequest{Snapname: schedule.Prefix},
+		Volname:               schedule.Volname,
+		Description:           "scheduled by " + schedule.ID,
+	})
+	if e != nil {
+		return e
+	}
+	run.Snapshot = snapshot.Name
+
+	output, e := runGluster(ctx, "snapshot", "info", "volume", schedule.Volname, "--xml")
+	if e != nil {
+		return glusterError(output, e)
+	}
+	snapshots, e := parser.ParseSnapshotInfo(output)
+	if e != nil {
+		return glusterError(output, e)
+	}
+	for _, name := range expiredSnapshots(schedule, snapshots) {
+		output, e := runGlusterConfirm(ctx, "snapshot", "delete", name, "--xml")
+		if e != nil {
+			return glusterError(output, e)
+		}
+		run.Pruned = append(run.Pruned, name)
+	}
+	return nil
+}
+
+// expiredSnapshots returns the snapshots of schedule its retention does not
+// keep: the KeepLast newest, and the newest of each of the last KeepDaily days
+// and KeepWeekly ISO weeks. Without any retention every snapshot is kept.
+func expiredSnapshots(schedule SnapshotSchedule, snapshots []parser.Snapshot) []string {
+	if schedule.KeepLast == 0 && schedule.KeepDaily == 0 && schedule.KeepWeekly == 0 {
+		return nil
+	}
+
+	var own []parser.Snapshot
+	for _, snapshot := range snapshots {
+		if strings.HasPrefix(snapshot.Name, schedule.Prefix+"_GMT-") && snapshot.CreateTime != nil {
+			own = append(own, snapshot)
+		}
+	}
+	sort.Slice(own, func(i, j int) bool { return own[i].CreateTime.After(*own[j].CreateTime) })
+
+	keep := make(map[string]bool)
+	days := make(map[string]bool)
+	weeks := make(map[string]bool)
+	for i, snapshot := range own {
+		t := snapshot.CreateTime.Local()
+		if i < schedule.KeepLast {
+			keep[snapshot.Name] = true
+		}
+		day := t.Format("2006-01-02")
+		if !days[day] && len(days) < schedule.KeepDaily {
+			days[day] = true
+			keep[snapshot.Name] = true
+		}
+		year, week := t.ISOWeek()
+		key := fmt.Sprintf("%d-%d", year, week)
+		if !weeks[key] && len(weeks) < schedule.KeepWeekly {
+			weeks[key] = true
+			keep[snapshot.Name] = true
+		}
+	}
+
+	var expired []string
+	for _, snapshot := range own {
+		if !keep[snapshot.Name] {
+			expired = append(expired, snapshot.Name)
+		}
+	}
+	return expired
+}
+
+type SnapshotScheduleRequest struct {
+	ID         string `json:"id"` // update, delete and run
+	Volname    string `json:"volname"`
+	Cron       string `json:"cron"`
+	Prefix     string `json:"prefix"`
+	KeepLast   int    `json:"keep_last"`
+	KeepDaily  int    `json:"keep_daily"`
+	KeepWeekly int    `json:"keep_weekly"`
+	Enabled    *bool  `json:"enabled"` // default true
+}
+
+type SnapshotScheduleResponse struct {
+	CommonResponse
+	Schedule *SnapshotSchedule `json:"schedule,omitempty"`
+}
+
+type SnapshotScheduleListResponse struct {
+	CommonResponse
+	Schedules []SnapshotSchedule `json:"schedules"`
+}
+
+func (req SnapshotScheduleRequest) apply(schedule *SnapshotSchedule) {
+	schedule.Volname = req.Volname
+	schedule.Cron = req.Cron
+	schedule.Prefix = req.Prefix
+	schedule.KeepLast = req.KeepLast
+	schedule.KeepDaily = req.KeepDaily
+	schedule.KeepWeekly = req.KeepWeekly
+	schedule.Enabled = req.Enabled == nil || *req.Enabled
+}
+
+/*
+[example]
+curl -X POST http://127.0.0.1:7030/gluster/snapshot/schedule/add -H 'Content-Type: application/json' -d '{
+"volname": "test",
+"cron": "0 * * * *",
+"prefix": "test-hourly",
+"keep_last": 24,
+"keep_daily": 7,
+"keep_weekly": 4
+}'
+<- {"result":"OK","schedule":{"id":"...","volname":"test","cron":"0 * * * *",...,"enabled":true,"last_runs":null}}
+*/
+func ProcessSnapshotScheduleAdd(w http.ResponseWriter, r *http.Request) {
+	var rsp SnapshotScheduleResponse
+	defer writeResponse(w, &rsp)
+
+	// analyze request
+	var req SnapshotScheduleRequest
+	if e := readRequest(r, &req); e != nil {
+		L.Gluster.Error(e.Error())
+		rsp.Fail(e)
+		return
+	}
+
+	schedule := SnapshotSchedule{ID: newJobID()}
+	req.apply(&schedule)
+	e := schedules.Update(func(list []SnapshotSchedule) ([]SnapshotSchedule, error) {
+		if e := scheduleConflict(list, schedule, -1); e != nil {
+			return nil, e
+		}
+		return append(list, schedule), nil
+	})
+	if e != nil {
+		L.Gluster.Error(e.Error())
+		rsp.Fail(e)
+		return
+	}
+	rsp.Schedule = &schedule
+	rsp.Result = "OK"
+}
+
+/*
+[example]
+curl -X POST http://127.0.0.1:7030/gluster/snapshot/schedule/update -H 'Content-Type: application/json' -d '{
+"id": "...",
+"volname": "test",
+"cron": "30 * * * *",
+"prefix": "test-hourly",
+"keep_last": 12,
+"enabled": false
+}'
+<- {"result":"OK","schedule":{...}}
+*/
+func ProcessSnapshotScheduleUpdate(w http.ResponseWriter, r *http.Request) {
+	var rsp SnapshotScheduleResponse
+	defer writeResponse(w, &rsp)
+
+	// analyze request
+	var req SnapshotScheduleRequest
+	if e := readRequest(r, &req); e != nil {
+		L.Gluster.Error(e.Error())
+		rsp.Fail(e)
+		return
+	}
+
+	var schedule SnapshotSchedule
+	e := schedules.Update(func(list []SnapshotSchedule) ([]SnapshotSchedule, error) {
+		i := findSchedule(list, req.ID)
+		if i < 0 {
+			return nil, ErrScheduleNotFound
+		}
+		schedule = list[i]
+		req.apply(&schedule)
+		if e := scheduleConflict(list, schedule, i); e != nil {
+			return nil, e
+		}
+		list[i] = schedule
+		return list, nil
+	})
+	if e != nil {
+		L.Gluster.Error(e.Error())
+		rsp.Fail(e)
+		return
+	}
+	rsp.Schedule = &schedule
+	rsp.Result = "OK"
+}
+
+/*
+[example]
+curl -X POST http://127.0.0.1:7030/gluster/snapshot/schedule/delete -H 'Content-Type: application/json' -d '{
+"id": "..."
+}'
+<- {"result":"OK"}
+*/
+func ProcessSnapshotScheduleDelete(w http.ResponseWriter, r *http.Request) {
+	var rsp SnapshotScheduleResponse
+	defer writeResponse(w, &rsp)
+
+	// analyze request
+	var req SnapshotScheduleIDRequest
+	if e := readRequest(r, &req); e != nil {
+		L.Gluster.Error(e.Error())
+		rsp.Fail(e)
+		return
+	}
+
+	e := schedules.Update(func(list []SnapshotSchedule) ([]SnapshotSchedule, error) {
+		i := findSchedule(list, req.ID)
+		if i < 0 {
+			return nil, ErrScheduleNotFound
+		}
+		return append(list[:i], list[i+1:]...), nil
+	})
+	if e != nil {
+		L.Gluster.Error(e.Error())
+		rsp.Fail(e)
+		return
+	}
+	rsp.Result = "OK"
+}
+
+/*
+[example]
+curl -X GET 'http://127.0.0.1:7030/gluster/snapshot/schedule/list?volname=test'
+<- {"result":"OK","schedules":[{"id":"...","next_run":"...","last_runs":[{"scheduled":"...","node":"node1","result":"OK","snapshot":"test-hourly_GMT-...","pruned":[...]}]}]}
+*/
+func ProcessSnapshotScheduleList(w http.ResponseWriter, r *http.Request) {
+	var rsp SnapshotScheduleListResponse
+	defer writeResponse(w, &rsp)
+
+	var e error
+	rsp.Schedules, e = schedules.List(r.URL.Query().Get("volname"))
+	if e != nil {
+		L.Gluster.Error(e.Error())
+		rsp.Fail(e)
+		return
+	}
+	rsp.Result = "OK"
+}
+
+type SnapshotScheduleIDRequest struct {
+	ID string `json:"id"`
+}
+
+/*
+[example]
+curl -X POST http://127.0.0.1:7030/gluster/snapshot/schedule/run -H 'Content-Type: application/json' -d '{
+"id": "..."
+}'
+<- {"result":"OK","job_id":"..."}
+*/
+func ProcessSnapshotScheduleRun(w http.ResponseWriter, r *http.Request) {
+	var rsp SnapshotScheduleResponse
+	defer writeResponse(w, &rsp)
+
+	// analyze request
+	var req SnapshotScheduleIDRequest
+	if e := readRequest(r, &req); e != nil {
+		L.Gluster.Error(e.Error())
+		rsp.Fail(e)
+		return
+	}
+
+	list, e := schedules.List("")
+	if e != nil {
+		L.Gluster.Error(e.Error())
+		rsp.Fail(e)
+		return
+	}
+	i := findSchedule(list, req.ID)
+	if i < 0 {
+		rsp.Fail(ErrScheduleNotFound)
+		return
+	}
+	job := startScheduleRun(list[i], time.Now())
+	rsp.JobID = job.ID
+	rsp.Schedule = &list[i]
+	rsp.Result = "OK"
+}
@@ -0,0 +1,125 @@
+package gluster
+
+import (
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"hualu.com/gluster-rest/parser"
+)
+
+// scheduleStore points ScheduleFile at an empty store for the test.
+func scheduleStore(t *testing.T) {
+	previous := ScheduleFile
+	ScheduleFile = filepath.Join(t.TempDir(), "gluster-rest", "snapshot_schedules.json")
+	t.Cleanup(func() { ScheduleFile = previous })
+}
+
+func TestSnapshotScheduleUpdate(t *testing.T) {
+	scheduleStore(t)
+	add := func(prefix string) string {
+		var rsp SnapshotScheduleResponse
+		body := fmt.Sprintf(`{"volname": "test", "cron": "0 * * * *", "prefix": %q}`, prefix)
+		if status := serve(t, ProcessSnapshotScheduleAdd, body, &rsp); status != http.StatusOK {
+			t.Fatalf("add %s: status %d: %+v", prefix, status, rsp.Error)
+		}
+		return rsp.Schedule.ID
+	}
+	add("hourly")
+	daily := add("daily")
+
+	tests := []struct {
+		name   string
+		body   string
+		status int
+	}{
+		{"same prefix", `{"volname": "test", "cron": "0 0 * * *", "prefix": "daily", "keep_daily": 7}`, http.StatusOK},
+		{"prefix of another schedule", `{"volname": "test", "cron": "0 0 * * *", "prefix": "hourly"}`, http.StatusConflict},
+		{"prefix on another volume", `{"volname": "other", "cron": "0 0 * * *", "prefix": "hourly"}`, http.StatusOK},
+	}
+	for _, test := range tests {
+		var rsp SnapshotScheduleResponse
+		body := fmt.Sprintf(`{"id": %q, %s`, daily, test.body[1:])
+		if status := serve(t, ProcessSnapshotScheduleUpdate, body, &rsp); status != test.status {
+			t.Errorf("%s: status %d, want %d: %+v", test.name, status, test.status, rsp.Error)
+		}
+	}
+
+	var rsp SnapshotScheduleResponse
+	if status := serve(t, ProcessSnapshotScheduleAdd, `{"volname": "test", "cron": "0 * * * *", "prefix": "hourly"}`, &rsp); status != http.StatusConflict {
+		t.Errorf("add a second hourly: status %d, want 409", status)
+	}
+	if status := serve(t, ProcessSnapshotScheduleUpdate, `{"id": "nope", "volname": "test", "cron": "0 * * * *", "prefix": "x"}`, &rsp); status != http.StatusNotFound {
+		t.Errorf("update unknown: status %d, want 404", status)
+	}
+
+	list, e := schedules.List("")
+	if e != nil {
+		t.Fatal(e)
+	}
+	if len(list) != 2 || list[1].Volname != "other" || list[1].Prefix != "hourly" {
+		t.Errorf("schedules = %+v", list)
+	}
+}
+
+// TestScheduleLostRun checks a run whose node died is not RUNNING forever.
+func TestScheduleLostRun(t *testing.T) {
+	scheduleStore(t)
+	now := time.Now()
+	e := schedules.Update(func(list []SnapshotSchedule) ([]SnapshotSchedule, error) {
+		return append(list, SnapshotSchedule{ID: "s", Volname: "test", Cron: "0 * * * *", Prefix: "hourly", LastRuns: []ScheduleRun{
+			{Scheduled: now, Node: "node2", Result: "RUNNING"},
+			{Scheduled: now.Add(-ScheduleRunTimeout - time.Minute), Node: "node1", Result: "RUNNING"},
+		}}), nil
+	})
+	if e != nil {
+		t.Fatal(e)
+	}
+	list, e := schedules.List("test")
+	if e != nil {
+		t.Fatal(e)
+	}
+	runs := list[0].LastRuns
+	if runs[0].Result != "RUNNING" {
+		t.Errorf("current run = %+v", runs[0])
+	}
+	if runs[1].Result != "ERROR" || runs[1].Error == nil || runs[1].Error.Code != CodeTimeout {
+		t.Errorf("lost run = %+v", runs[1])
+	}
+}
+
+func TestExpiredSnapshots(t *testing.T) {
+	var snapshots []parser.Snapshot
+	start := time.Date(2019, 4, 1, 12, 0, 0, 0, time.Local) // a Monday
+	for i := 0; i < 10; i++ {
+		created := start.AddDate(0, 0, i)
+		snapshots = append(snapshots, parser.Snapshot{Name: fmt.Sprintf("daily_GMT-%d", i), CreateTime: &created})
+	}
+	other := start
+	snapshots = append(snapshots, parser.Snapshot{Name: "manual", CreateTime: &other})
+
+	tests := []struct {
+		name     string
+		schedule SnapshotSchedule
+		expired  int
+	}{
+		{"no retention", SnapshotSchedule{Prefix: "daily"}, 0},
+		{"last 3", SnapshotSchedule{Prefix: "daily", KeepLast: 3}, 7},
+		{"3 days", SnapshotSchedule{Prefix: "daily", KeepDaily: 3}, 7},
+		{"2 weeks", SnapshotSchedule{Prefix: "daily", KeepWeekly: 2}, 8}, // the 10th and the 7th, a Sunday
+		{"last 1 and 2 weeks", SnapshotSchedule{Prefix: "daily", KeepLast: 1, KeepWeekly: 2}, 8},
+	}
+	for _, test := range tests {
+		expired := expiredSnapshots(test.schedule, snapshots)
+		if len(expired) != test.expired {
+			t.Errorf("%s: expired %v, want %d", test.name, expired, test.expired)
+		}
+		for _, name := range expired {
+			if name == "manual" {
+				t.Errorf("%s: expired a snapshot of no schedule", test.name)
+			}
+		}
+	}
+}
@@ -0,0 +1,276 @@
+package gluster
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"hualu.com/gluster-rest/parser"
+	L "hualu.com/logger"
+)
+
+// VolumeShrinkRequest removes whole replica / disperse sets from a volume
+// after checking the remaining sets can hold their data.
+type VolumeShrinkRequest struct {
+	CommonVolumeRequest
+	Bricks  []string `json:"bricks"`   // every brick of the sets to remove
+	MinFree string   `json:"min_free"` // percent of the remaining capacity to keep free, 10 by default as cluster.min-free-disk
+}
+
+// ShrinkCapacity is the space of the volume before and after the shrink, in
+// bytes of data, replicas and redundancy excluded. It is computed from the
+// filesystems of the bricks, so it assumes every brick has a filesystem of
+// its own: a shrink is refused when two bricks of the volume report the same
+// device, and other files on a brick filesystem count as data.
+type ShrinkCapacity struct {
+	Used              uint64 `json:"used"`               // data on the whole volume
+	Moved             uint64 `json:"moved"`              // data on the removed sets
+	RemainingTotal    uint64 `json:"remaining_total"`    // capacity of the remaining sets
+	RemainingUsed     uint64 `json:"remaining_used"`     // data on the remaining sets after the migration
+	RemainingFreeRate int    `json:"remaining_free_pct"` // free percent of the remaining sets after the migration
+	MinFree           int    `json:"min_free_pct"`
+}
+
+type VolumeShrinkResponse struct {
+	CommonVolumeResponse
+	Capacity *ShrinkCapacity `json:"capacity,omitempty"`
+}
+
+// subvolumeSpace returns the capacity of subvolume and the data it holds: a
+// replica set holds what its smallest data brick holds, a disperse set that
+// times its data bricks. ok is false when a data brick reports no size, e.g.
+// because it is offline.
+func subvolumeSpace(subvolume parser.Subvolume, bricks map[string]parser.BrickStatus, factor uint64) (total, used uint64, ok bool) {
+	for i, brick := range subvolume.DataBricks() {
+		status, found := bricks[brick.Name]
+		if !found || status.SizeTotal == 0 {
+			return 0, 0, false
+		}
+		if i == 0 || status.SizeTotal < total {
+			total = status.SizeTotal
+		}
+		// replicas pending heal differ, count the fullest
+		if status.SizeFree < status.SizeTotal && status.SizeTotal-status.SizeFree > used {
+			used = status.SizeTotal - status.SizeFree
+		}
+	}
+	return total * factor, used * factor, true
+}
+
+// shrinkCapacity checks bricks are whole sets of volume, not all of them, and
+// computes whether the data of the removed sets fits on the others keeping
+// minFree percent free. The capacity is returned with the error when it does not.
+func shrinkCapacity(volume parser.Volume, bricks map[string]parser.BrickStatus, removed []string, minFree int) (*ShrinkCapacity, error) {
+	removing := make(map[string]bool)
+	for i, brick := range removed {
+		if !volumeHasBrick(volume, brick) {
+			return nil, invalid(fmt.Sprintf("bricks[%d]", i), brick, "is not a brick of volume "+volume.Name)
+		}
+		removing[brick] = true
+	}
+
+	devices := make(map[string]string)
+	for _, brick := range volume.Bricks {
+		status := bricks[brick.Name]
+		if status.Device == "" {
+			continue
+		}
+		device := brick.Host + ":" + status.Device
+		if other, ok := devices[device]; ok {
+			return nil, NewError(http.StatusConflict, CodeNotSupported,
+				"bricks %s and %s share the device %s, their usage cannot be told apart", other, brick.Name, device)
+		}
+		devices[device] = brick.Name
+	}
+
+	factor := uint64(1)
+	if volume.DisperseCount > 0 {
+		factor = uint64(volume.DisperseCount - volume.RedundancyCount)
+	}
+	capacity := &ShrinkCapacity{MinFree: minFree}
+	remaining := 0
+	for _, subvolume := range volume.Subvolumes {
+		n := 0
+		for _, brick := range subvolume.Bricks {
+			if removing[brick.Name] {
+				n++
+			}
+		}
+		if n > 0 && n < len(subvolume.Bricks) {
+			return nil, invalid("bricks", strings.Join(removed, ","),
+				fmt.Sprintf("must hold all %d bricks of %s, reducing the replica count is not a shrink", len(subvolume.Bricks), subvolume.Name))
+		}
+
+		total, used, ok := subvolumeSpace(subvolume, bricks, factor)
+		if !ok {
+			return nil, NewError(http.StatusServiceUnavailable, CodeBrickDown, "the usage of %s is unknown, its bricks must be online", subvolume.Name)
+		}
+		capacity.Used += used
+		if n > 0 {
+			capacity.Moved += used
+		} else {
+			capacity.RemainingTotal += total
+			remaining++
+		}
+	}
+	if remaining == 0 {
+		return nil, invalid("bricks", strings.Join(removed, ","), "cannot remove every set of the volume")
+	}
+
+	capacity.RemainingUsed = capacity.Used
+	if capacity.RemainingUsed < capacity.RemainingTotal {
+		capacity.RemainingFreeRate = int((capacity.RemainingTotal - capacity.RemainingUsed) * 100 / capacity.RemainingTotal)
+	}
+	if capacity.RemainingUsed > capacity.RemainingTotal || capacity.RemainingFreeRate < minFree {
+		return capacity, NewError(http.StatusInsufficientStorage, CodeInsufficientSpace,
+			"the remaining sets cannot take %d bytes: %d of %d bytes would be used, %d%% free is required",
+			capacity.Moved, capacity.RemainingUsed, capacity.RemainingTotal, minFree)
+	}
+	return capacity, nil
+}
+
+func volumeHasBrick(volume parser.Volume, brick string) bool {
+	for _, b := range volume.Bricks {
+		if b.Name == brick {
+			return true
+		}
+	}
+	return false
+}
+
+// steps of the volume shrink
+const (
+	stepRemoveStart  = "start remove-brick"
+	stepMigrate      = "migrate data"
+	stepRemoveCommit = "commit remove-brick"
+)
+
+// shrinkJob migrates the data off the bricks and commits their removal once
+// every node completed. Cancelling the job stops the migration, the bricks
+// then stay in the volume.
+func shrinkJob(req VolumeShrinkRequest) JobFunc {
+	return func(ctx context.Context, update func(JobProgress)) (string, error) {
+		steps := newJobSteps(update, stepRemoveStart, stepMigrate, stepRemoveCommit)
+		removeReq := VolumeRemoveBrickRequest{CommonVolumeRequest: req.CommonVolumeRequest, Bricks: req.Bricks}
+
+		e := steps.Run(stepRemoveStart, func() (string, error) {
+			output, e := runGluster(ctx, removeBrickArgs(removeReq, "start", "--xml")...)
+			if e != nil {
+				L.Gluster.Error(string(output))
+				return "", glusterError(output, e)
+			}
+			return "migrating data off " + strconv.Itoa(len(req.Bricks)) + " bricks", nil
+		})
+		if e != nil {
+			return "", e
+		}
+
+		e = steps.Run(stepMigrate, func() (string, error) {
+			if _, e := followRemoveBrick(ctx, removeReq, steps.Progress); e != nil {
+				return "", e
+			}
+			return "completed on every node", nil
+		})
+		if e != nil {
+			return "", e
+		}
+
+		var output []byte
+		e = steps.Run(stepRemoveCommit, func() (string, error) {
+			status := RemoveBrickStatus(ctx, removeReq)
+			if status.Error != nil {
+				return "", status.Error
+			}
+			if e := removeBrickCommittable(status.VolRemoveBrick); e != nil {
+				return "", e
+			}
+			var e error
+			output, e = runGlusterConfirm(ctx, removeBrickArgs(removeReq, "commit")...)
+			if e != nil {
+				L.Gluster.Error(string(output))
+				return "", glusterError(output, e)
+			}
+			return strings.TrimSpace(string(output)), nil
+		})
+		return string(output), e
+	}
+}
+
+/*
+[example]
+docker exec glusterfs gluster volume status test detail --xml
+docker exec glusterfs gluster volume remove-brick test node4:/data/test node5:/data/test node6:/data/test start --xml
+
+curl -X POST http://127.0.0.1:7030/gluster/volume/shrink -H 'Content-Type: application/json' -d '{
+"volname": "test",
+"bricks": ["node4:/data/test", "node5:/data/test", "node6:/data/test"],
+"min_free": "10"
+}'
+<- {"result":"OK","job_id":"9f2c6a1e0b7d4c35","capacity":{"used":429496729600,"moved":107374182400,"remaining_total":1099511627776,"remaining_used":429496729600,"remaining_free_pct":60,"min_free_pct":10}}
+<- {"result":"ERROR","error":{"status":507,"code":"insufficient_space","message":"the remaining sets cannot take 107374182400 bytes: ..."},"capacity":{...}}
+
+curl -X GET http://127.0.0.1:7030/gluster/jobs/9f2c6a1e0b7d4c35
+<- {"result":"OK","job":{"id":"9f2c6a1e0b7d4c35","op":"shrink","volname":"test","state":"running","progress":{"status":"in progress","files":1200,...,
+"steps":[{"name":"start remove-brick","state":"done",...},{"name":"migrate data","state":"running",...},{"name":"commit remove-brick","state":"pending"}]}}}
+*/
+func ProcessVolumeShrink(w http.ResponseWriter, r *http.Request) {
+	var rsp VolumeShrinkResponse
+	defer writeResponse(w, &rsp)
+
+	// analyze request
+	var req VolumeShrinkRequest
+	if e := readRequest(r, &req); e != nil {
+		L.Gluster.Error(e.Error())
+		rsp.Fail(e)
+		return
+	}
+	minFree := 10
+	if req.MinFree != "" {
+		minFree, _ = strconv.Atoi(req.MinFree)
+	}
+
+	// the lock is held until the removal is committed, nothing may be added meanwhile
+	release, e := lockForRequest(r, volumeLock(req.Volname), "shrink")
+	if e != nil {
+		L.Gluster.Error(e.Error())
+		rsp.Fail(e)
+		return
+	}
+	capacity, e := shrinkChecks(r.Context(), req, minFree)
+	rsp.Capacity = capacity
+	if e != nil {
+		release()
+		L.Gluster.Error(e.Error())
+		rsp.Fail(e)
+		return
+	}
+
+	job := jobs.Start("shrink", req.Volname, locked(release, shrinkJob(req)))
+	rsp.JobID = job.ID
+	rsp.Result = "OK"
+}
+
+// shrinkChecks reads the volume and the usage of its bricks and checks the
+// shrink, see shrinkCapacity.
+func shrinkChecks(ctx context.Context, req VolumeShrinkRequest, minFree int) (*ShrinkCapacity, error) {
+	volumes, e := volumesInfo(ctx, req.Volname)
+	if e != nil {
+		return nil, e
+	}
+	if len(volumes) == 0 {
+		return nil, NewError(http.StatusNotFound, CodeVolumeNotFound, "volume %s does not exist", req.Volname)
+	}
+	if volumes[0].Status != parser.VolumeStarted {
+		return nil, NewError(http.StatusConflict, CodeVolumeStopped, "volume %s is not started, data cannot be migrated", req.Volname)
+	}
+	if _, e := volumeLayout(volumes[0]); e != nil {
+		return nil, e
+	}
+	bricks, e := bricksStatus(ctx, req.Volname)
+	if e != nil {
+		return nil, e
+	}
+	return shrinkCapacity(volumes[0], bricks, req.Bricks, minFree)
+}
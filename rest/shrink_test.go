@@ -0,0 +1,161 @@
+package gluster
+
+import (
+	"path"
+	"testing"
+
+	"hualu.com/gluster-rest/parser"
+)
+
+// shrinkVolume is the volume test with the given counts, the last brick of
+// each set is an arbiter when arbiter is set.
+func shrinkVolume(size, replica, disperse, redundancy int, arbiter bool, bricks ...string) parser.Volume {
+	v := parser.Volume{Name: "test", SubvolumeSize: size, ReplicaCount: replica, DisperseCount: disperse, RedundancyCount: redundancy}
+	if arbiter {
+		v.ArbiterCount = 1
+	}
+	for i, name := range bricks {
+		host, p := parser.SplitBrick(name)
+		v.Bricks = append(v.Bricks, parser.Brick{Name: name, Host: host, Path: p, IsArbiter: arbiter && (i+1)%size == 0})
+	}
+	v.Subvolumes = parser.GroupSubvolumes(v)
+	return v
+}
+
+// brickSpace is the status of an online brick with a device of its own.
+func brickSpace(name string, total, used uint64) parser.BrickStatus {
+	_, p := parser.SplitBrick(name)
+	return parser.BrickStatus{Name: name, Online: true, SizeTotal: total * SIZE_GB, SizeFree: (total - used) * SIZE_GB, Device: "/dev/" + path.Base(p)}
+}
+
+func spaces(statuses ...parser.BrickStatus) map[string]parser.BrickStatus {
+	bricks := make(map[string]parser.BrickStatus)
+	for _, status := range statuses {
+		bricks[status.Name] = status
+	}
+	return bricks
+}
+
+func TestSubvolumeSpace(t *testing.T) {
+	replica := shrinkVolume(3, 3, 0, 0, true, "node1:/data/b1", "node2:/data/b1", "node3:/data/b1")
+	disperse := shrinkVolume(3, 1, 3, 1, false, "node1:/data/b1", "node2:/data/b1", "node3:/data/b1")
+	tests := []struct {
+		name        string
+		volume      parser.Volume
+		bricks      map[string]parser.BrickStatus
+		factor      uint64
+		total, used uint64
+		ok          bool
+	}{
+		{"smallest and fullest data brick", replica, spaces(brickSpace("node1:/data/b1", 100, 20), brickSpace("node2:/data/b1", 80, 25), brickSpace("node3:/data/b1", 1, 1)),
+			1, 80, 25, true},
+		{"disperse data bricks", disperse, spaces(brickSpace("node1:/data/b1", 100, 10), brickSpace("node2:/data/b1", 100, 10), brickSpace("node3:/data/b1", 100, 10)),
+			2, 200, 20, true},
+		{"offline data brick", replica, spaces(brickSpace("node1:/data/b1", 100, 20), brickSpace("node3:/data/b1", 1, 1)),
+			1, 0, 0, false},
+		{"offline arbiter", replica, spaces(brickSpace("node1:/data/b1", 100, 20), brickSpace("node2:/data/b1", 100, 20)),
+			1, 100, 20, true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			total, used, ok := subvolumeSpace(test.volume.Subvolumes[0], test.bricks, test.factor)
+			if ok != test.ok || total != test.total*SIZE_GB || used != test.used*SIZE_GB {
+				t.Errorf("got %d, %d, %v, want %d GB, %d GB, %v", total, used, ok, test.total, test.used, test.ok)
+			}
+		})
+	}
+}
+
+func TestShrinkCapacity(t *testing.T) {
+	replica2 := shrinkVolume(2, 2, 0, 0, false, "node1:/data/b1", "node2:/data/b1", "node1:/data/b2", "node2:/data/b2")
+	arbiter := shrinkVolume(3, 3, 0, 0, true, "node1:/data/b1", "node2:/data/b1", "node3:/data/b1", "node1:/data/b2", "node2:/data/b2", "node3:/data/b2")
+	disperse := shrinkVolume(3, 1, 3, 1, false, "node1:/data/b1", "node2:/data/b1", "node3:/data/b1", "node1:/data/b2", "node2:/data/b2", "node3:/data/b2")
+	secondSet := []string{"node1:/data/b2", "node2:/data/b2"}
+	replicaUsage := func(b1, b2 uint64) map[string]parser.BrickStatus {
+		return spaces(brickSpace("node1:/data/b1", 100, b1), brickSpace("node2:/data/b1", 100, b1),
+			brickSpace("node1:/data/b2", 100, b2), brickSpace("node2:/data/b2", 100, b2))
+	}
+	tests := []struct {
+		name     string
+		volume   parser.Volume
+		bricks   map[string]parser.BrickStatus
+		removed  []string
+		minFree  int
+		capacity *ShrinkCapacity // in GB
+		field    string          // the invalid field
+		code     string
+	}{
+		{"fits", replica2, replicaUsage(30, 20), secondSet, 10,
+			&ShrinkCapacity{Used: 50, Moved: 20, RemainingTotal: 100, RemainingUsed: 50, RemainingFreeRate: 50, MinFree: 10}, "", ""},
+		{"below min free", replica2, replicaUsage(60, 35), secondSet, 10,
+			&ShrinkCapacity{Used: 95, Moved: 35, RemainingTotal: 100, RemainingUsed: 95, RemainingFreeRate: 5, MinFree: 10}, "", CodeInsufficientSpace},
+		{"no min free", replica2, replicaUsage(60, 35), secondSet, 0,
+			&ShrinkCapacity{Used: 95, Moved: 35, RemainingTotal: 100, RemainingUsed: 95, RemainingFreeRate: 5}, "", ""},
+		{"overflow", replica2, replicaUsage(80, 40), secondSet, 0,
+			&ShrinkCapacity{Used: 120, Moved: 40, RemainingTotal: 100, RemainingUsed: 120}, "", CodeInsufficientSpace},
+		{"smallest remaining brick", replica2, spaces(brickSpace("node1:/data/b1", 100, 30), brickSpace("node2:/data/b1", 60, 30),
+			brickSpace("node1:/data/b2", 100, 20), brickSpace("node2:/data/b2", 100, 20)), secondSet, 10,
+			&ShrinkCapacity{Used: 50, Moved: 20, RemainingTotal: 60, RemainingUsed: 50, RemainingFreeRate: 16, MinFree: 10}, "", ""},
+		{"arbiter left out", arbiter, spaces(brickSpace("node1:/data/b1", 100, 30), brickSpace("node2:/data/b1", 100, 30), brickSpace("node3:/data/b1", 1, 1),
+			brickSpace("node1:/data/b2", 100, 20), brickSpace("node2:/data/b2", 100, 20), brickSpace("node3:/data/b2", 1, 1)),
+			[]string{"node1:/data/b2", "node2:/data/b2", "node3:/data/b2"}, 10,
+			&ShrinkCapacity{Used: 50, Moved: 20, RemainingTotal: 100, RemainingUsed: 50, RemainingFreeRate: 50, MinFree: 10}, "", ""},
+		{"disperse factor", disperse, spaces(brickSpace("node1:/data/b1", 100, 10), brickSpace("node2:/data/b1", 100, 10), brickSpace("node3:/data/b1", 100, 10),
+			brickSpace("node1:/data/b2", 100, 10), brickSpace("node2:/data/b2", 100, 10), brickSpace("node3:/data/b2", 100, 10)),
+			[]string{"node1:/data/b2", "node2:/data/b2", "node3:/data/b2"}, 10,
+			&ShrinkCapacity{Used: 40, Moved: 20, RemainingTotal: 200, RemainingUsed: 40, RemainingFreeRate: 80, MinFree: 10}, "", ""},
+		{"shared device", replica2, func() map[string]parser.BrickStatus {
+			bricks := replicaUsage(30, 20)
+			shared := bricks["node1:/data/b2"]
+			shared.Device = "/dev/b1"
+			bricks["node1:/data/b2"] = shared
+			return bricks
+		}(), secondSet, 10, nil, "", CodeNotSupported},
+		{"same device name on two hosts", replica2, replicaUsage(30, 20), secondSet, 10,
+			&ShrinkCapacity{Used: 50, Moved: 20, RemainingTotal: 100, RemainingUsed: 50, RemainingFreeRate: 50, MinFree: 10}, "", ""},
+		{"partial set", replica2, replicaUsage(30, 20), []string{"node1:/data/b2"}, 10, nil, "bricks", ""},
+		{"every set", replica2, replicaUsage(30, 20), []string{"node1:/data/b1", "node2:/data/b1", "node1:/data/b2", "node2:/data/b2"}, 10, nil, "bricks", ""},
+		{"not a brick", replica2, replicaUsage(30, 20), []string{"node3:/data/b2"}, 10, nil, "bricks[0]", ""},
+		{"offline brick", replica2, func() map[string]parser.BrickStatus {
+			bricks := replicaUsage(30, 20)
+			delete(bricks, "node2:/data/b1")
+			return bricks
+		}(), secondSet, 10, nil, "", CodeBrickDown},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			capacity, e := shrinkCapacity(test.volume, test.bricks, test.removed, test.minFree)
+			switch err := e.(type) {
+			case nil:
+				if test.field != "" || test.code != "" {
+					t.Errorf("got no error, want %s%s", test.field, test.code)
+				}
+			case *ValidationError:
+				if err.Field != test.field {
+					t.Errorf("got %v, want invalid %q", e, test.field)
+				}
+			case *Error:
+				if err.Code != test.code {
+					t.Errorf("got %v, want %s", e, test.code)
+				}
+			default:
+				t.Errorf("got %v", e)
+			}
+
+			if test.capacity == nil {
+				if capacity != nil {
+					t.Errorf("capacity = %+v", capacity)
+				}
+				return
+			}
+			want := *test.capacity
+			want.Used *= SIZE_GB
+			want.Moved *= SIZE_GB
+			want.RemainingTotal *= SIZE_GB
+			want.RemainingUsed *= SIZE_GB
+			if capacity == nil || *capacity != want {
+				t.Errorf("capacity = %+v, want %+v", capacity, want)
+			}
+		})
+	}
+}
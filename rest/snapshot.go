@@ -0,0 +1,725 @@
+package gluster
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	"hualu.com/gluster-rest/parser"
+	L "hualu.com/logger"
+)
+
+type CommonSnapshotRequest struct {
+	Snapname string `json:"snapname"`
+	Force    string `json:"force"` // activate only
+}
+
+type SnapshotCreateRequest struct {
+	CommonSnapshotRequest
+	Volname     string `json:"volname"`
+	Description string `json:"description"`
+	NoTimestamp string `json:"no_timestamp"` // keep the name without the _GMT-<time> suffix
+}
+
+// SnapshotQueryRequest selects one snapshot, the snapshots of a volume, or
+// every snapshot when both are empty.
+type SnapshotQueryRequest struct {
+	Snapname string `json:"snapname"`
+	Volname  string `json:"volname"`
+}
+
+type SnapshotCloneRequest struct {
+	CommonSnapshotRequest
+	Clonename string `json:"clonename"`
+}
+
+// SnapshotConfigRequest changes the limits of a volume, or the system wide
+// ones when Volname is empty. Empty fields are left unchanged.
+type SnapshotConfigRequest struct {
+	Volname          string `json:"volname"`
+	HardLimit        string `json:"snap_max_hard_limit"`
+	SoftLimit        string `json:"snap_max_soft_limit"` // percent
+	AutoDelete       string `json:"auto_delete"`         // enable, disable; system wide only
+	ActivateOnCreate string `json:"activate_on_create"`  // enable, disable; system wide only
+}
+
+type SnapshotResponse struct {
+	CommonResponse
+	Snapshot *parser.Snapshot `json:"snapshot,omitempty"`
+}
+
+type SnapshotListResponse struct {
+	CommonResponse
+	Snapshots []string `json:"snapshots"`
+}
+
+type SnapshotInfoResponse struct {
+	CommonResponse
+	Snapshots []parser.Snapshot `json:"snapshots"`
+}
+
+type SnapshotStatusResponse struct {
+	CommonResponse
+	Snapshots []parser.SnapshotStatus `json:"snapshots"`
+}
+
+type SnapshotConfigResponse struct {
+	CommonResponse
+	Config *parser.SnapshotConfig `json:"config,omitempty"`
+}
+
+// snapshotQueryArgs builds "<snap>" or "volume <vol>" for list/info/status.
+func snapshotQueryArgs(req SnapshotQueryRequest) []string {
+	switch {
+	case req.Snapname != "":
+		return []string{req.Snapname}
+	case req.Volname != "":
+		return []string{"volume", req.Volname}
+	}
+	return nil
+}
+
+// snapshotInfo returns `snapshot info <snap>`.
+func snapshotInfo(ctx context.Context, snapname string) (parser.Snapshot, error) {
+	output, e := runGluster(ctx, "snapshot", "info", snapname, "--xml")
+	if e != nil {
+		return parser.Snapshot{}, glusterError(output, e)
+	}
+	snapshots, e := parser.ParseSnapshotInfo(output)
+	if e != nil {
+		return parser.Snapshot{}, glusterError(output, e)
+	}
+	if len(snapshots) == 0 {
+		return parser.Snapshot{}, NewError(http.StatusNotFound, CodeSnapshotNotFound, "snapshot %s does not exist", snapname)
+	}
+	return snapshots[0], nil
+}
+
+// localPeerUUID returns the uuid of the glusterd this service talks to.
+func localPeerUUID(ctx context.Context) (string, error) {
+	output, e := runGluster(ctx, "system::", "uuid", "get")
+	if e != nil {
+		return "", glusterError(output, e)
+	}
+	// UUID: 3d1d1d0b-...
+	fields := strings.Fields(string(output))
+	if len(fields) != 2 || fields[0] != "UUID:" {
+		return "", NewError(http.StatusInternalServerError, CodeInternal, "unexpected uuid output: %q", output)
+	}
+	return fields[1], nil
+}
+
+// checkThinBricks makes sure every brick of volume sits on a thin provisioned
+// LV, which gluster snapshots require. Bricks of other peers are checked by the
+// gluster-rest service of their peer, see ProcessBrickThinCheck.
+func checkThinBricks(ctx context.Context, volume parser.Volume) error {
+	local, e := localPeerUUID(ctx)
+	if e != nil {
+		return e
+	}
+	for _, brick := range volume.Bricks {
+		var device string
+		var thin bool
+		if brick.HostUUID == local {
+			device, thin, e = thinDevice(ctx, brick.Path)
+		} else {
+			device, thin, e = remoteThinDevice(ctx, brick.Host, brick.Path)
+		}
+		if e != nil {
+			return e
+		}
+		if !thin {
+			return NewError(http.StatusConflict, CodeNotThinProvisioned,
+				"brick %s is on %s which is not a thinly provisioned LV", brick.Name, device)
+		}
+	}
+	return nil
+}
+
+// thinDevice returns the device path is on and whether it is a thin LV.
+func thinDevice(ctx context.Context, path string) (string, bool, error) {
+	output, e := run(ctx, Command{Name: "df", Args: []string{"--output=source", path}})
+	if e != nil {
+		return "", false, glusterError(output, e)
+	}
+	lines := strings.Fields(string(output)) // header, device
+	if len(lines) < 2 {
+		return "", false, NewError(http.StatusInternalServerError, CodeInternal, "unexpected df output for %s: %q", path, output)
+	}
+	device := lines[len(lines)-1]
+
+	output, e = run(ctx, Command{Name: "lvs", Args: []string{"--noheadings", "-o", "lv_attr", device}})
+	attr := strings.TrimSpace(string(output))
+	return device, e == nil && attr != "" && attr[0] == 'V', nil // V: thin volume
+}
+
+// remoteThinDevice asks the gluster-rest service of host for the device of
+// path, a peer that cannot be asked fails the check.
+func remoteThinDevice(ctx context.Context, host, path string) (string, bool, error) {
+	var rsp BrickThinResponse
+	if e := callPeer(ctx, host, "/gluster/brick/thin/check", BrickThinRequest{Path: path}, &rsp); e != nil {
+		return "", false, NewError(http.StatusServiceUnavailable, CodePeerNotConnected,
+			"cannot check the device of brick %s:%s: %s", host, path, e)
+	}
+	if rsp.Error != nil {
+		return "", false, rsp.Error
+	}
+	return rsp.Device, rsp.Thin, nil
+}
+
+type BrickThinRequest struct {
+	Path string `json:"path"`
+}
+
+type BrickThinResponse struct {
+	CommonResponse
+	Device string `json:"device"`
+	Thin   bool   `json:"thin"` // a thinly provisioned LV
+}
+
+/*
+[example]
+curl -X POST http://node2:7030/gluster/brick/thin/check -H 'Content-Type: application/json' -d '{
+"path": "/data/brick1/test"
+}'
+<- {"result":"OK","device":"/dev/mapper/vg_bricks-test","thin":true}
+*/
+func ProcessBrickThinCheck(w http.ResponseWriter, r *http.Request) {
+	var rsp BrickThinResponse
+	defer writeResponse(w, &rsp)
+
+	// analyze request
+	var req BrickThinRequest
+	if e := readRequest(r, &req); e != nil {
+		L.Gluster.Error(e.Error())
+		rsp.Fail(e)
+		return
+	}
+
+	device, thin, e := thinDevice(r.Context(), req.Path)
+	if e != nil {
+		L.Gluster.Error(e.Error())
+		rsp.Fail(e)
+		return
+	}
+	rsp.Device = device
+	rsp.Thin = thin
+	rsp.Result = "OK"
+}
+
+/*
+[example]
+docker exec glusterfs gluster snapshot create snap1 test description "before upgrade" --xml
+
+curl -X POST http://127.0.0.1:7030/gluster/snapshot/create -H 'Content-Type: application/json' -d '{
+"snapname": "snap1",
+"volname": "test",
+"description": "before upgrade"
+}'
+<- {"result":"OK","snapshot":{"name":"snap1_GMT-2019.04.11-08.25.34","uuid":"...","volume":"test",...}}
+*/
+func ProcessSnapshotCreate(w http.ResponseWriter, r *http.Request) {
+	var rsp SnapshotResponse
+	defer writeResponse(w, &rsp)
+
+	// analyze request
+	var req SnapshotCreateRequest
+	if e := readRequest(r, &req); e != nil {
+		L.Gluster.Error(e.Error())
+		rsp.Fail(e)
+		return
+	}
+
+	release, e := lockForRequest(r, volumeLock(req.Volname), "snapshot create")
+	if e != nil {
+		L.Gluster.Error(e.Error())
+		rsp.Fail(e)
+		return
+	}
+	defer release()
+
+	snapshot, e := createSnapshot(r.Context(), req)
+	if e != nil {
+		L.Gluster.Error(e.Error())
+		rsp.Fail(e)
+		return
+	}
+	rsp.Snapshot = &snapshot
+	rsp.Result = "OK"
+}
+
+// createSnapshot checks the volume can be snapshotted and takes the snapshot.
+// The caller holds the volume lock.
+func createSnapshot(ctx context.Context, req SnapshotCreateRequest) (parser.Snapshot, error) {
+	volumes, e := volumesInfo(ctx, req.Volname)
+	if e != nil {
+		return parser.Snapshot{}, e
+	}
+	if len(volumes) == 0 {
+		return parser.Snapshot{}, NewError(http.StatusNotFound, CodeVolumeNotFound, "volume %s does not exist", req.Volname)
+	}
+	if volumes[0].Status != parser.VolumeStarted {
+		return parser.Snapshot{}, NewError(http.StatusConflict, CodeVolumeStopped, "volume %s is not started", req.Volname)
+	}
+	if e := checkThinBricks(ctx, volumes[0]); e != nil {
+		return parser.Snapshot{}, e
+	}
+
+	args := []string{"snapshot", "create", req.Snapname, req.Volname}
+	if req.NoTimestamp == "true" {
+		args = append(args, "no-timestamp")
+	}
+	if req.Description != "" {
+		args = append(args, "description", req.Description)
+	}
+	if req.Force == "true" {
+		args = append(args, "force")
+	}
+	output, e := runGluster(ctx, append(args, "--xml")...)
+	if e != nil {
+		L.Gluster.Error(string(output))
+		return parser.Snapshot{}, glusterError(output, e)
+	}
+	snapshot, e := parser.ParseSnapshotAction(output, "create")
+	if e != nil {
+		return parser.Snapshot{}, glusterError(output, e)
+	}
+
+	// the reply only has name and uuid
+	if info, e := snapshotInfo(ctx, snapshot.Name); e == nil {
+		snapshot = info
+	}
+	return snapshot, nil
+}
+
+/*
+[example]
+docker exec glusterfs gluster snapshot list test --xml
+
+curl -X POST http://127.0.0.1:7030/gluster/snapshot/list -H 'Content-Type: application/json' -d '{
+"volname": "test"
+}'
+<- {"result":"OK","snapshots":["snap1_GMT-2019.04.11-08.25.34"]}
+*/
+func ProcessSnapshotList(w http.ResponseWriter, r *http.Request) {
+	var rsp SnapshotListResponse
+	defer writeResponse(w, &rsp)
+
+	// analyze request
+	var req SnapshotQueryRequest
+	if e := readRequest(r, &req); e != nil {
+		L.Gluster.Error(e.Error())
+		rsp.Fail(e)
+		return
+	}
+
+	args := []string{"snapshot", "list"}
+	if req.Volname != "" {
+		args = append(args, req.Volname)
+	}
+	output, e := runGluster(r.Context(), append(args, "--xml")...)
+	if e != nil {
+		L.Gluster.Error(string(output))
+		rsp.Fail(glusterError(output, e))
+		return
+	}
+	rsp.Snapshots, e = parser.ParseSnapshotList(output)
+	if e != nil {
+		L.Gluster.Error(e.Error())
+		rsp.Fail(glusterError(output, e))
+		return
+	}
+	rsp.Result = "OK"
+}
+
+/*
+[example]
+docker exec glusterfs gluster snapshot info volume test --xml
+
+curl -X POST http://127.0.0.1:7030/gluster/snapshot/info -H 'Content-Type: application/json' -d '{
+"volname": "test"
+}'
+<- {"result":"OK","snapshots":[{"name":"snap1","uuid":"...","create_time":"...","volume":"test","status":"Stopped",...}]}
+*/
+func ProcessSnapshotInfo(w http.ResponseWriter, r *http.Request) {
+	var rsp SnapshotInfoResponse
+	defer writeResponse(w, &rsp)
+
+	// analyze request
+	var req SnapshotQueryRequest
+	if e := readRequest(r, &req); e != nil {
+		L.Gluster.Error(e.Error())
+		rsp.Fail(e)
+		return
+	}
+
+	args := append([]string{"snapshot", "info"}, snapshotQueryArgs(req)...)
+	output, e := runGluster(r.Context(), append(args, "--xml")...)
+	if e != nil {
+		L.Gluster.Error(string(output))
+		rsp.Fail(glusterError(output, e))
+		return
+	}
+	rsp.Snapshots, e = parser.ParseSnapshotInfo(output)
+	if e != nil {
+		L.Gluster.Error(e.Error())
+		rsp.Fail(glusterError(output, e))
+		return
+	}
+	rsp.Result = "OK"
+}
+
+/*
+[example]
+docker exec glusterfs gluster snapshot status snap1 --xml
+
+curl -X POST http://127.0.0.1:7030/gluster/snapshot/status -H 'Content-Type: application/json' -d '{
+"snapname": "snap1"
+}'
+<- {"result":"OK","snapshots":[{"name":"snap1","uuid":"...","bricks":[{"path":"...","volume_group":"vg0","running":true,...}]}]}
+*/
+func ProcessSnapshotStatus(w http.ResponseWriter, r *http.Request) {
+	var rsp SnapshotStatusResponse
+	defer writeResponse(w, &rsp)
+
+	// analyze request
+	var req SnapshotQueryRequest
+	if e := readRequest(r, &req); e != nil {
+		L.Gluster.Error(e.Error())
+		rsp.Fail(e)
+		return
+	}
+
+	args := append([]string{"snapshot", "status"}, snapshotQueryArgs(req)...)
+	output, e := runGluster(r.Context(), append(args, "--xml")...)
+	if e != nil {
+		L.Gluster.Error(string(output))
+		rsp.Fail(glusterError(output, e))
+		return
+	}
+	rsp.Snapshots, e = parser.ParseSnapshotStatus(output)
+	if e != nil {
+		L.Gluster.Error(e.Error())
+		rsp.Fail(glusterError(output, e))
+		return
+	}
+	rsp.Result = "OK"
+}
+
+// snapshotAction runs `snapshot <op> <snap> [args...] --xml` under the lock
+// of the snapshot and answers with the snapshot it acted on.
+func snapshotAction(w http.ResponseWriter, r *http.Request, op string, confirm bool) {
+	var rsp SnapshotResponse
+	defer writeResponse(w, &rsp)
+
+	// analyze request
+	var req CommonSnapshotRequest
+	if e := readRequest(r, &req); e != nil {
+		L.Gluster.Error(e.Error())
+		rsp.Fail(e)
+		return
+	}
+
+	release, e := lockForRequest(r, snapshotLock(req.Snapname), "snapshot "+op)
+	if e != nil {
+		L.Gluster.Error(e.Error())
+		rsp.Fail(e)
+		return
+	}
+	defer release()
+
+	args := []string{"snapshot", op, req.Snapname}
+	if op == "activate" && req.Force == "true" {
+		args = append(args, "force")
+	}
+	args = append(args, "--xml")
+	var output []byte
+	if confirm {
+		output, e = runGlusterConfirm(r.Context(), args...)
+	} else {
+		output, e = runGluster(r.Context(), args...)
+	}
+	if e != nil {
+		L.Gluster.Error(string(output))
+		rsp.Fail(glusterError(output, e))
+		return
+	}
+	snapshot, e := parser.ParseSnapshotAction(output, op)
+	if e != nil {
+		L.Gluster.Error(e.Error())
+		rsp.Fail(glusterError(output, e))
+		return
+	}
+	rsp.Snapshot = &snapshot
+	rsp.Result = "OK"
+}
+
+/*
+[example]
+docker exec glusterfs gluster snapshot activate snap1 --xml
+
+curl -X POST http://127.0.0.1:7030/gluster/snapshot/activate -H 'Content-Type: application/json' -d '{
+"snapname": "snap1"
+}'
+<- {"result":"OK","snapshot":{"name":"snap1","uuid":"..."}}
+*/
+func ProcessSnapshotActivate(w http.ResponseWriter, r *http.Request) {
+	snapshotAction(w, r, "activate", false)
+}
+
+/*
+[example]
+docker exec glusterfs sh -c "gluster snapshot deactivate snap1 --xml <<< y"
+
+curl -X POST http://127.0.0.1:7030/gluster/snapshot/deactivate -H 'Content-Type: application/json' -d '{
+"snapname": "snap1"
+}'
+<- {"result":"OK","snapshot":{"name":"snap1","uuid":"..."}}
+*/
+func ProcessSnapshotDeactivate(w http.ResponseWriter, r *http.Request) {
+	snapshotAction(w, r, "deactivate", true)
+}
+
+/*
+[example]
+docker exec glusterfs sh -c "gluster snapshot delete snap1 --xml <<< y"
+
+curl -X POST http://127.0.0.1:7030/gluster/snapshot/delete -H 'Content-Type: application/json' -d '{
+"snapname": "snap1"
+}'
+<- {"result":"OK","snapshot":{"name":"snap1","uuid":"..."}}
+*/
+func ProcessSnapshotDelete(w http.ResponseWriter, r *http.Request) {
+	snapshotAction(w, r, "delete", true)
+}
+
+/*
+[example]
+docker exec glusterfs sh -c "gluster snapshot restore snap1 --xml <<< y"
+
+curl -X POST http://127.0.0.1:7030/gluster/snapshot/restore -H 'Content-Type: application/json' -d '{
+"snapname": "snap1"
+}'
+<- {"result":"OK","snapshot":{"name":"snap1","volume":"test",...}}
+*/
+func ProcessSnapshotRestore(w http.ResponseWriter, r *http.Request) {
+	var rsp SnapshotResponse
+	defer writeResponse(w, &rsp)
+
+	// analyze request
+	var req CommonSnapshotRequest
+	if e := readRequest(r, &req); e != nil {
+		L.Gluster.Error(e.Error())
+		rsp.Fail(e)
+		return
+	}
+
+	snapshot, e := snapshotInfo(r.Context(), req.Snapname)
+	if e != nil {
+		L.Gluster.Error(e.Error())
+		rsp.Fail(e)
+		return
+	}
+
+	// restore replaces the bricks of the origin volume
+	release, e := lockForRequest(r, volumeLock(snapshot.Volume), "snapshot restore")
+	if e != nil {
+		L.Gluster.Error(e.Error())
+		rsp.Fail(e)
+		return
+	}
+	defer release()
+
+	volumes, e := volumesInfo(r.Context(), snapshot.Volume)
+	if e != nil {
+		L.Gluster.Error(e.Error())
+		rsp.Fail(e)
+		return
+	}
+	if len(volumes) > 0 && volumes[0].Status == parser.VolumeStarted {
+		rsp.Fail(NewError(http.StatusConflict, CodeVolumeStarted, "volume %s must be stopped before restoring %s", snapshot.Volume, req.Snapname))
+		return
+	}
+
+	output, e := runGlusterConfirm(r.Context(), "snapshot", "restore", req.Snapname, "--xml")
+	if e != nil {
+		L.Gluster.Error(string(output))
+		rsp.Fail(glusterError(output, e))
+		return
+	}
+	rsp.Snapshot = &snapshot
+	rsp.Result = "OK"
+}
+
+/*
+[example]
+docker exec glusterfs gluster snapshot clone test-clone snap1 --xml
+
+curl -X POST http://127.0.0.1:7030/gluster/snapshot/clone -H 'Content-Type: application/json' -d '{
+"clonename": "test-clone",
+"snapname": "snap1"
+}'
+<- {"result":"OK","snapshot":{"name":"test-clone","uuid":"..."}}
+*/
+func ProcessSnapshotClone(w http.ResponseWriter, r *http.Request) {
+	var rsp SnapshotResponse
+	defer writeResponse(w, &rsp)
+
+	// analyze request
+	var req SnapshotCloneRequest
+	if e := readRequest(r, &req); e != nil {
+		L.Gluster.Error(e.Error())
+		rsp.Fail(e)
+		return
+	}
+
+	// the clone is a new volume
+	release, e := lockForRequest(r, volumeLock(req.Clonename), "snapshot clone")
+	if e != nil {
+		L.Gluster.Error(e.Error())
+		rsp.Fail(e)
+		return
+	}
+	defer release()
+
+	snapshot, e := snapshotInfo(r.Context(), req.Snapname)
+	if e != nil {
+		L.Gluster.Error(e.Error())
+		rsp.Fail(e)
+		return
+	}
+	if !snapshot.Activated {
+		rsp.Fail(NewError(http.StatusConflict, CodeVolumeStopped, "snapshot %s must be activated before cloning", req.Snapname))
+		return
+	}
+
+	output, e := runGluster(r.Context(), "snapshot", "clone", req.Clonename, req.Snapname, "--xml")
+	if e != nil {
+		L.Gluster.Error(string(output))
+		rsp.Fail(glusterError(output, e))
+		return
+	}
+	clone, e := parser.ParseSnapshotAction(output, "clone")
+	if e != nil {
+		L.Gluster.Error(e.Error())
+		rsp.Fail(glusterError(output, e))
+		return
+	}
+	rsp.Snapshot = &clone
+	rsp.Result = "OK"
+}
+
+// snapshotConfig returns `snapshot config [<vol>]`.
+func snapshotConfig(ctx context.Context, volname string) (parser.SnapshotConfig, error) {
+	args := []string{"snapshot", "config"}
+	if volname != "" {
+		args = append(args, volname)
+	}
+	output, e := runGluster(ctx, append(args, "--xml")...)
+	if e != nil {
+		return parser.SnapshotConfig{}, glusterError(output, e)
+	}
+	config, e := parser.ParseSnapshotConfig(output)
+	if e != nil {
+		return config, glusterError(output, e)
+	}
+	return config, nil
+}
+
+/*
+[example]
+docker exec glusterfs gluster snapshot config test --xml
+
+curl -X POST http://127.0.0.1:7030/gluster/snapshot/config -H 'Content-Type: application/json' -d '{
+"volname": "test"
+}'
+<- {"result":"OK","config":{"snap_max_hard_limit":256,"snap_max_soft_limit":"90%","auto_delete":false,...}}
+*/
+func ProcessSnapshotConfig(w http.ResponseWriter, r *http.Request) {
+	var rsp SnapshotConfigResponse
+	defer writeResponse(w, &rsp)
+
+	// analyze request
+	var req SnapshotConfigRequest
+	if e := readRequest(r, &req); e != nil {
+		L.Gluster.Error(e.Error())
+		rsp.Fail(e)
+		return
+	}
+
+	config, e := snapshotConfig(r.Context(), req.Volname)
+	if e != nil {
+		L.Gluster.Error(e.Error())
+		rsp.Fail(e)
+		return
+	}
+	rsp.Config = &config
+	rsp.Result = "OK"
+}
+
+/*
+[example]
+docker exec glusterfs sh -c "gluster snapshot config test snap-max-hard-limit 64 <<< y"
+
+curl -X POST http://127.0.0.1:7030/gluster/snapshot/config/set -H 'Content-Type: application/json' -d '{
+"volname": "test",
+"snap_max_hard_limit": "64"
+}'
+<- {"result":"OK","config":{"snap_max_hard_limit":256,...,"volumes":[{"name":"test","snap_max_hard_limit":64,...}]}}
+*/
+func ProcessSnapshotConfigSet(w http.ResponseWriter, r *http.Request) {
+	var rsp SnapshotConfigResponse
+	defer writeResponse(w, &rsp)
+
+	// analyze request
+	var req SnapshotConfigRequest
+	if e := readRequest(r, &req); e != nil {
+		L.Gluster.Error(e.Error())
+		rsp.Fail(e)
+		return
+	}
+
+	lock := clusterLock
+	if req.Volname != "" {
+		lock = volumeLock(req.Volname)
+	}
+	release, e := lockForRequest(r, lock, "snapshot config")
+	if e != nil {
+		L.Gluster.Error(e.Error())
+		rsp.Fail(e)
+		return
+	}
+	defer release()
+
+	// the CLI takes one setting at a time
+	settings := [][2]string{
+		{"snap-max-hard-limit", req.HardLimit},
+		{"snap-max-soft-limit", req.SoftLimit},
+		{"auto-delete", req.AutoDelete},
+		{"activate-on-create", req.ActivateOnCreate},
+	}
+	for _, setting := range settings {
+		if setting[1] == "" {
+			continue
+		}
+		args := []string{"snapshot", "config"}
+		if req.Volname != "" {
+			args = append(args, req.Volname)
+		}
+		output, e := runGlusterConfirm(r.Context(), append(args, setting[0], setting[1])...)
+		if e != nil {
+			L.Gluster.Error(string(output))
+			rsp.Fail(glusterError(output, e))
+			return
+		}
+	}
+
+	config, e := snapshotConfig(r.Context(), req.Volname)
+	if e != nil {
+		L.Gluster.Error(e.Error())
+		rsp.Fail(e)
+		return
+	}
+	rsp.Config = &config
+	rsp.Result = "OK"
+}
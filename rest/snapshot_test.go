@@ -0,0 +1,169 @@
+package gluster
+
+import (
+	"net/http"
+	"testing"
+)
+
+const snapshotCreateXML = `<cliOutput><opRet>0</opRet><opErrno>0</opErrno><opErrstr/>` +
+	`<snapCreate><snapshot><name>snap1</name><uuid>3f1b6d2e-8c4a-4e9b-a1d7-5c2f0e9b8a47</uuid></snapshot></snapCreate></cliOutput>`
+
+// fakeSnapshotVolume answers the checks of a snapshot create on volume test,
+// a replica 2 volume with a brick on the local peer node1 and one on the peer
+// host, on LVs of lvAttr.
+func fakeSnapshotVolume(t *testing.T, host, lvAttr string) *FakeRunner {
+	return fakeGluster(t).
+		On(volumeInfoXML("test", "2", "node1:/data/brick1/test", host+":/data/brick2/test"), nil, "gluster", "volume", "info").
+		On("UUID: node1\n", nil, "gluster", "system::", "uuid", "get").
+		On("Filesystem\n/dev/mapper/vg_bricks-test\n", nil, "df").
+		On("Filesystem\n/dev/mapper/vg_bricks-test2\n", nil, "df", "--output=source", "/data/brick2/test").
+		On("  "+lvAttr+"\n", nil, "lvs").
+		On(snapshotCreateXML, nil, "gluster", "snapshot", "create").
+		On("", errExit, "gluster", "snapshot", "info")
+}
+
+func TestSnapshotCreate(t *testing.T) {
+	checks := []string{
+		"gluster volume info test --xml",
+		"gluster system:: uuid get",
+		"df --output=source /data/brick1/test",
+		"lvs --noheadings -o lv_attr /dev/mapper/vg_bricks-test",
+		"df --output=source /data/brick2/test", // by the peer service
+		"lvs --noheadings -o lv_attr /dev/mapper/vg_bricks-test2",
+	}
+	tests := []struct {
+		name   string
+		body   string
+		lvAttr string
+		status int
+		want   []string
+	}{
+		{
+			name:   "thin brick",
+			body:   `{"snapname": "snap1", "volname": "test"}`,
+			lvAttr: "Vwi-aotz--",
+			status: http.StatusOK,
+			want:   append(checks[:6:6], "gluster snapshot create snap1 test --xml", "gluster snapshot info snap1 --xml"),
+		},
+		{
+			name:   "description without timestamp",
+			body:   `{"snapname": "snap1", "volname": "test", "description": "before upgrade", "no_timestamp": "true", "force": "true"}`,
+			lvAttr: "Vwi-aotz--",
+			status: http.StatusOK,
+			want:   append(checks[:6:6], "gluster snapshot create snap1 test no-timestamp description before upgrade force --xml", "gluster snapshot info snap1 --xml"),
+		},
+		{
+			name:   "thick brick",
+			body:   `{"snapname": "snap1", "volname": "test"}`,
+			lvAttr: "-wi-ao----",
+			status: http.StatusConflict,
+			want:   checks[:4],
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			f := fakeSnapshotVolume(t, peerService(t), test.lvAttr)
+			var rsp SnapshotResponse
+			if status := serve(t, ProcessSnapshotCreate, test.body, &rsp); status != test.status {
+				t.Fatalf("status %d, want %d: %+v", status, test.status, rsp.Error)
+			}
+			if test.status == http.StatusOK && (rsp.Snapshot == nil || rsp.Snapshot.Name != "snap1") {
+				t.Errorf("snapshot = %+v", rsp.Snapshot)
+			}
+			checkCalls(t, f, test.want)
+			// the description is one argument
+			for _, c := range f.Calls {
+				for i, arg := range c.Args {
+					if arg == "description" && c.Args[i+1] != "before upgrade" {
+						t.Errorf("description argument %q", c.Args[i+1])
+					}
+				}
+			}
+		})
+	}
+}
+
+// TestSnapshotCreateRemoteBrick checks bricks of other peers are checked by
+// their peer, and a peer that cannot be asked fails the create.
+func TestSnapshotCreateRemoteBrick(t *testing.T) {
+	f := fakeSnapshotVolume(t, peerService(t), "Vwi-aotz--").
+		On("  -wi-ao----\n", nil, "lvs", "--noheadings", "-o", "lv_attr", "/dev/mapper/vg_bricks-test2")
+	var rsp SnapshotResponse
+	if status := serve(t, ProcessSnapshotCreate, `{"snapname": "snap1", "volname": "test"}`, &rsp); status != http.StatusConflict {
+		t.Errorf("thick remote brick: status %d, want 409", status)
+	}
+	if rsp.Error == nil || rsp.Error.Code != CodeNotThinProvisioned {
+		t.Errorf("thick remote brick: %+v", rsp.Error)
+	}
+	for _, call := range f.Invocations() {
+		if call == "gluster snapshot create snap1 test --xml" {
+			t.Error("created a snapshot of a thick remote brick")
+		}
+	}
+
+	previous := ServicePort
+	ServicePort = "1" // nothing listens
+	defer func() { ServicePort = previous }()
+	fakeSnapshotVolume(t, "127.0.0.1", "Vwi-aotz--")
+	rsp = SnapshotResponse{}
+	if status := serve(t, ProcessSnapshotCreate, `{"snapname": "snap1", "volname": "test"}`, &rsp); status != http.StatusServiceUnavailable {
+		t.Errorf("unreachable peer: status %d, want 503", status)
+	}
+	if rsp.Error == nil || rsp.Error.Code != CodePeerNotConnected {
+		t.Errorf("unreachable peer: %+v", rsp.Error)
+	}
+}
+
+func TestSnapshotCreateLimit(t *testing.T) {
+	f := fakeSnapshotVolume(t, peerService(t), "Vwi-aotz--")
+	f.On(`<cliOutput><opRet>-1</opRet><opErrno>30805</opErrno><opErrstr>The number of existing snaps has reached the effective maximum limit of 256</opErrstr></cliOutput>`,
+		errExit, "gluster", "snapshot", "create")
+	var rsp SnapshotResponse
+	if status := serve(t, ProcessSnapshotCreate, `{"snapname": "snap1", "volname": "test"}`, &rsp); status != http.StatusConflict {
+		t.Errorf("status %d, want 409", status)
+	}
+	if rsp.Error == nil || rsp.Error.Code != CodeSnapshotLimit || rsp.Error.OpErrno != 30805 {
+		t.Errorf("got %+v", rsp.Error)
+	}
+}
+
+func TestSnapshotAction(t *testing.T) {
+	tests := []struct {
+		name    string
+		handler http.HandlerFunc
+		body    string
+		want    string
+		confirm bool
+	}{
+		{"activate", ProcessSnapshotActivate, `{"snapname": "snap1"}`, "gluster snapshot activate snap1 --xml", false},
+		{"activate force", ProcessSnapshotActivate, `{"snapname": "snap1", "force": "true"}`, "gluster snapshot activate snap1 force --xml", false},
+		{"deactivate", ProcessSnapshotDeactivate, `{"snapname": "snap1"}`, "gluster snapshot deactivate snap1 --xml", true},
+		{"delete", ProcessSnapshotDelete, `{"snapname": "snap1"}`, "gluster snapshot delete snap1 --xml", true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			f := fakeGluster(t)
+			f.Default = FakeResponse{Output: `<cliOutput><opRet>0</opRet><opErrno>0</opErrno><opErrstr/></cliOutput>`}
+			var rsp SnapshotResponse
+			if status := serve(t, test.handler, test.body, &rsp); status != http.StatusOK {
+				t.Fatalf("status %d: %+v", status, rsp.Error)
+			}
+			checkCalls(t, f, []string{test.want})
+			if confirm := f.Calls[0].Stdin == "y\n"; confirm != test.confirm {
+				t.Errorf("confirm %v, want %v", confirm, test.confirm)
+			}
+		})
+	}
+}
+
+func TestSnapshotNotFound(t *testing.T) {
+	fakeGluster(t).On(`<cliOutput><opRet>-1</opRet><opErrno>30807</opErrno><opErrstr>Snapshot (snap1) does not exist</opErrstr></cliOutput>`,
+		errExit, "gluster", "snapshot", "activate")
+	var rsp SnapshotResponse
+	if status := serve(t, ProcessSnapshotActivate, `{"snapname": "snap1"}`, &rsp); status != http.StatusNotFound {
+		t.Errorf("status %d, want 404", status)
+	}
+	if rsp.Error == nil || rsp.Error.Code != CodeSnapshotNotFound {
+		t.Errorf("got %+v", rsp.Error)
+	}
+}
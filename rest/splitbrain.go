@@ -0,0 +1,300 @@
+package gluster
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"hualu.com/gluster-rest/parser"
+	L "hualu.com/logger"
+)
+
+// split-brain resolution policies of `volume heal <vol> split-brain`
+const (
+	PolicyBiggerFile  = "bigger-file"
+	PolicyLatestMtime = "latest-mtime"
+	PolicySourceBrick = "source-brick"
+)
+
+// SplitBrainResolveRequest resolves File, or with the source-brick policy and
+// no File every file in split-brain on SourceBrick.
+type SplitBrainResolveRequest struct {
+	CommonVolumeRequest
+	Policy      string `json:"policy"`       // bigger-file, latest-mtime, source-brick
+	SourceBrick string `json:"source_brick"` // host:/path, source-brick only
+	File        string `json:"file"`         // path from the volume root, or gfid:<gfid>
+	DryRun      string `json:"dry_run"`      // only list the files that would be resolved
+}
+
+type SplitBrainResolveResponse struct {
+	CommonResponse
+	Files  []parser.HealEntry `json:"files"` // resolved, or to be resolved with dry_run
+	Output string             `json:"output,omitempty"`
+}
+
+// SplitBrainAudit records a resolution: who chose which policy for which files.
+type SplitBrainAudit struct {
+	Time        time.Time `json:"time"`
+	User        string    `json:"user"` // X-Remote-User set by the authenticating proxy
+	RemoteAddr  string    `json:"remote_addr"`
+	Volname     string    `json:"volname"`
+	Policy      string    `json:"policy"`
+	SourceBrick string    `json:"source_brick,omitempty"`
+	File        string    `json:"file,omitempty"`
+	Files       int       `json:"files"` // in split-brain when resolving
+	Result      string    `json:"result"`
+	Error       string    `json:"error,omitempty"`
+}
+
+// SplitBrainAuditFile holds the resolutions of the cluster, one json entry per
+// line. Like ScheduleFile it must be on shared storage for every node to
+// append to and read the same trail.
+var SplitBrainAuditFile = "/var/run/gluster/shared_storage/gluster-rest/split_brain_audit.log"
+
+// auditMaxSize rotates the audit file to SplitBrainAuditFile.1 once exceeded,
+// the previous rotation is dropped.
+var auditMaxSize int64 = 4 << 20
+
+// SplitBrainAuditLog appends to SplitBrainAuditFile under an flock.
+type SplitBrainAuditLog struct {
+	mu sync.Mutex
+}
+
+var splitBrainAudit = &SplitBrainAuditLog{}
+
+func (l *SplitBrainAuditLog) Add(entry SplitBrainAudit) error {
+	L.Gluster.Infof("split-brain %s on %s by %q from %s: %s %s%s",
+		entry.Result, entry.Volname, entry.User, entry.RemoteAddr, entry.Policy, entry.SourceBrick, entry.File)
+	line, e := json.Marshal(entry)
+	if e != nil {
+		return e
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	f, e := lockStore("audit", SplitBrainAuditFile, CodeAuditStore)
+	if e != nil {
+		return e
+	}
+	defer f.Close() // releases the flock
+
+	if info, e := os.Stat(SplitBrainAuditFile); e == nil && info.Size()+int64(len(line)) >= auditMaxSize {
+		if e := os.Rename(SplitBrainAuditFile, SplitBrainAuditFile+".1"); e != nil {
+			return NewError(http.StatusServiceUnavailable, CodeAuditStore, "audit store: %s", e)
+		}
+	}
+	out, e := os.OpenFile(SplitBrainAuditFile, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if e != nil {
+		return NewError(http.StatusServiceUnavailable, CodeAuditStore, "audit store: %s", e)
+	}
+	defer out.Close()
+	if _, e := out.Write(append(line, '\n')); e != nil {
+		return NewError(http.StatusServiceUnavailable, CodeAuditStore, "audit store: %s", e)
+	}
+	return out.Sync()
+}
+
+// List returns the entries of volname (empty matches all), newest first, from
+// the audit file and its rotation. Entries are appended whole under the lock,
+// so it is read without it.
+func (l *SplitBrainAuditLog) List(volname string) ([]SplitBrainAudit, error) {
+	var entries []SplitBrainAudit
+	for _, file := range []string{SplitBrainAuditFile + ".1", SplitBrainAuditFile} {
+		buf, e := ioutil.ReadFile(file)
+		if os.IsNotExist(e) {
+			continue
+		}
+		if e != nil {
+			return nil, NewError(http.StatusServiceUnavailable, CodeAuditStore, "audit store: %s", e)
+		}
+		for _, line := range strings.Split(string(buf), "\n") {
+			var entry SplitBrainAudit
+			if strings.TrimSpace(line) == "" || json.Unmarshal([]byte(line), &entry) != nil {
+				continue // a line cut by a crash
+			}
+			if volname == "" || entry.Volname == volname {
+				entries = append(entries, entry)
+			}
+		}
+	}
+	list := make([]SplitBrainAudit, 0, len(entries))
+	for i := len(entries) - 1; i >= 0; i-- {
+		list = append(list, entries[i])
+	}
+	return list, nil
+}
+
+// addAudit records entry, a resolution already done is not undone when the
+// trail cannot be written.
+func addAudit(entry SplitBrainAudit) {
+	if e := splitBrainAudit.Add(entry); e != nil {
+		L.Gluster.Error("split-brain audit: " + e.Error())
+	}
+}
+
+// splitBrainFiles returns the files `volume heal <vol> split-brain` would act
+// on: file when it is in split-brain, or every file in split-brain on the
+// source brick. Bricks list the entries they blame, so a file is reported once
+// by gfid.
+func splitBrainFiles(ctx context.Context, req SplitBrainResolveRequest) ([]parser.HealEntry, error) {
+	bricks, e := healInfo(ctx, req.Volname, "split-brain")
+	if e != nil {
+		return nil, e
+	}
+
+	if req.SourceBrick != "" {
+		found := false
+		for _, brick := range bricks {
+			if brick.Name == req.SourceBrick {
+				found = true
+				if !brick.Connected {
+					return nil, NewError(http.StatusServiceUnavailable, CodeBrickDown, "source brick %s is %s", brick.Name, brick.Status)
+				}
+			}
+		}
+		if !found {
+			return nil, NewError(http.StatusNotFound, CodeNotFound, "brick %s is not part of volume %s", req.SourceBrick, req.Volname)
+		}
+	}
+
+	files := []parser.HealEntry{}
+	seen := make(map[string]bool)
+	for _, brick := range bricks {
+		if req.File == "" && brick.Name != req.SourceBrick {
+			continue
+		}
+		for _, entry := range brick.Files {
+			if req.File != "" && req.File != entry.Path && req.File != "gfid:"+entry.GFID {
+				continue
+			}
+			if !seen[entry.GFID] {
+				seen[entry.GFID] = true
+				files = append(files, entry)
+			}
+		}
+	}
+	return files, nil
+}
+
+/*
+[example]
+docker exec glusterfs gluster volume heal test split-brain source-brick node1:/data/brick1/test /dir/a.txt
+
+curl -X POST http://127.0.0.1:7030/gluster/volume/heal/split-brain/resolve -H 'X-Remote-User: alice' -H 'Content-Type: application/json' -d '{
+"volname": "test",
+"policy": "source-brick",
+"source_brick": "node1:/data/brick1/test",
+"file": "/dir/a.txt",
+"dry_run": "true"
+}'
+<- {"result":"OK","files":[{"gfid":"8f0b1c3e-...","path":"/dir/a.txt"}]}
+*/
+func ProcessVolumeSplitBrainResolve(w http.ResponseWriter, r *http.Request) {
+	var rsp SplitBrainResolveResponse
+	defer writeResponse(w, &rsp)
+
+	// analyze request
+	var req SplitBrainResolveRequest
+	if e := readRequest(r, &req); e != nil {
+		L.Gluster.Error(e.Error())
+		rsp.Fail(e)
+		return
+	}
+
+	// the service trusts the proxy in front of it to authenticate the user, a
+	// resolution nobody can be held to is refused
+	user := r.Header.Get("X-Remote-User")
+	if user == "" && req.DryRun != "true" {
+		e := invalid("X-Remote-User", user, "cannot be empty, the audit records who resolved the split-brain")
+		L.Gluster.Error(e.Error())
+		rsp.Fail(e)
+		return
+	}
+
+	files, e := splitBrainFiles(r.Context(), req)
+	if e != nil {
+		L.Gluster.Error(e.Error())
+		rsp.Fail(e)
+		return
+	}
+	if req.DryRun == "true" {
+		rsp.Files = files
+		rsp.Result = "OK"
+		return
+	}
+	if req.File != "" && len(files) == 0 {
+		rsp.Fail(NewError(http.StatusConflict, CodeNotInSplitBrain, "%s is not in split-brain", req.File))
+		return
+	}
+
+	release, e := lockForRequest(r, volumeLock(req.Volname), "split-brain "+req.Policy)
+	if e != nil {
+		L.Gluster.Error(e.Error())
+		rsp.Fail(e)
+		return
+	}
+	defer release()
+
+	args := []string{"volume", "heal", req.Volname, "split-brain", req.Policy}
+	if req.SourceBrick != "" {
+		args = append(args, req.SourceBrick)
+	}
+	if req.File != "" {
+		args = append(args, req.File)
+	}
+	output, e := runGluster(r.Context(), args...)
+
+	audit := SplitBrainAudit{
+		Time:        time.Now(),
+		User:        user,
+		RemoteAddr:  r.RemoteAddr,
+		Volname:     req.Volname,
+		Policy:      req.Policy,
+		SourceBrick: req.SourceBrick,
+		File:        req.File,
+		Files:       len(files),
+		Result:      "OK",
+	}
+	if e != nil {
+		err := glusterError(output, e)
+		audit.Result, audit.Error = "ERROR", err.Message
+		addAudit(audit)
+		L.Gluster.Error(string(output))
+		rsp.Fail(err)
+		return
+	}
+	addAudit(audit)
+
+	rsp.Files = files
+	rsp.Output = strings.TrimSpace(string(output))
+	rsp.Result = "OK"
+}
+
+type SplitBrainAuditResponse struct {
+	CommonResponse
+	Audit []SplitBrainAudit `json:"audit"`
+}
+
+/*
+[example]
+curl -X GET 'http://127.0.0.1:7030/gluster/volume/heal/split-brain/audit?volname=test'
+<- {"result":"OK","audit":[{"time":"...","user":"alice","remote_addr":"10.0.0.5:51234","volname":"test","policy":"source-brick",...,"result":"OK"}]}
+*/
+func ProcessVolumeSplitBrainAudit(w http.ResponseWriter, r *http.Request) {
+	var rsp SplitBrainAuditResponse
+	defer writeResponse(w, &rsp)
+
+	audit, e := splitBrainAudit.List(r.URL.Query().Get("volname"))
+	if e != nil {
+		L.Gluster.Error(e.Error())
+		rsp.Fail(e)
+		return
+	}
+	rsp.Audit = audit
+	rsp.Result = "OK"
+}
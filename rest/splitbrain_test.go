@@ -0,0 +1,141 @@
+package gluster
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// splitBrainXML is `volume heal test info split-brain --xml`: both copies of
+// a.txt blame each other, b.txt is blamed by node2 only, node3 is down.
+const splitBrainXML = `<cliOutput><healInfo><bricks>` +
+	`<brick hostUuid="node1"><name>node1:/data/brick1/test</name><status>Connected</status>` +
+	`<file gfid="8f0b1c3e-2d4a-4b6c-9e8f-7a6b5c4d3e2f">/dir/a.txt</file><numberOfEntries>1</numberOfEntries></brick>` +
+	`<brick hostUuid="node2"><name>node2:/data/brick1/test</name><status>Connected</status>` +
+	`<file gfid="8f0b1c3e-2d4a-4b6c-9e8f-7a6b5c4d3e2f">/dir/a.txt</file>` +
+	`<file gfid="c4d3e2f1-a0b9-4c8d-8e7f-6a5b4c3d2e1f">/dir/b.txt</file><numberOfEntries>2</numberOfEntries></brick>` +
+	`<brick hostUuid="-"><name>node3:/data/brick1/test</name><status>Transport endpoint is not connected</status><numberOfEntries>-</numberOfEntries></brick>` +
+	`</bricks></healInfo><opRet>0</opRet><opErrno>0</opErrno><opErrstr/></cliOutput>`
+
+// auditStore points SplitBrainAuditFile at an empty trail for the test.
+func auditStore(t *testing.T) {
+	previous := SplitBrainAuditFile
+	SplitBrainAuditFile = filepath.Join(t.TempDir(), "gluster-rest", "split_brain_audit.log")
+	t.Cleanup(func() { SplitBrainAuditFile = previous })
+}
+
+func TestSplitBrainFiles(t *testing.T) {
+	fakeGluster(t).On(splitBrainXML, nil, "gluster", "volume", "heal", "test", "info", "split-brain")
+	tests := []struct {
+		name        string
+		sourceBrick string
+		file        string
+		want        []string
+		code        string
+	}{
+		{"file by path", "", "/dir/a.txt", []string{"/dir/a.txt"}, ""}, // listed by two bricks, reported once
+		{"file by gfid", "", "gfid:c4d3e2f1-a0b9-4c8d-8e7f-6a5b4c3d2e1f", []string{"/dir/b.txt"}, ""},
+		{"file not in split-brain", "", "/dir/c.txt", nil, ""},
+		{"source brick", "node2:/data/brick1/test", "", []string{"/dir/a.txt", "/dir/b.txt"}, ""},
+		{"file on source brick", "node1:/data/brick1/test", "/dir/a.txt", []string{"/dir/a.txt"}, ""},
+		{"source brick down", "node3:/data/brick1/test", "", nil, CodeBrickDown},
+		{"unknown source brick", "node4:/data/brick1/test", "", nil, CodeNotFound},
+	}
+	for _, test := range tests {
+		req := SplitBrainResolveRequest{SourceBrick: test.sourceBrick, File: test.file}
+		req.Volname = "test"
+		files, e := splitBrainFiles(context.Background(), req)
+		if test.code != "" {
+			if err, ok := e.(*Error); !ok || err.Code != test.code {
+				t.Errorf("%s: %v, want %s", test.name, e, test.code)
+			}
+			continue
+		}
+		if e != nil {
+			t.Errorf("%s: %v", test.name, e)
+			continue
+		}
+		var paths []string
+		for _, f := range files {
+			paths = append(paths, f.Path)
+		}
+		if strings.Join(paths, ",") != strings.Join(test.want, ",") {
+			t.Errorf("%s: %v, want %v", test.name, paths, test.want)
+		}
+	}
+}
+
+func TestSplitBrainResolveUser(t *testing.T) {
+	body := `{"volname": "test", "policy": "bigger-file", "file": "/dir/a.txt"}`
+	resolve := func(user, body string) (int, SplitBrainResolveResponse) {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body))
+		if user != "" {
+			r.Header.Set("X-Remote-User", user)
+		}
+		ProcessVolumeSplitBrainResolve(w, r)
+		var rsp SplitBrainResolveResponse
+		decode(t, w, &rsp)
+		return w.Code, rsp
+	}
+
+	auditStore(t)
+	f := fakeGluster(t).On(splitBrainXML, nil, "gluster", "volume", "heal", "test", "info", "split-brain")
+	status, rsp := resolve("", body)
+	if status != http.StatusBadRequest || rsp.Error == nil || rsp.Error.Field != "X-Remote-User" {
+		t.Errorf("anonymous: status %d, %+v", status, rsp.Error)
+	}
+	checkCalls(t, f, nil)
+
+	if status, rsp := resolve("", `{"volname": "test", "policy": "bigger-file", "dry_run": "true", "file": "/dir/a.txt"}`); status != http.StatusOK || len(rsp.Files) != 1 {
+		t.Errorf("anonymous dry run: status %d, %+v", status, rsp)
+	}
+
+	if status, rsp := resolve("alice", body); status != http.StatusOK {
+		t.Fatalf("alice: status %d, %+v", status, rsp.Error)
+	}
+	audit, e := splitBrainAudit.List("test")
+	if e != nil {
+		t.Fatal(e)
+	}
+	if len(audit) != 1 || audit[0].User != "alice" || audit[0].Policy != "bigger-file" || audit[0].Files != 1 || audit[0].Result != "OK" {
+		t.Errorf("audit = %+v", audit)
+	}
+}
+
+func TestSplitBrainAudit(t *testing.T) {
+	auditStore(t)
+	previous := auditMaxSize
+	auditMaxSize = 300 // two entries a file
+	defer func() { auditMaxSize = previous }()
+
+	for _, entry := range []SplitBrainAudit{
+		{Volname: "a", File: "/1"}, {Volname: "b", File: "/2"}, {Volname: "a", File: "/3"},
+		{Volname: "a", File: "/4"}, {Volname: "a", File: "/5"},
+	} {
+		entry.User, entry.Policy, entry.Result = "alice", PolicyBiggerFile, "OK"
+		if e := splitBrainAudit.Add(entry); e != nil {
+			t.Fatal(e)
+		}
+	}
+	if _, e := os.Stat(SplitBrainAuditFile + ".1"); e != nil {
+		t.Fatalf("not rotated: %v", e)
+	}
+
+	// the oldest rotation is dropped, the rest is listed newest first
+	list, e := splitBrainAudit.List("a")
+	if e != nil {
+		t.Fatal(e)
+	}
+	var files []string
+	for _, entry := range list {
+		files = append(files, entry.File)
+	}
+	if strings.Join(files, ",") != "/5,/4,/3" {
+		t.Errorf("files %v, want /5,/4,/3", files)
+	}
+}
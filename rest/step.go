@@ -0,0 +1,99 @@
+package gluster
+
+import (
+	"time"
+
+	L "hualu.com/logger"
+)
+
+type StepState string
+
+const (
+	StepPending StepState = "pending"
+	StepRunning StepState = "running"
+	StepDone    StepState = "done"
+	StepFailed  StepState = "failed"
+	StepSkipped StepState = "skipped"
+)
+
+// JobStep is one operation of a job chaining several, reported in its progress
+// so a UI can show where the job is and where it stopped.
+type JobStep struct {
+	Name      string     `json:"name"`
+	State     StepState  `json:"state"`
+	Message   string     `json:"message,omitempty"`
+	StartTime *time.Time `json:"start_time,omitempty"`
+	EndTime   *time.Time `json:"end_time,omitempty"`
+}
+
+// jobSteps tracks the steps of a job and publishes them with its progress.
+type jobSteps struct {
+	update   func(JobProgress)
+	progress JobProgress
+	steps    []JobStep
+}
+
+func newJobSteps(update func(JobProgress), names ...string) *jobSteps {
+	s := &jobSteps{update: update}
+	for _, name := range names {
+		s.steps = append(s.steps, JobStep{Name: name, State: StepPending})
+	}
+	s.publish()
+	return s
+}
+
+func (s *jobSteps) publish() {
+	p := s.progress
+	p.Steps = append([]JobStep(nil), s.steps...) // the job manager reads it concurrently
+	s.update(p)
+}
+
+func (s *jobSteps) step(name string) *JobStep {
+	for i := range s.steps {
+		if s.steps[i].Name == name {
+			return &s.steps[i]
+		}
+	}
+	s.steps = append(s.steps, JobStep{Name: name, State: StepPending})
+	return &s.steps[len(s.steps)-1]
+}
+
+// Run runs step name. fn returns a message describing its outcome, the error
+// fails the step and is returned.
+func (s *jobSteps) Run(name string, fn func() (string, error)) error {
+	now := time.Now()
+	step := s.step(name)
+	step.State, step.StartTime = StepRunning, &now
+	s.progress.Status = name
+	s.publish()
+
+	message, e := fn()
+
+	end := time.Now()
+	step = s.step(name)
+	step.EndTime, step.Message = &end, message
+	if e != nil {
+		step.State = StepFailed
+		if step.Message == "" {
+			step.Message = toError(e).Message
+		}
+		L.Gluster.Error(name + ": " + e.Error())
+	} else {
+		step.State = StepDone
+	}
+	s.publish()
+	return e
+}
+
+// Skip marks step name as not needed.
+func (s *jobSteps) Skip(name, message string) {
+	step := s.step(name)
+	step.State, step.Message = StepSkipped, message
+	s.publish()
+}
+
+// Progress reports the counters of the running step, e.g. pending heal entries.
+func (s *jobSteps) Progress(p JobProgress) {
+	s.progress = p
+	s.publish()
+}
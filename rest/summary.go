@@ -0,0 +1,161 @@
+package gluster
+
+import (
+	"context"
+	"net/http"
+	"sort"
+	"strings"
+
+	"hualu.com/gluster-rest/parser"
+	L "hualu.com/logger"
+)
+
+// VolumeSummary is one volume of GET /gluster/volumes.
+type VolumeSummary struct {
+	Name            string `json:"name"`
+	ID              string `json:"id"`
+	Type            string `json:"type"`
+	Status          string `json:"status"`
+	Transport       string `json:"transport"`
+	BrickCount      int    `json:"brick_count"`
+	OnlineBricks    int    `json:"online_bricks"`
+	ReplicaCount    int    `json:"replica_count"`
+	ArbiterCount    int    `json:"arbiter_count"`
+	DisperseCount   int    `json:"disperse_count"`
+	RedundancyCount int    `json:"redundancy_count"`
+	SizeTotal       uint64 `json:"size_total"` // usable bytes, replicas and redundancy excluded
+	SizeFree        uint64 `json:"size_free"`
+	SizeUsed        uint64 `json:"size_used"`
+}
+
+type VolumeListResponse struct {
+	CommonVolumeResponse
+	Volumes []VolumeSummary `json:"volumes"`
+}
+
+// volumesInfo returns `volume info <volname> --xml`.
+func volumesInfo(ctx context.Context, volname string) ([]parser.Volume, error) {
+	output, e := runGluster(ctx, "volume", "info", volname, "--xml")
+	if e != nil {
+		return nil, glusterError(output, e)
+	}
+	return parser.ParseVolumeInfo(output)
+}
+
+// bricksStatus returns `volume status <volname> detail --xml` by brick name.
+// Stopped volumes are not reported by glusterd and are missing from the map.
+func bricksStatus(ctx context.Context, volname string) (map[string]parser.BrickStatus, error) {
+	output, e := runGluster(ctx, "volume", "status", volname, "detail", "--xml")
+	if e != nil {
+		return nil, glusterError(output, e)
+	}
+	return parser.ParseVolumeStatus(output)
+}
+
+// volumesHealth fills the subvolume health of the started volumes. Failing to
+// get the status leaves it out, the volume info is still worth returning.
+func volumesHealth(ctx context.Context, volname string, volumes []parser.Volume) {
+	bricks, e := bricksStatus(ctx, volname)
+	if e != nil {
+		L.Gluster.Error(e.Error())
+		return
+	}
+	for i := range volumes {
+		if volumes[i].Status == parser.VolumeStarted {
+			volumes[i].ApplyStatus(bricks)
+		}
+	}
+}
+
+// summarizeVolume joins the info of a volume with the status of its bricks.
+func summarizeVolume(volume parser.Volume, bricks map[string]parser.BrickStatus) VolumeSummary {
+	summary := VolumeSummary{
+		Name:            volume.Name,
+		ID:              volume.ID,
+		Type:            string(volume.Type),
+		Status:          string(volume.Status),
+		Transport:       volume.Transport,
+		BrickCount:      len(volume.Bricks),
+		ReplicaCount:    volume.ReplicaCount,
+		ArbiterCount:    volume.ArbiterCount,
+		DisperseCount:   volume.DisperseCount,
+		RedundancyCount: volume.RedundancyCount,
+	}
+	for _, brick := range volume.Bricks {
+		if bricks[brick.Name].Online {
+			summary.OnlineBricks++
+		}
+	}
+
+	// a subvolume stores one copy of its data: a replica set holds what its
+	// smallest data brick holds, a disperse set that times its data bricks
+	factor := uint64(1)
+	if volume.DisperseCount > 0 {
+		factor = uint64(volume.DisperseCount - volume.RedundancyCount)
+	}
+	for _, subvolume := range volume.Subvolumes {
+		var total, free uint64
+		found := false
+		for _, brick := range subvolume.DataBricks() {
+			status, ok := bricks[brick.Name]
+			if !ok || status.SizeTotal == 0 {
+				continue
+			}
+			if !found || status.SizeTotal < total {
+				total = status.SizeTotal
+			}
+			if !found || status.SizeFree < free {
+				free = status.SizeFree
+			}
+			found = true
+		}
+		summary.SizeTotal += total * factor
+		summary.SizeFree += free * factor
+	}
+	if summary.SizeFree < summary.SizeTotal {
+		summary.SizeUsed = summary.SizeTotal - summary.SizeFree
+	}
+	return summary
+}
+
+/*
+[example]
+docker exec glusterfs gluster volume info all --xml
+docker exec glusterfs gluster volume status all detail --xml
+
+curl -X GET 'http://127.0.0.1:7030/gluster/volumes?status=started&type=replicate'
+<- {"result":"OK","volumes":[{"name":"test","id":"...","type":"Replicate","status":"Started","brick_count":3,"online_bricks":3,...}]}
+*/
+func ProcessVolumeList(w http.ResponseWriter, r *http.Request) {
+	var rsp VolumeListResponse
+	defer writeResponse(w, &rsp)
+
+	status := r.URL.Query().Get("status")
+	volumeType := r.URL.Query().Get("type")
+
+	volumes, e := volumesInfo(r.Context(), "all")
+	if e != nil {
+		L.Gluster.Error(e.Error())
+		rsp.Fail(e)
+		return
+	}
+
+	// status fails when no volume is started, bricks are then reported offline
+	bricks, e := bricksStatus(r.Context(), "all")
+	if e != nil {
+		L.Gluster.Error(e.Error())
+	}
+
+	rsp.Volumes = []VolumeSummary{}
+	for _, volume := range volumes {
+		if status != "" && !strings.EqualFold(string(volume.Status), status) {
+			continue
+		}
+		if volumeType != "" && !strings.EqualFold(string(volume.Type), volumeType) {
+			continue
+		}
+		rsp.Volumes = append(rsp.Volumes, summarizeVolume(volume, bricks))
+	}
+	sort.Slice(rsp.Volumes, func(i, j int) bool { return rsp.Volumes[i].Name < rsp.Volumes[j].Name })
+	rsp.Result = "OK"
+}
@@ -0,0 +1,726 @@
+package gluster
+
+import (
+	"fmt"
+	"net"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"hualu.com/gluster-rest/parser"
+)
+
+// ValidationError describes a request field rejected before any command runs.
+type ValidationError struct {
+	Field  string
+	Value  string
+	Reason string
+}
+
+func (e *ValidationError) Error() string {
+	return fmt.Sprintf("invalid %s %q: %s", e.Field, e.Value, e.Reason)
+}
+
+func invalid(field, value, reason string) *ValidationError {
+	return &ValidationError{Field: field, Value: value, Reason: reason}
+}
+
+// glusterd limits, see cli_validate_volname() in cli-cmd-parser.c
+const volnameMax = 999
+
+// GLUSTERD_MAX_SNAP_NAME
+const snapnameMax = 255
+
+var (
+	volnameChars  = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+	snapnameChars = regexp.MustCompile(`^[A-Za-z0-9_.-]+$`) // '.' for the _GMT-2006.01.02-15.04.05 suffix
+	hostnameLabel = regexp.MustCompile(`^[A-Za-z0-9]([A-Za-z0-9-]{0,61}[A-Za-z0-9])?$`)
+	pathChars     = regexp.MustCompile(`^[A-Za-z0-9._/+@=-]+$`)
+	optionKey     = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]*$`)
+	gfidFile      = regexp.MustCompile(`^gfid:[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+	quotaSize     = regexp.MustCompile(`^(?i)[0-9]+(\.[0-9]+)?(B|KB|MB|GB|TB|PB)?$`) // gf_string2bytesize
+	quotaTime     = regexp.MustCompile(`^[0-9]+(s|sec|m|min|h|hr|d|days|w|wk)?$`)    // gf_string2time
+
+	volnameReserved = map[string]bool{
+		"volume": true, "type": true, "subvolumes": true, "option": true,
+		"end-volume": true, "all": true, "volume_not_in_ring": true,
+		"description": true, "force": true, "snap-max-hard-limit": true,
+		"snap-max-soft-limit": true, "auto-delete": true, "activate-on-create": true,
+	}
+
+	// directories that must never be used as a mount point, they get removed on unmount
+	mountReserved = map[string]bool{
+		"/": true, "/bin": true, "/boot": true, "/dev": true, "/etc": true,
+		"/home": true, "/lib": true, "/lib64": true, "/opt": true, "/proc": true,
+		"/root": true, "/run": true, "/sbin": true, "/sys": true, "/tmp": true,
+		"/usr": true, "/var": true,
+	}
+)
+
+func ValidateVolname(field, volname string) *ValidationError {
+	switch {
+	case volname == "":
+		return invalid(field, volname, "cannot be empty")
+	case len(volname) > volnameMax:
+		return invalid(field, volname, fmt.Sprintf("exceeds %d characters", volnameMax))
+	case volname[0] == '-':
+		return invalid(field, volname, "cannot start with '-'")
+	case !volnameChars.MatchString(volname):
+		return invalid(field, volname, "only alphanumeric, '-' and '_' are allowed")
+	case volnameReserved[volname]:
+		return invalid(field, volname, "is a reserved word")
+	}
+	return nil
+}
+
+func ValidateSnapname(field, snapname string) *ValidationError {
+	switch {
+	case snapname == "":
+		return invalid(field, snapname, "cannot be empty")
+	case len(snapname) > snapnameMax:
+		return invalid(field, snapname, fmt.Sprintf("exceeds %d characters", snapnameMax))
+	case snapname[0] == '-':
+		return invalid(field, snapname, "cannot start with '-'")
+	case !snapnameChars.MatchString(snapname):
+		return invalid(field, snapname, "only alphanumeric, '.', '-' and '_' are allowed")
+	case volnameReserved[snapname]:
+		return invalid(field, snapname, "is a reserved word")
+	}
+	return nil
+}
+
+// ValidateHost accepts an IPv4/IPv6 address or an RFC 1123 hostname.
+func ValidateHost(field, host string) *ValidationError {
+	if host == "" {
+		return invalid(field, host, "cannot be empty")
+	}
+	if net.ParseIP(host) != nil {
+		return nil
+	}
+	if len(host) > 253 {
+		return invalid(field, host, "exceeds 253 characters")
+	}
+	for _, label := range strings.Split(host, ".") {
+		if !hostnameLabel.MatchString(label) {
+			return invalid(field, host, "is neither an IP address nor a valid hostname")
+		}
+	}
+	return nil
+}
+
+// ValidatePath accepts a clean absolute path made of safe characters.
+func ValidatePath(field, p string) *ValidationError {
+	switch {
+	case p == "":
+		return invalid(field, p, "cannot be empty")
+	case !strings.HasPrefix(p, "/"):
+		return invalid(field, p, "must be an absolute path")
+	case !pathChars.MatchString(p):
+		return invalid(field, p, "contains characters outside [A-Za-z0-9._/+@=-]")
+	case path.Clean(p) != p:
+		return invalid(field, p, "must be a clean path without '..', '//' or trailing '/'")
+	case p == "/":
+		return invalid(field, p, "cannot be the root directory")
+	}
+	return nil
+}
+
+// ValidateDevice checks a block device path such as /dev/sdc or
+// /dev/mapper/vg-lv.
+func ValidateDevice(field, device string) *ValidationError {
+	if e := ValidatePath(field, device); e != nil {
+		return e
+	}
+	if !strings.HasPrefix(device, "/dev/") {
+		return invalid(field, device, "must be a device under /dev")
+	}
+	return nil
+}
+
+// ValidateBrick accepts a "host:/path" brick spec.
+func ValidateBrick(field, brick string) *ValidationError {
+	i := strings.LastIndex(brick, ":")
+	if i <= 0 {
+		return invalid(field, brick, "must be in host:/path form")
+	}
+	if e := ValidateHost(field, brick[:i]); e != nil {
+		return invalid(field, brick, "host "+e.Reason)
+	}
+	if e := ValidatePath(field, brick[i+1:]); e != nil {
+		return invalid(field, brick, "path "+e.Reason)
+	}
+	return nil
+}
+
+func ValidateBricks(field string, bricks []string) *ValidationError {
+	if len(bricks) == 0 {
+		return invalid(field, "", "at least one brick is required")
+	}
+	seen := make(map[string]bool)
+	for i, brick := range bricks {
+		name := fmt.Sprintf("%s[%d]", field, i)
+		if e := ValidateBrick(name, brick); e != nil {
+			return e
+		}
+		if seen[brick] {
+			return invalid(name, brick, "is listed more than once")
+		}
+		seen[brick] = true
+	}
+	return nil
+}
+
+func ValidateTransport(field, transport string) *ValidationError {
+	switch transport {
+	case "", "tcp", "rdma", "tcp,rdma":
+		return nil
+	}
+	return invalid(field, transport, "must be one of tcp, rdma, tcp,rdma")
+}
+
+func ValidateMountPath(field, mount string) *ValidationError {
+	if e := ValidatePath(field, mount); e != nil {
+		return e
+	}
+	if mountReserved[mount] {
+		return invalid(field, mount, "is a system directory")
+	}
+	return nil
+}
+
+// ValidateOptionKey accepts a volume option name such as performance.cache-size.
+func ValidateOptionKey(field, key string) *ValidationError {
+	switch {
+	case key == "":
+		return invalid(field, key, "cannot be empty")
+	case !optionKey.MatchString(key):
+		return invalid(field, key, "only alphanumeric, '.', '-' and '_' are allowed")
+	}
+	return nil
+}
+
+func validateCount(field, count string, min int) *ValidationError {
+	n, e := strconv.Atoi(count)
+	if e != nil || n < min {
+		return invalid(field, count, fmt.Sprintf("must be an integer >= %d", min))
+	}
+	return nil
+}
+
+func validateOneOf(field, value string, allowed ...string) *ValidationError {
+	for _, a := range allowed {
+		if value == a {
+			return nil
+		}
+	}
+	return invalid(field, value, "must be one of "+strings.Join(allowed, ", "))
+}
+
+func (req CommonPeerRequest) Validate() *ValidationError {
+	return ValidateHost("hostname", req.Hostname)
+}
+
+func (req CommonVolumeRequest) Validate() *ValidationError {
+	return ValidateVolname("volname", req.Volname)
+}
+
+func (req VolumeQueryRequest) Validate() *ValidationError {
+	if req.Volname == "" {
+		return nil
+	}
+	return req.CommonVolumeRequest.Validate()
+}
+
+func (req VolumeCreateRequest) Validate() *ValidationError {
+	if e := req.CommonVolumeRequest.Validate(); e != nil {
+		return e
+	}
+	layout, e := req.Layout()
+	if e != nil {
+		return e
+	}
+	if e := ValidateTransport("transport", req.Transport); e != nil {
+		return e
+	}
+	if e := validateOneOf("force", req.Force, "", "true", "false"); e != nil {
+		return e
+	}
+	if e := ValidateBricks("bricks", req.Bricks); e != nil {
+		return e
+	}
+	if e := layout.CheckBricks("bricks", req.Bricks, req.Force == "true"); e != nil {
+		return e
+	}
+	for i, option := range req.Options {
+		if e := ValidateOptionKey(fmt.Sprintf("options[%d].name", i), option.Name); e != nil {
+			return e
+		}
+	}
+	if e := validateOneOf("start", req.Start, "", "true", "false"); e != nil {
+		return e
+	}
+	return validateOneOf("atomic", req.Atomic, "", "true", "false")
+}
+
+func (req VolumePlanRequest) Validate() *ValidationError {
+	if e := req.CommonVolumeRequest.Validate(); e != nil {
+		return e
+	}
+	if _, e := req.Layout(); e != nil {
+		return e
+	}
+	if e := ValidateTransport("transport", req.Transport); e != nil {
+		return e
+	}
+	if len(req.Bricks) > 0 {
+		return invalid("bricks", strings.Join(req.Bricks, " "), "are planned, leave them empty")
+	}
+	if req.Size != "" {
+		if _, e := parseSize(req.Size); e != nil {
+			return invalid("size", req.Size, e.Error())
+		}
+	}
+	if len(req.Candidates) == 0 {
+		return invalid("candidates", "", "at least one candidate directory is required")
+	}
+	for i, c := range req.Candidates {
+		field := fmt.Sprintf("candidates[%d]", i)
+		if c.Host != "" {
+			if e := ValidateHost(field+".host", c.Host); e != nil {
+				return e
+			}
+		}
+		if e := ValidatePath(field+".path", c.Path); e != nil {
+			return e
+		}
+		if c.Size != "" {
+			if _, e := parseSize(c.Size); e != nil {
+				return invalid(field+".size", c.Size, e.Error())
+			}
+		}
+	}
+	if e := validateOneOf("force", req.Force, "", "true", "false"); e != nil {
+		return e
+	}
+	return validateOneOf("create", req.Create, "", "true", "false")
+}
+
+func (req VolumeAddBrickRequest) Validate() *ValidationError {
+	if e := req.CommonVolumeRequest.Validate(); e != nil {
+		return e
+	}
+	return ValidateBricks("bricks", req.Bricks)
+}
+
+func (req VolumeExpandRequest) Validate() *ValidationError {
+	if e := req.CommonVolumeRequest.Validate(); e != nil {
+		return e
+	}
+	if e := ValidateBricks("bricks", req.Bricks); e != nil {
+		return e
+	}
+	if e := validateOneOf("force", req.Force, "", "true", "false"); e != nil {
+		return e
+	}
+	return validateOneOf("rebalance", req.Rebalance, "", "full", "fix-layout")
+}
+
+func (req VolumeShrinkRequest) Validate() *ValidationError {
+	if e := req.CommonVolumeRequest.Validate(); e != nil {
+		return e
+	}
+	if e := ValidateBricks("bricks", req.Bricks); e != nil {
+		return e
+	}
+	if req.MinFree != "" {
+		return validateRange("min_free", req.MinFree, 0, 99)
+	}
+	return nil
+}
+
+func (req VolumeRemoveBrickRequest) Validate() *ValidationError {
+	if e := req.CommonVolumeRequest.Validate(); e != nil {
+		return e
+	}
+	if e := validateOneOf("options", req.Options, "", "force"); e != nil {
+		return e
+	}
+	return ValidateBricks("bricks", req.Bricks)
+}
+
+func (req VolumeReBalanceRequest) Validate() *ValidationError {
+	if e := req.CommonVolumeRequest.Validate(); e != nil {
+		return e
+	}
+	return validateOneOf("options", req.Options, "start", "stop", "status")
+}
+
+func (req VolumeOptionSetRequest) Validate() *ValidationError {
+	if e := req.CommonVolumeRequest.Validate(); e != nil {
+		return e
+	}
+	if len(req.Options) == 0 {
+		return invalid("options", "", "at least one option is required")
+	}
+	for i, option := range req.Options {
+		if e := ValidateOptionKey(fmt.Sprintf("options[%d].name", i), option.Name); e != nil {
+			return e
+		}
+		if option.Value == "" || strings.ContainsAny(option.Value, "\n\r") {
+			return invalid(fmt.Sprintf("options[%d].value", i), option.Value, "must be a non-empty single line")
+		}
+	}
+	return validateOneOf("force", req.Force, "", "true", "false")
+}
+
+func (req VolumeOptionRequest) Validate() *ValidationError {
+	if e := req.CommonVolumeRequest.Validate(); e != nil {
+		return e
+	}
+	for i, key := range req.Keys {
+		if e := ValidateOptionKey(fmt.Sprintf("keys[%d]", i), key); e != nil {
+			return e
+		}
+	}
+	return validateOneOf("force", req.Force, "", "true", "false")
+}
+
+func (req CommonSnapshotRequest) Validate() *ValidationError {
+	if e := ValidateSnapname("snapname", req.Snapname); e != nil {
+		return e
+	}
+	return validateOneOf("force", req.Force, "", "true", "false")
+}
+
+func (req BrickThinRequest) Validate() *ValidationError {
+	return ValidatePath("path", req.Path)
+}
+
+func (req SnapshotCreateRequest) Validate() *ValidationError {
+	if e := req.CommonSnapshotRequest.Validate(); e != nil {
+		return e
+	}
+	if e := ValidateVolname("volname", req.Volname); e != nil {
+		return e
+	}
+	if strings.ContainsAny(req.Description, "\n\r") {
+		return invalid("description", req.Description, "must be a single line")
+	}
+	return validateOneOf("no_timestamp", req.NoTimestamp, "", "true", "false")
+}
+
+func (req SnapshotQueryRequest) Validate() *ValidationError {
+	if req.Snapname != "" && req.Volname != "" {
+		return invalid("snapname", req.Snapname, "cannot be combined with volname")
+	}
+	if req.Snapname != "" {
+		return ValidateSnapname("snapname", req.Snapname)
+	}
+	if req.Volname != "" {
+		return ValidateVolname("volname", req.Volname)
+	}
+	return nil
+}
+
+func (req SnapshotCloneRequest) Validate() *ValidationError {
+	if e := req.CommonSnapshotRequest.Validate(); e != nil {
+		return e
+	}
+	return ValidateVolname("clonename", req.Clonename)
+}
+
+func (req SnapshotConfigRequest) Validate() *ValidationError {
+	if req.Volname != "" {
+		if e := ValidateVolname("volname", req.Volname); e != nil {
+			return e
+		}
+		if req.AutoDelete != "" {
+			return invalid("auto_delete", req.AutoDelete, "is a system wide setting, leave volname empty")
+		}
+		if req.ActivateOnCreate != "" {
+			return invalid("activate_on_create", req.ActivateOnCreate, "is a system wide setting, leave volname empty")
+		}
+	}
+	if req.HardLimit != "" {
+		if e := validateCount("snap_max_hard_limit", req.HardLimit, 1); e != nil {
+			return e
+		}
+	}
+	if req.SoftLimit != "" {
+		if n, e := strconv.Atoi(req.SoftLimit); e != nil || n < 1 || n > 100 {
+			return invalid("snap_max_soft_limit", req.SoftLimit, "must be a percentage between 1 and 100")
+		}
+	}
+	if e := validateOneOf("auto_delete", req.AutoDelete, "", "enable", "disable"); e != nil {
+		return e
+	}
+	return validateOneOf("activate_on_create", req.ActivateOnCreate, "", "enable", "disable")
+}
+
+// snapshot names get a _GMT-2006.01.02-15.04.05 suffix
+const snapTimestampLen = len("_GMT-2006.01.02-15.04.05")
+
+func (req SnapshotScheduleRequest) Validate() *ValidationError {
+	if e := ValidateVolname("volname", req.Volname); e != nil {
+		return e
+	}
+	if _, e := ParseCron(req.Cron); e != nil {
+		return invalid("cron", req.Cron, e.Error())
+	}
+	if e := ValidateSnapname("prefix", req.Prefix); e != nil {
+		return e
+	}
+	if len(req.Prefix) > snapnameMax-snapTimestampLen {
+		return invalid("prefix", req.Prefix, fmt.Sprintf("exceeds %d characters", snapnameMax-snapTimestampLen))
+	}
+	for field, n := range map[string]int{"keep_last": req.KeepLast, "keep_daily": req.KeepDaily, "keep_weekly": req.KeepWeekly} {
+		if n < 0 {
+			return invalid(field, strconv.Itoa(n), "cannot be negative")
+		}
+	}
+	return nil
+}
+
+func (req SnapshotScheduleIDRequest) Validate() *ValidationError {
+	if req.ID == "" {
+		return invalid("id", req.ID, "cannot be empty")
+	}
+	return nil
+}
+
+// ValidateQuotaPath accepts a directory of the volume, "/" being its root.
+func ValidateQuotaPath(field, p string) *ValidationError {
+	if p == "/" {
+		return nil
+	}
+	return ValidatePath(field, p)
+}
+
+// ValidatePercent accepts 80 or 80%.
+func ValidatePercent(field, percent string) *ValidationError {
+	n, e := strconv.Atoi(strings.TrimSuffix(percent, "%"))
+	if e != nil || n < 1 || n > 100 {
+		return invalid(field, percent, "must be a percentage between 1 and 100")
+	}
+	return nil
+}
+
+func (req VolumeQuotaListRequest) Validate() *ValidationError {
+	if e := ValidateVolname("volname", req.Volname); e != nil {
+		return e
+	}
+	for i, p := range req.Paths {
+		if e := ValidateQuotaPath(fmt.Sprintf("paths[%d]", i), p); e != nil {
+			return e
+		}
+	}
+	return nil
+}
+
+func (req VolumeQuotaPathRequest) Validate() *ValidationError {
+	if e := ValidateVolname("volname", req.Volname); e != nil {
+		return e
+	}
+	return ValidateQuotaPath("path", req.Path)
+}
+
+func (req VolumeQuotaLimitRequest) Validate() *ValidationError {
+	if e := req.VolumeQuotaPathRequest.Validate(); e != nil {
+		return e
+	}
+	if req.SoftLimit != "" {
+		return ValidatePercent("soft_limit", req.SoftLimit)
+	}
+	return nil
+}
+
+func (req VolumeQuotaUsageRequest) Validate() *ValidationError {
+	if e := req.VolumeQuotaLimitRequest.Validate(); e != nil {
+		return e
+	}
+	if !quotaSize.MatchString(req.Size) {
+		return invalid("size", req.Size, "must be a size such as 512MB or 10GB")
+	}
+	return nil
+}
+
+func (req VolumeQuotaObjectsRequest) Validate() *ValidationError {
+	if e := req.VolumeQuotaLimitRequest.Validate(); e != nil {
+		return e
+	}
+	return validateCount("count", req.Count, 1)
+}
+
+func (req VolumeQuotaRemoveRequest) Validate() *ValidationError {
+	if e := req.VolumeQuotaPathRequest.Validate(); e != nil {
+		return e
+	}
+	return validateOneOf("objects", req.Objects, "", "true", "false")
+}
+
+func (req VolumeQuotaSoftLimitRequest) Validate() *ValidationError {
+	if e := ValidateVolname("volname", req.Volname); e != nil {
+		return e
+	}
+	return ValidatePercent("soft_limit", req.SoftLimit)
+}
+
+func (req VolumeQuotaAlertTimeRequest) Validate() *ValidationError {
+	if e := ValidateVolname("volname", req.Volname); e != nil {
+		return e
+	}
+	if !quotaTime.MatchString(req.AlertTime) {
+		return invalid("alert_time", req.AlertTime, "must be a duration such as 30s, 10m, 2h, 1d or 1w")
+	}
+	return nil
+}
+
+func (req SplitBrainResolveRequest) Validate() *ValidationError {
+	if e := ValidateVolname("volname", req.Volname); e != nil {
+		return e
+	}
+	if e := validateOneOf("policy", req.Policy, PolicyBiggerFile, PolicyLatestMtime, PolicySourceBrick); e != nil {
+		return e
+	}
+	if req.Policy == PolicySourceBrick {
+		if e := ValidateBrick("source_brick", req.SourceBrick); e != nil {
+			return e
+		}
+	} else {
+		if req.SourceBrick != "" {
+			return invalid("source_brick", req.SourceBrick, "is only used by the source-brick policy")
+		}
+		if req.File == "" {
+			return invalid("file", req.File, "is required by the "+req.Policy+" policy")
+		}
+	}
+	if req.File != "" && !gfidFile.MatchString(req.File) {
+		if e := ValidatePath("file", req.File); e != nil {
+			return invalid("file", req.File, e.Reason+", or gfid:<gfid>")
+		}
+	}
+	return validateOneOf("dry_run", req.DryRun, "", "true", "false")
+}
+
+func (req VolumeReplaceBrickRequest) Validate() *ValidationError {
+	if e := ValidateVolname("volname", req.Volname); e != nil {
+		return e
+	}
+	if e := ValidateBrick("brick", req.Brick); e != nil {
+		return e
+	}
+	if e := ValidateBrick("new_brick", req.NewBrick); e != nil {
+		return e
+	}
+	if req.NewBrick == req.Brick {
+		return invalid("new_brick", req.NewBrick, "is the brick being replaced, use reset-brick")
+	}
+	return validateOneOf("force", req.Force, "", "true", "false")
+}
+
+func (req BrickPathRequest) Validate() *ValidationError {
+	return ValidatePath("path", req.Path)
+}
+
+func (req VolumeResetBrickRequest) Validate() *ValidationError {
+	if e := ValidateVolname("volname", req.Volname); e != nil {
+		return e
+	}
+	if e := ValidateBrick("brick", req.Brick); e != nil {
+		return e
+	}
+	if req.NewBrick != "" {
+		if e := ValidateBrick("new_brick", req.NewBrick); e != nil {
+			return e
+		}
+		_, oldPath := parser.SplitBrick(req.Brick)
+		if _, newPath := parser.SplitBrick(req.NewBrick); newPath != oldPath {
+			return invalid("new_brick", req.NewBrick, "must keep the path of brick, use replace-brick to move it")
+		}
+	}
+	return validateOneOf("force", req.Force, "", "true", "false")
+}
+
+func (req VolumeBrickFailureRequest) Validate() *ValidationError {
+	if e := ValidateVolname("volname", req.Volname); e != nil {
+		return e
+	}
+	if e := ValidateBrick("brick", req.Brick); e != nil {
+		return e
+	}
+	brick := req.Brick
+	if req.NewBrick != "" {
+		if e := ValidateBrick("new_brick", req.NewBrick); e != nil {
+			return e
+		}
+		brick = req.NewBrick
+	}
+	if req.Device != "" {
+		if e := ValidateDevice("device", req.Device); e != nil {
+			return e
+		}
+		if _, path := parser.SplitBrick(brick); brickMountDir(path) == "/" {
+			return invalid("device", req.Device, "cannot be mounted on the root directory, the brick path needs a parent")
+		}
+	}
+	return validateOneOf("force", req.Force, "", "true", "false")
+}
+
+func (req BrickDeviceRequest) Validate() *ValidationError {
+	if e := ValidateDevice("device", req.Device); e != nil {
+		return e
+	}
+	if e := ValidatePath("path", req.Path); e != nil {
+		return e
+	}
+	if brickMountDir(req.Path) == "/" {
+		return invalid("path", req.Path, "needs a parent directory to mount the device on")
+	}
+	return nil
+}
+
+func validateRange(field, value string, min, max int) *ValidationError {
+	n, e := strconv.Atoi(value)
+	if e != nil || n < min || n > max {
+		return invalid(field, value, fmt.Sprintf("must be an integer between %d and %d", min, max))
+	}
+	return nil
+}
+
+func (req VolumeHealOptionsRequest) Validate() *ValidationError {
+	if e := ValidateVolname("volname", req.Volname); e != nil {
+		return e
+	}
+	if req.WindowSize != "" {
+		if e := validateRange("self_heal_window_size", req.WindowSize, 1, 1024); e != nil {
+			return e
+		}
+	}
+	if req.HealTimeout != "" {
+		if e := validateCount("heal_timeout", req.HealTimeout, 5); e != nil {
+			return e
+		}
+	}
+	if req.MaxThreads != "" {
+		return validateRange("shd_max_threads", req.MaxThreads, 1, 64)
+	}
+	return nil
+}
+
+func (req CommonMountRequest) Validate() *ValidationError {
+	if e := ValidateVolname("volname", req.Volname); e != nil {
+		return e
+	}
+	if e := validateOneOf("type", req.Type, "glusterfs", "nfs"); e != nil {
+		return e
+	}
+	return ValidateMountPath("mount", req.Mount)
+}
+
+func (req MountDeleteRequest) Validate() *ValidationError {
+	if e := ValidateMountPath("mount", req.Mount); e != nil {
+		return e
+	}
+	return validateOneOf("force", req.Force, "", "true", "false")
+}
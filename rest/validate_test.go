@@ -0,0 +1,111 @@
+package gluster
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestValidateFields(t *testing.T) {
+	tests := []struct {
+		name  string
+		check func(field, value string) *ValidationError
+		value string
+		ok    bool
+	}{
+		{"volname", ValidateVolname, "test_vol-1", true},
+		{"volname", ValidateVolname, "", false},
+		{"volname", ValidateVolname, "-test", false},
+		{"volname", ValidateVolname, "test;rm", false},
+		{"volname", ValidateVolname, "$(id)", false},
+		{"volname", ValidateVolname, "all", false},
+		{"volname", ValidateVolname, strings.Repeat("a", volnameMax+1), false},
+		{"snapname", ValidateSnapname, "snap1_GMT-2019.04.11-08.25.34", true},
+		{"snapname", ValidateSnapname, "snap 1", false},
+		{"host", ValidateHost, "node1.example.com", true},
+		{"host", ValidateHost, "10.0.0.1", true},
+		{"host", ValidateHost, "fe80::1", true},
+		{"host", ValidateHost, "node1 node2", false},
+		{"host", ValidateHost, "-node1", false},
+		{"path", ValidatePath, "/data/brick1/test", true},
+		{"path", ValidatePath, "data", false},
+		{"path", ValidatePath, "/data/../etc", false},
+		{"path", ValidatePath, "/data/", false},
+		{"path", ValidatePath, "/data/a b", false},
+		{"path", ValidatePath, "/", false},
+		{"device", ValidateDevice, "/dev/mapper/vg-brick1", true},
+		{"device", ValidateDevice, "/data/sdc", false},
+		{"device", ValidateDevice, "/dev/../etc/passwd", false},
+		{"brick", ValidateBrick, "node1:/data/test", true},
+		{"brick", ValidateBrick, "10.0.0.1:/data/test", true},
+		{"brick", ValidateBrick, "node1", false},
+		{"brick", ValidateBrick, ":/data/test", false},
+		{"brick", ValidateBrick, "node1:data", false},
+		{"brick", ValidateBrick, "node1:/data/test;reboot", false},
+		{"transport", ValidateTransport, "tcp,rdma", true},
+		{"transport", ValidateTransport, "udp", false},
+		{"mount", ValidateMountPath, "/mnt/test", true},
+		{"mount", ValidateMountPath, "/etc", false},
+		{"option", ValidateOptionKey, "performance.cache-size", true},
+		{"option", ValidateOptionKey, "a=b", false},
+	}
+	for _, test := range tests {
+		e := test.check(test.name, test.value)
+		if (e == nil) != test.ok {
+			t.Errorf("%s %q: %v, want ok %v", test.name, test.value, e, test.ok)
+		}
+		if e != nil && e.Field != test.name {
+			t.Errorf("%s %q: field %q", test.name, test.value, e.Field)
+		}
+	}
+}
+
+func TestBadRequest(t *testing.T) {
+	tests := []struct {
+		name    string
+		handler http.HandlerFunc
+		body    string
+		field   string
+	}{
+		{"malformed json", ProcessVolumeStart, `{"volname": `, ""},
+		{"wrong type", ProcessVolumeCreate, `{"volname": "test", "replica": "3"}`, ""},
+		{"empty volname", ProcessVolumeStart, `{"volname": ""}`, "volname"},
+		{"reserved volname", ProcessVolumeStart, `{"volname": "all"}`, "volname"},
+		{"volname with a space", ProcessVolumeStart, `{"volname": "a b"}`, "volname"},
+		{"brick without path", ProcessVolumeCreate, `{"volname": "test", "bricks": ["node1"]}`, "bricks[0]"},
+		{"relative brick path", ProcessVolumeCreate, `{"volname": "test", "bricks": ["node1:data"]}`, "bricks[0]"},
+		{"brick listed twice", ProcessVolumeCreate, `{"volname": "test", "bricks": ["node1:/data/test", "node1:/data/test"]}`, "bricks[1]"},
+		{"partial replica set", ProcessVolumeCreate, `{"volname": "test", "replica": 3, "bricks": ["node1:/data/test", "node2:/data/test"]}`, "bricks"},
+		{"replica set on one host", ProcessVolumeCreate, `{"volname": "test", "replica": 2, "bricks": ["node1:/data/b1", "node1:/data/b2"]}`, "bricks[1]"},
+		{"arbiter without replica 3", ProcessVolumeCreate, `{"volname": "test", "replica": 2, "arbiter": 1, "bricks": ["node1:/data/test", "node2:/data/test"]}`, "arbiter"},
+		{"unknown transport", ProcessVolumeCreate, `{"volname": "test", "transport": "udp", "bricks": ["node1:/data/test"]}`, "transport"},
+		{"remove-brick option", ProcessVolumeRemoveBrick, `{"volname": "test", "bricks": ["node1:/data/test"], "options": "start"}`, "options"},
+		{"rebalance option", ProcessVolumeReBalance, `{"volname": "test", "options": "fix"}`, "options"},
+		{"peer hostname", ProcessPeerAdd, `{"hostname": "node1;reboot"}`, "hostname"},
+		{"quota size", ProcessVolumeQuotaLimitUsage, `{"volname": "test", "path": "/data", "size": "10 GB"}`, "size"},
+		{"quota path", ProcessVolumeQuotaLimitUsage, `{"volname": "test", "path": "/data/../etc", "size": "10GB"}`, "path"},
+		{"quota soft limit", ProcessVolumeQuotaLimitUsage, `{"volname": "test", "path": "/data", "size": "10GB", "soft_limit": "120%"}`, "soft_limit"},
+		{"quota object count", ProcessVolumeQuotaLimitObjects, `{"volname": "test", "path": "/data", "count": "0"}`, "count"},
+		{"device outside /dev", ProcessVolumeReplaceFailedBrick, `{"volname": "test", "brick": "node1:/data/brick1/test", "device": "/data/sdc"}`, "device"},
+		{"device for a brick on /", ProcessVolumeReplaceFailedBrick, `{"volname": "test", "brick": "node1:/data/brick1/test", "new_brick": "node3:/brick", "device": "/dev/sdc"}`, "device"},
+		{"device prepare path", ProcessBrickDevicePrepare, `{"device": "/dev/sdc", "path": "/brick"}`, "path"},
+		{"snapshot name", ProcessSnapshotCreate, `{"snapname": "snap 1", "volname": "test"}`, "snapname"},
+		{"snapshot description", ProcessSnapshotCreate, `{"snapname": "snap1", "volname": "test", "description": "a\nb"}`, "description"},
+		{"snapshot force", ProcessSnapshotActivate, `{"snapname": "snap1", "force": "yes"}`, "force"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			f := fakeGluster(t)
+			var rsp CommonResponse
+			if status := serve(t, test.handler, test.body, &rsp); status != http.StatusBadRequest {
+				t.Errorf("status %d, want 400", status)
+			}
+			if rsp.Result != "ERROR" || rsp.Error == nil || rsp.Error.Code != CodeInvalidArgument || rsp.Error.Field != test.field {
+				t.Errorf("got %+v, want invalid_argument on %q", rsp.Error, test.field)
+			}
+			if calls := f.Invocations(); len(calls) != 0 {
+				t.Errorf("ran %q for an invalid request", calls)
+			}
+		})
+	}
+}
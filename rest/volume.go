@@ -1,15 +1,13 @@
 package gluster
 
 import (
-	"encoding/json"
+	"context"
 	"encoding/xml"
 	"fmt"
-	"io/ioutil"
 	"net/http"
-	"os/exec"
-
-	"github.com/errors"
+	"strings"
 
+	"hualu.com/gluster-rest/parser"
 	L "hualu.com/logger"
 )
 
@@ -18,19 +16,32 @@ type CommonVolumeRequest struct {
 	Volname string `json:"volname"`
 }
 
-type CommonVolumeResponse struct {
-	Result string `json:"result"`
-	Errors string `json:"errors,omitempty"`
+type CommonVolumeResponse = CommonResponse
+
+// VolumeQueryRequest selects one volume, or all of them when Volname is empty.
+type VolumeQueryRequest struct {
+	CommonVolumeRequest
 }
 
+// VolumeCreateRequest creates a distribute volume, or with replica or disperse
+// counts a volume of replica or disperse sets, see VolumeLayout.
 type VolumeCreateRequest struct {
 	CommonVolumeRequest
-	Type       string   `json:"type,omitempty"`
-	Transport  string   `json:"transport"`
-	Count      string   `json:"count"`
-	Redundancy string   `json:"redundancy"`
-	Bricks     []string `json:"bricks"`
-	Force      string   `json:"force"`
+	Replica      int      `json:"replica,omitempty"`       // bricks per replica set, arbiter included
+	Arbiter      int      `json:"arbiter,omitempty"`       // 1 with replica 3
+	Disperse     int      `json:"disperse,omitempty"`      // bricks per disperse set
+	DisperseData int      `json:"disperse_data,omitempty"` // data bricks per disperse set
+	Redundancy   int      `json:"redundancy,omitempty"`    // bricks per disperse set that may fail
+	Transport    string   `json:"transport"`
+	Bricks       []string `json:"bricks"`  // kept in order, consecutive bricks form a set
+	Force        string   `json:"force"`   // also allows two bricks of a set on one host
+	Options      []Option `json:"options"` // set between create and start, e.g. {"name": "group", "value": "virt"}
+	Start        string   `json:"start"`   // false leaves the volume created, started by default
+	Atomic       string   `json:"atomic"`  // delete the volume again when setting options or starting fails
+
+	// Deprecated: the old form of one count, e.g. "type": "replica", "count": "3"
+	Type  string `json:"type,omitempty"`
+	Count string `json:"count,omitempty"`
 }
 
 type VolumeAddBrickRequest struct {
@@ -41,7 +52,9 @@ type VolumeAddBrickRequest struct {
 type VolumeRemoveBrickRequest struct {
 	CommonVolumeRequest
 	Bricks  []string `json:"bricks"`
-	Options string   `json:"options"`
+	Options string   `json:"options"` // only "force" on /brick/remove, see /brick/remove/{start,status,commit,stop}
+
+	replica int // the replica count left, when the bricks reduce it
 }
 
 type VolumeReBalanceRequest struct {
@@ -66,7 +79,7 @@ type VolumeReBalanceXML struct {
 type VolReBalance struct {
 	TaskId    string              `xml:"task-id" json:"task_id,omitempty"`
 	Op        int                 `xml:"op" json:"op,omitempty"`
-	NodeCount int                 `xml:"nodeCount"json:"node_count,omitempty"`
+	NodeCount int                 `xml:"nodeCount" json:"node_count,omitempty"`
 	Node      []NodeInRebalance   `xml:"node" json:"node,omitempty"`
 	Aggregate AggregateInRebalace `xml:"aggregate" json:"aggregate,omitempty"`
 }
@@ -99,6 +112,7 @@ type AggregateInRebalace struct {
 type VolumeInfoResponse struct {
 	CommonVolumeResponse
 	VolumeInfoXML
+	Volumes []parser.Volume `json:"volumes"` // typed model of VolInfo, with subvolume health
 }
 
 type VolumeInfoXML struct {
@@ -127,6 +141,7 @@ type Volume struct {
 	BrickCount string `xml:"brickCount" json:"brick_count"`
 	//StripeCount   string   `xml:"stripeCount" json:"stripe_count"`
 	ReplicaCount    string   `xml:"replicaCount" json:"replica_count"`       //Replicate卷冗余个数
+	ArbiterCount    string   `xml:"arbiterCount" json:"arbiter_count"`       //每组replica中的arbiter个数
 	DisperseCount   string   `xml:"disperseCount" json:"disperse_count"`     //Disperse卷每组个数: 数据+冗余
 	RedundancyCount string   `xml:"redundancyCount" json:"redundancy_count"` //Disperse卷冗余个数
 	Transport       string   `xml:"transport" json:"transports"`
@@ -147,6 +162,7 @@ type Option struct {
 type VolumeStatusResponse struct {
 	CommonVolumeResponse
 	VolumeStatusXML
+	Volumes []parser.Volume `json:"volumes"` // subvolumes of each volume with their health
 }
 
 type VolumeStatusXML struct {
@@ -177,6 +193,9 @@ type NodeInStatus struct {
 	Port     string `xml:"port" json:"port"`
 	Ports    Ports  `xml:"ports" json:"ports"`
 	Pid      string `xml:"pid" json:"pid"`
+	// volume status ... detail
+	SizeTotal string `xml:"sizeTotal" json:"size_total,omitempty"`
+	SizeFree  string `xml:"sizeFree" json:"size_free,omitempty"`
 }
 
 type Ports struct {
@@ -184,136 +203,185 @@ type Ports struct {
 	Rdma string `xml:"rdma" json:"rdma"`
 }
 
+/*
+[example]
+docker exec glusterfs gluster volume create test replica 3 arbiter 1 node1:/data/test node2:/data/test node3:/data/test
+
+curl -X POST http://127.0.0.1:7030/gluster/volume/create -H 'Content-Type: application/json' -d '{
+"volname": "test",
+"replica": 3,
+"arbiter": 1,
+"bricks": ["node1:/data/test", "node2:/data/test", "node3:/data/test"],
+"options": [{"name": "group", "value": "virt"}],
+"atomic": "true"
+}'
+<- {"result":"OK","job_id":"9f2c6a1e0b7d4c35"}
+
+curl -X GET http://127.0.0.1:7030/gluster/jobs/9f2c6a1e0b7d4c35
+<- {"result":"OK","job":{"id":"9f2c6a1e0b7d4c35","op":"create","volname":"test","state":"failed","progress":{"steps":[
+{"name":"create","state":"done",...},{"name":"set options","state":"done",...},{"name":"start","state":"failed","message":"..."},
+{"name":"rollback","state":"done","message":"volume test deleted"}]},"error":{...}}}
+*/
 func ProcessVolumeCreate(w http.ResponseWriter, r *http.Request) {
 
 	var rsp CommonVolumeResponse
-	defer func() {
-		buf, e := json.Marshal(&rsp)
-		if e != nil {
-			w.WriteHeader(500)
-			return
-		}
-		w.Write([]byte(buf))
-	}()
+	defer writeResponse(w, &rsp)
 
 	// analyze request
-	body, e := ioutil.ReadAll(r.Body)
-	defer r.Body.Close()
-	if e != nil {
+	var volumeCreateReq VolumeCreateRequest
+	if e := readRequest(r, &volumeCreateReq); e != nil {
 		L.Gluster.Error(e.Error())
-		rsp.Result = "ERROR"
-		rsp.Errors = e.Error()
+		rsp.Fail(e)
 		return
 	}
 
-	var volumeCreateReq VolumeCreateRequest
-	e = json.Unmarshal(body, &volumeCreateReq)
+	//L.Gluster.Debugf("After Unmarshall > VolumeCreateReq is: %+v", volumeCreateReq)
+
+	job, e := startCreate(r, volumeCreateReq)
 	if e != nil {
 		L.Gluster.Error(e.Error())
-		rsp.Result = "ERROR"
-		rsp.Errors = e.Error()
+		rsp.Fail(e)
 		return
 	}
+	rsp.JobID = job.ID
+	rsp.Result = "OK"
+}
 
-	//L.Gluster.Debugf("After Unmarshall > VolumeCreateReq is: %+v", volumeCreateReq)
+// startCreate creates the volume in a job holding its lock, creating a volume
+// on many bricks takes a while.
+func startCreate(r *http.Request, volumeCreateReq VolumeCreateRequest) (Job, error) {
+	release, e := lockForRequest(r, volumeLock(volumeCreateReq.Volname), "create")
+	if e != nil {
+		return Job{}, e
+	}
+	return jobs.Start("create", volumeCreateReq.Volname, locked(release, createJob(volumeCreateReq))), nil
+}
 
-	// TODO
-	// run command in docker
-	var bricks string
-	for _, brick := range volumeCreateReq.Bricks {
-		bricks = brick + " " + bricks
+// phases of volume create, reported as job steps
+const (
+	stepCreate   = "create"
+	stepOptions  = "set options"
+	stepStart    = "start"
+	stepRollback = "rollback"
+)
+
+// createJob creates the volume, sets its options and starts it. When a phase
+// after create fails and the request is atomic, the volume is deleted again.
+func createJob(volumeCreateReq VolumeCreateRequest) JobFunc {
+	return func(ctx context.Context, update func(JobProgress)) (string, error) {
+		steps := newJobSteps(update, stepCreate, stepOptions, stepStart)
+
+		var output string
+		e := steps.Run(stepCreate, func() (string, error) {
+			var e error
+			output, e = createVolume(ctx, volumeCreateReq)
+			return strings.TrimSpace(output), e
+		})
+		if e != nil {
+			return output, e
+		}
+
+		if len(volumeCreateReq.Options) == 0 {
+			steps.Skip(stepOptions, "no options")
+		} else {
+			e = steps.Run(stepOptions, func() (string, error) {
+				for _, option := range volumeCreateReq.Options {
+					out, e := runGluster(ctx, "volume", "set", volumeCreateReq.Volname, option.Name, option.Value)
+					if e != nil {
+						L.Gluster.Error(string(out))
+						err := glusterError(out, e)
+						return fmt.Sprintf("%s %s: %s", option.Name, option.Value, err.Message), err
+					}
+				}
+				return fmt.Sprintf("%d options set", len(volumeCreateReq.Options)), nil
+			})
+		}
+
+		if e == nil && volumeCreateReq.Start == "false" {
+			steps.Skip(stepStart, "start not requested")
+		} else if e == nil {
+			e = steps.Run(stepStart, func() (string, error) {
+				out, e := runGluster(ctx, "volume", "start", volumeCreateReq.Volname)
+				if e != nil {
+					L.Gluster.Error(string(out))
+					return "", glusterError(out, e)
+				}
+				return strings.TrimSpace(string(out)), nil
+			})
+		} else {
+			steps.Skip(stepStart, "an earlier phase failed")
+		}
+		if e == nil || volumeCreateReq.Atomic != "true" {
+			return output, e
+		}
+
+		// the job reports the phase that failed, not the rollback
+		steps.Run(stepRollback, func() (string, error) {
+			out, e := runGlusterConfirm(context.Background(), "volume", "delete", volumeCreateReq.Volname)
+			if e != nil {
+				L.Gluster.Error(string(out))
+				return "", glusterError(out, e)
+			}
+			return "volume " + volumeCreateReq.Volname + " deleted", nil
+		})
+		return output, e
 	}
-	//L.Gluster.Debugf("bricks is [%s]", bricks)
+}
 
-	var force string
+func createVolume(ctx context.Context, volumeCreateReq VolumeCreateRequest) (string, error) {
+	layout, err := volumeCreateReq.Layout()
+	if err != nil {
+		return "", err
+	}
+	args := append([]string{"volume", "create", volumeCreateReq.Volname}, layout.Args...)
+	if volumeCreateReq.Transport != "" {
+		args = append(args, "transport", volumeCreateReq.Transport)
+	}
+	// the order decides which bricks form a set
+	args = append(args, volumeCreateReq.Bricks...)
 	if volumeCreateReq.Force == "true" {
-		force = "force"
-	} else {
-		force = ""
+		args = append(args, "force")
 	}
 
 	//cmdString := fmt.Sprintf(
 	//	"docker exec glusterfs sh -c \"gluster volume create %s %s %s transport %s %s %s\"",
 	//	volumeCreateReq.Volname, volumeCreateReq.Type, volumeCreateReq.Count, volumeCreateReq.Transport, bricks, force)
 
-	cmdString := fmt.Sprintf(
-		"gluster volume create %s %s %s transport %s %s %s",
-		volumeCreateReq.Volname, volumeCreateReq.Type, volumeCreateReq.Count, volumeCreateReq.Transport, bricks, force)
-
-	if volumeCreateReq.Force == "true" {
-		//cmdString = fmt.Sprintf(
-		//	"docker exec glusterfs sh -c \"gluster volume create %s %s %s transport %s %s %s <<<y\"",
-		//	volumeCreateReq.Volname, volumeCreateReq.Type, volumeCreateReq.Count, volumeCreateReq.Transport, bricks, force)
-
-		cmdString = fmt.Sprintf(
-			"gluster volume create %s %s %s transport %s %s %s <<<y",
-			volumeCreateReq.Volname, volumeCreateReq.Type, volumeCreateReq.Count, volumeCreateReq.Transport, bricks, force)
-	}
-
-	L.Gluster.Info(cmdString)
-
-	cmd := exec.Command("sh", "-c", cmdString)
-	output, e := cmd.CombinedOutput()
+	output, e := runGlusterConfirm(ctx, args...)
 	if e != nil {
-		rsp.Result = "ERROR"
-		rsp.Errors = string(output)
 		L.Gluster.Error(string(output))
-		return
+		return string(output), glusterError(output, e)
 	}
-
-	// start
-	cmdString = fmt.Sprintf("gluster volume start %s", volumeCreateReq.Volname)
-	L.Gluster.Info(cmdString)
-	cmd = exec.Command("sh", "-c", cmdString)
-	cmd.CombinedOutput()
-
-	rsp.Result = "OK"
-	rsp.Errors = string(output)
+	return string(output), nil
 }
 
 func ProcessVolumeStart(w http.ResponseWriter, r *http.Request) {
 
 	var rsp CommonVolumeResponse
-	defer func() {
-		buf, e := json.Marshal(&rsp)
-		if e != nil {
-			w.WriteHeader(500)
-			return
-		}
-		w.Write([]byte(buf))
-	}()
+	defer writeResponse(w, &rsp)
 
 	// analyze request
-	body, e := ioutil.ReadAll(r.Body)
-	defer r.Body.Close()
-	if e != nil {
+	var volumeStartReq CommonVolumeRequest
+	if e := readRequest(r, &volumeStartReq); e != nil {
 		L.Gluster.Error(e.Error())
-		rsp.Result = "ERROR"
-		rsp.Errors = e.Error()
+		rsp.Fail(e)
 		return
 	}
 
-	var volumeStartReq CommonVolumeRequest
-	e = json.Unmarshal(body, &volumeStartReq)
+	release, e := lockForRequest(r, volumeLock(volumeStartReq.Volname), "start")
 	if e != nil {
 		L.Gluster.Error(e.Error())
-		rsp.Result = "ERROR"
-		rsp.Errors = e.Error()
+		rsp.Fail(e)
 		return
 	}
+	defer release()
 
 	// run command in docker
 	//cmdString := fmt.Sprintf("docker exec glusterfs gluster volume start %s", volumeStartReq.Volname)
-	cmdString := fmt.Sprintf("gluster volume start %s", volumeStartReq.Volname)
-
-	L.Gluster.Info(cmdString)
-
-	cmd := exec.Command("sh", "-c", cmdString)
-	output, e := cmd.CombinedOutput()
+	output, e := runGluster(r.Context(), "volume", "start", volumeStartReq.Volname)
 	if e != nil {
 		L.Gluster.Error(string(output))
-		rsp.Result = "ERROR"
-		rsp.Errors = string(output)
+		rsp.Fail(glusterError(output, e))
 		return
 	}
 
@@ -323,45 +391,30 @@ func ProcessVolumeStart(w http.ResponseWriter, r *http.Request) {
 
 func ProcessVolumeStop(w http.ResponseWriter, r *http.Request) {
 	var rsp CommonVolumeResponse
-	defer func() {
-		buf, e := json.Marshal(&rsp)
-		if e != nil {
-			w.WriteHeader(500)
-			return
-		}
-		w.Write([]byte(buf))
-	}()
+	defer writeResponse(w, &rsp)
 
 	// analyze request
 	var volumeStopReq CommonVolumeRequest
-	body, e := ioutil.ReadAll(r.Body)
-	defer r.Body.Close()
-	if e != nil {
+	if e := readRequest(r, &volumeStopReq); e != nil {
 		L.Gluster.Error(e.Error())
-		rsp.Result = "ERROR"
-		rsp.Errors = e.Error()
+		rsp.Fail(e)
 		return
 	}
 
-	e = json.Unmarshal(body, &volumeStopReq)
+	release, e := lockForRequest(r, volumeLock(volumeStopReq.Volname), "stop")
 	if e != nil {
 		L.Gluster.Error(e.Error())
-		rsp.Result = "ERROR"
-		rsp.Errors = e.Error()
+		rsp.Fail(e)
 		return
 	}
+	defer release()
 
 	// run command in docker
 	//cmdString := fmt.Sprintf("docker exec glusterfs sh -c 'gluster volume stop %s force <<< y' ", volumeStopReq.Volname)
-	cmdString := fmt.Sprintf("gluster volume stop %s force <<< y ", volumeStopReq.Volname)
-	L.Gluster.Info(cmdString)
-
-	cmd := exec.Command("sh", "-c", cmdString)
-	output, e := cmd.CombinedOutput()
+	output, e := runGlusterConfirm(r.Context(), "volume", "stop", volumeStopReq.Volname, "force")
 	if e != nil {
 		L.Gluster.Error(string(output))
-		rsp.Result = "ERROR"
-		rsp.Errors = string(output)
+		rsp.Fail(glusterError(output, e))
 		return
 	}
 
@@ -371,49 +424,32 @@ func ProcessVolumeStop(w http.ResponseWriter, r *http.Request) {
 
 func ProcessVolumeDelete(w http.ResponseWriter, r *http.Request) {
 	var rsp CommonVolumeResponse
-	defer func() {
-		buf, e := json.Marshal(&rsp)
-		if e != nil {
-			w.WriteHeader(500)
-			return
-		}
-		w.Write([]byte(buf))
-	}()
+	defer writeResponse(w, &rsp)
 
 	// analyze request
 	var volumeDeleteReq CommonVolumeRequest
-	body, e := ioutil.ReadAll(r.Body)
-	defer r.Body.Close()
-	if e != nil {
+	if e := readRequest(r, &volumeDeleteReq); e != nil {
 		L.Gluster.Error(e.Error())
-		rsp.Result = "ERROR"
-		rsp.Errors = e.Error()
+		rsp.Fail(e)
 		return
 	}
 
-	e = json.Unmarshal(body, &volumeDeleteReq)
+	release, e := lockForRequest(r, volumeLock(volumeDeleteReq.Volname), "delete")
 	if e != nil {
 		L.Gluster.Error(e.Error())
-		rsp.Result = "ERROR"
-		rsp.Errors = e.Error()
+		rsp.Fail(e)
 		return
 	}
+	defer release()
 
 	// run command
-	cmdString := fmt.Sprintf("gluster volume stop %s force <<< y ", volumeDeleteReq.Volname)
-	L.Gluster.Info(cmdString)
-	cmd := exec.Command("sh", "-c", cmdString)
-	cmd.CombinedOutput()
+	runGlusterConfirm(r.Context(), "volume", "stop", volumeDeleteReq.Volname, "force")
 
 	//cmdString := fmt.Sprintf("docker exec glusterfs sh -c \"gluster volume delete %s <<<y\"", volumeDeleteReq.Volname)
-	cmdString = fmt.Sprintf("gluster volume delete %s <<<y", volumeDeleteReq.Volname)
-	L.Gluster.Info(cmdString)
-	cmd = exec.Command("sh", "-c", cmdString)
-	output, e := cmd.CombinedOutput()
+	output, e := runGlusterConfirm(r.Context(), "volume", "delete", volumeDeleteReq.Volname)
 	if e != nil {
 		L.Gluster.Error(string(output))
-		rsp.Result = "ERROR"
-		rsp.Errors = string(output)
+		rsp.Fail(glusterError(output, e))
 		return
 	}
 
@@ -423,44 +459,27 @@ func ProcessVolumeDelete(w http.ResponseWriter, r *http.Request) {
 
 func ProcessVolumeInfo(w http.ResponseWriter, r *http.Request) {
 	var rsp VolumeInfoResponse
-	defer func() {
-		buf, e := json.Marshal(&rsp)
-		if e != nil {
-			L.Gluster.Error(e.Error())
-			w.WriteHeader(500)
-			return
-		}
-		w.Write([]byte(buf))
-	}()
+	defer writeResponse(w, &rsp)
 
 	// analyze request
-	body, e := ioutil.ReadAll(r.Body)
-	if e != nil {
+	var volumeInfoReq VolumeQueryRequest
+	if e := readRequest(r, &volumeInfoReq); e != nil {
 		L.Gluster.Error(e.Error())
-		rsp.Result = "ERROR"
-		rsp.Errors = e.Error()
+		rsp.Fail(e)
 		return
 	}
 
-	var volumeInfoReq CommonVolumeRequest
-	e = json.Unmarshal(body, &volumeInfoReq)
-	if e != nil {
-		L.Gluster.Error(e.Error())
-		rsp.Result = "ERROR"
-		rsp.Errors = e.Error()
-		return
+	volname := "all" // an empty volname queries every volume
+	if volumeInfoReq.Volname != "" {
+		volname = volumeInfoReq.Volname
 	}
 
 	// run command in docker
 	//cmdString := fmt.Sprintf("docker exec glusterfs  gluster volume info %s --xml", volumeInfoReq.Volname)
-	cmdString := fmt.Sprintf("gluster volume info %s --xml", volumeInfoReq.Volname)
-	L.Gluster.Info(cmdString)
-	cmd := exec.Command("sh", "-c", cmdString)
-	output, e := cmd.CombinedOutput()
+	output, e := runGluster(r.Context(), "volume", "info", volname, "--xml")
 	if e != nil {
 		L.Gluster.Error(string(output))
-		rsp.Result = "ERROR"
-		rsp.Errors = string(output)
+		rsp.Fail(glusterError(output, e))
 		return
 	}
 
@@ -469,70 +488,62 @@ func ProcessVolumeInfo(w http.ResponseWriter, r *http.Request) {
 	var volinfoXML VolumeInfoXML
 	e = xml.Unmarshal(output, &volinfoXML)
 	if e != nil {
-		rsp.Result = "ERROR"
-		rsp.Errors = e.Error()
 		L.Gluster.Error(e.Error())
+		rsp.Fail(e)
+		return
 	}
 	L.Gluster.Infof("XML is %+v", volinfoXML)
 
+	volumes, e := parser.ParseVolumeInfo(output)
+	if e != nil {
+		L.Gluster.Error(e.Error())
+		rsp.Fail(e)
+		return
+	}
+
+	volumesHealth(r.Context(), volname, volumes)
+
 	rsp.VolumeInfoXML = volinfoXML
+	rsp.Volumes = volumes
 	rsp.Result = "OK"
 
 }
 
+// ProcessVolumeAddBrick adds the bricks with force and without a rebalance,
+// /gluster/volume/expand checks the sets and rebalances.
 func ProcessVolumeAddBrick(w http.ResponseWriter, r *http.Request) {
 	var rsp CommonVolumeResponse
-	defer func() {
-		buf, e := json.Marshal(rsp)
-		if e != nil {
-			L.Gluster.Error(e.Error())
-			w.WriteHeader(500)
-			return
-		}
-		w.Write(buf) // w.Write([]byte(buf))
-	}()
+	defer writeResponse(w, &rsp)
 
 	// analyze request
 	var volumeAddBrickReq VolumeAddBrickRequest
-	body, e := ioutil.ReadAll(r.Body)
-	defer r.Body.Close()
-	if e != nil {
+	if e := readRequest(r, &volumeAddBrickReq); e != nil {
 		L.Gluster.Error(e.Error())
-		rsp.Result = "ERROR"
-		rsp.Errors = e.Error()
+		rsp.Fail(e)
 		return
 	}
 
-	//L.Gluster.Debugf("volumeAddBrickReqJSON is: %s", string(body))
-
-	e = json.Unmarshal(body, &volumeAddBrickReq)
+	release, e := lockForRequest(r, volumeLock(volumeAddBrickReq.Volname), "add-brick")
 	if e != nil {
 		L.Gluster.Error(e.Error())
-		rsp.Result = "ERROR"
-		rsp.Errors = e.Error()
+		rsp.Fail(e)
 		return
 	}
+	defer release()
 
 	//L.Gluster.Debugf("volumeAddBrickReq is: %+v", volumeAddBrickReq)
 
 	// run command in docker
-	var bricks string
-	for i := range volumeAddBrickReq.Bricks {
-		bricks = fmt.Sprintf("%s %s", bricks, volumeAddBrickReq.Bricks[i])
-	}
+	args := []string{"volume", "add-brick", volumeAddBrickReq.Volname}
+	args = append(args, volumeAddBrickReq.Bricks...)
+	args = append(args, "force")
 
-	//L.Gluster.Info("bricks is:", bricks)
 	//cmdString := fmt.Sprintf("docker exec glusterfs gluster volume add-brick %s %s", volumeAddBrickReq.Volname, bricks)
-	cmdString := fmt.Sprintf("gluster volume add-brick %s %s force<<<y", volumeAddBrickReq.Volname, bricks)
-	L.Gluster.Info(cmdString)
-
-	cmd := exec.Command("sh", "-c", cmdString)
-	output, e := cmd.CombinedOutput()
+	output, e := runGlusterConfirm(r.Context(), args...)
 	if e != nil {
 		L.Gluster.Error("error is " + e.Error())
 		L.Gluster.Error("cmd output is " + string(output))
-		rsp.Result = "ERROR"
-		rsp.Errors = string(output)
+		rsp.Fail(glusterError(output, e))
 		return
 	}
 
@@ -542,78 +553,68 @@ func ProcessVolumeAddBrick(w http.ResponseWriter, r *http.Request) {
 	rsp.Errors = string(output)
 }
 
+// ProcessVolumeRemoveBrick removes the bricks with force, without migrating
+// their data, so it only takes bricks whose data stays on the other bricks of
+// their set: lowering the replica count or dropping arbiters.
+//
+// Deprecated: whole sets are refused, /gluster/volume/shrink checks the
+// capacity and migrates them off first.
 func ProcessVolumeRemoveBrick(w http.ResponseWriter, r *http.Request) {
+	var rsp CommonVolumeResponse
+	defer writeResponse(w, &rsp)
+
 	// analyze request
-	body, e := ioutil.ReadAll(r.Body)
-	defer r.Body.Close()
-	if e != nil {
+	var volRemoveBrickReq VolumeRemoveBrickRequest
+	if e := readRequest(r, &volRemoveBrickReq); e != nil {
 		L.Gluster.Error(e.Error())
-		w.WriteHeader(500)
+		rsp.Fail(e)
 		return
 	}
 
-	var volRemoveBrickReq VolumeRemoveBrickRequest
-	e = json.Unmarshal(body, &volRemoveBrickReq)
+	//L.Gluster.Debugf("volRemoveBrickReq is %+v", volRemoveBrickReq)
+
+	release, e := lockForRequest(r, volumeLock(volRemoveBrickReq.Volname), "remove-brick")
 	if e != nil {
 		L.Gluster.Error(e.Error())
-		w.WriteHeader(500)
+		rsp.Fail(e)
 		return
 	}
-
-	//L.Gluster.Debugf("volRemoveBrickReq is %+v", volRemoveBrickReq)
-
-	rsp := RemoveBrick(volRemoveBrickReq)
-	buf, e := json.Marshal(rsp)
+	volRemoveBrickReq.replica, e = forceRemovable(r.Context(), volRemoveBrickReq)
 	if e != nil {
+		release()
 		L.Gluster.Error(e.Error())
-		rsp.Result = "ERROR"
-		rsp.Errors = e.Error()
+		rsp.Fail(e)
 		return
 	}
-	w.Write(buf)
-	return
+
+	job := jobs.Start("remove-brick", volRemoveBrickReq.Volname, locked(release, removeBrickJob(volRemoveBrickReq)))
+	rsp.JobID = job.ID
+	rsp.Result = "OK"
 }
 
 func ProcessVolumeStatus(w http.ResponseWriter, r *http.Request) {
 	var rsp VolumeStatusResponse
-	defer func() {
-		buf, e := json.Marshal(&rsp)
-		if e != nil {
-			L.Gluster.Error(e.Error())
-			w.WriteHeader(500)
-			return
-		}
-		w.Write([]byte(buf))
-	}()
+	defer writeResponse(w, &rsp)
 
 	// analyze request
-	body, e := ioutil.ReadAll(r.Body)
-	if e != nil {
+	var volumeInfoReq VolumeQueryRequest
+	if e := readRequest(r, &volumeInfoReq); e != nil {
 		L.Gluster.Error(e.Error())
-		rsp.Result = "ERROR"
-		rsp.Errors = e.Error()
+		rsp.Fail(e)
 		return
 	}
 
-	var volumeInfoReq CommonVolumeRequest
-	e = json.Unmarshal(body, &volumeInfoReq)
-	if e != nil {
-		L.Gluster.Error(e.Error())
-		rsp.Result = "ERROR"
-		rsp.Errors = e.Error()
-		return
+	volname := "all" // an empty volname queries every volume
+	if volumeInfoReq.Volname != "" {
+		volname = volumeInfoReq.Volname
 	}
 
 	// run command in docker
 	//cmdString := fmt.Sprintf("docker exec glusterfs  gluster volume info %s --xml", volumeInfoReq.Volname)
-	cmdString := fmt.Sprintf("gluster volume status %s --xml", volumeInfoReq.Volname)
-	L.Gluster.Info(cmdString)
-	cmd := exec.Command("sh", "-c", cmdString)
-	output, e := cmd.CombinedOutput()
+	output, e := runGluster(r.Context(), "volume", "status", volname, "--xml")
 	if e != nil {
 		L.Gluster.Error(string(output))
-		rsp.Result = "ERROR"
-		rsp.Errors = string(output)
+		rsp.Fail(glusterError(output, e))
 		return
 	}
 
@@ -622,145 +623,79 @@ func ProcessVolumeStatus(w http.ResponseWriter, r *http.Request) {
 	var volstatusXML VolumeStatusXML
 	e = xml.Unmarshal(output, &volstatusXML)
 	if e != nil {
-		rsp.Result = "ERROR"
-		rsp.Errors = e.Error()
 		L.Gluster.Error(e.Error())
+		rsp.Fail(e)
+		return
 	}
 	//L.Gluster.Infof("XML is %+v", volstatusXML)
 
-	rsp.VolumeStatusXML = volstatusXML
-	rsp.Result = "OK"
-
-}
-
-func ProcessVolumeHealth(w http.ResponseWriter, r *http.Request) {
-	var rsp CommonVolumeResponse
-	defer func() {
-		buf, e := json.Marshal(&rsp)
-		if e != nil {
-			L.Gluster.Error(e.Error())
-			w.WriteHeader(500)
-			return
-		}
-		w.Write([]byte(buf))
-	}()
-
-	// analyze request
-	body, e := ioutil.ReadAll(r.Body)
+	bricks, e := parser.ParseVolumeStatus(output)
 	if e != nil {
 		L.Gluster.Error(e.Error())
-		rsp.Result = "ERROR"
-		rsp.Errors = e.Error()
+		rsp.Fail(e)
 		return
 	}
-
-	var volumeInfoReq CommonVolumeRequest
-	e = json.Unmarshal(body, &volumeInfoReq)
+	volumes, e := volumesInfo(r.Context(), volname)
 	if e != nil {
 		L.Gluster.Error(e.Error())
-		rsp.Result = "ERROR"
-		rsp.Errors = e.Error()
+		rsp.Fail(e)
 		return
 	}
-
-	if volumeInfoReq.Volname == "" {
-		L.Gluster.Error(errors.New("Volume Name cannot be empty"))
-		rsp.Result = "ERROR"
-		rsp.Errors = "Volume Name cannot be empty"
-		return
-	}
-
-	// run command in docker
-	//cmdString := fmt.Sprintf("docker exec glusterfs  gluster volume info %s --xml", volumeInfoReq.Volname)
-	cmdString := fmt.Sprintf("gluster volume heal %s full", volumeInfoReq.Volname)
-	L.Gluster.Info(cmdString)
-	cmd := exec.Command("sh", "-c", cmdString)
-	output, e := cmd.CombinedOutput()
-	if e != nil {
-		L.Gluster.Error(string(output))
-		rsp.Result = "ERROR"
-		rsp.Errors = string(output)
-		return
+	rsp.Volumes = []parser.Volume{}
+	for _, volume := range volumes {
+		if volume.Status == parser.VolumeStarted { // status only reports started volumes
+			volume.ApplyStatus(bricks)
+			rsp.Volumes = append(rsp.Volumes, volume)
+		}
 	}
 
-	L.Gluster.Debug(string(output))
-
-	rsp.Errors = string(output)
+	rsp.VolumeStatusXML = volstatusXML
 	rsp.Result = "OK"
+
 }
 
 func ProcessVolumeReBalance(w http.ResponseWriter, r *http.Request) {
 	var rsp VolumeReBalanceResponse
-	defer func() {
-		buf, e := json.Marshal(&rsp)
-		if e != nil {
-			L.Gluster.Error(e.Error())
-			w.WriteHeader(500)
-			return
-		}
-		w.Write([]byte(buf))
-	}()
+	defer writeResponse(w, &rsp)
 
 	// analyze request
-	body, e := ioutil.ReadAll(r.Body)
-	if e != nil {
-		L.Gluster.Error(e.Error())
-		rsp.Result = "ERROR"
-		rsp.Errors = e.Error()
-		return
-	}
-
 	var volumeReBalanceReq VolumeReBalanceRequest
-	e = json.Unmarshal(body, &volumeReBalanceReq)
-	if e != nil {
+	if e := readRequest(r, &volumeReBalanceReq); e != nil {
 		L.Gluster.Error(e.Error())
-		rsp.Result = "ERROR"
-		rsp.Errors = e.Error()
-		return
-	}
-
-	if volumeReBalanceReq.Volname == "" {
-		L.Gluster.Error(errors.New("Volume Name cannot be empty"))
-		rsp.Result = "ERROR"
-		rsp.Errors = "Volume Name cannot be empty"
+		rsp.Fail(e)
 		return
 	}
 
-	switch volumeReBalanceReq.Options {
-	case "start":
-		break
-	case "stop":
-		break
-	case "status":
-		break
-	default:
-		L.Gluster.Error(errors.New("Volume Options illegal"))
-		rsp.Result = "ERROR"
-		rsp.Errors = "Volume Options illegal"
+	// stop and status must get through while the rebalance job holds the lock
+	if volumeReBalanceReq.Options == "start" {
+		release, e := lockForRequest(r, volumeLock(volumeReBalanceReq.Volname), "rebalance")
+		if e != nil {
+			L.Gluster.Error(e.Error())
+			rsp.Fail(e)
+			return
+		}
+		job := jobs.Start("rebalance", volumeReBalanceReq.Volname, locked(release, rebalanceJob(volumeReBalanceReq.Volname)))
+		rsp.JobID = job.ID
+		rsp.Result = "OK"
 		return
 	}
 
 	// run command in docker
 	//cmdString := fmt.Sprintf("docker exec glusterfs  gluster volume info %s --xml", volumeInfoReq.Volname)
-	cmdString := fmt.Sprintf("gluster volume rebalance %s %s --xml", volumeReBalanceReq.Volname, volumeReBalanceReq.Options)
-	L.Gluster.Info(cmdString)
-	cmd := exec.Command("sh", "-c", cmdString)
-	output, err := cmd.CombinedOutput()
+	output, e := runGluster(r.Context(), "volume", "rebalance", volumeReBalanceReq.Volname, volumeReBalanceReq.Options, "--xml")
+	if e != nil {
+		L.Gluster.Error(string(output))
+		rsp.Fail(glusterError(output, e))
+		return
+	}
 
 	L.Gluster.Debug(string(output))
 
 	var volumeReBalanceXML VolumeReBalanceXML
 	e = xml.Unmarshal(output, &volumeReBalanceXML)
 	if e != nil {
-		rsp.Result = "ERROR"
-		rsp.Errors = e.Error()
 		L.Gluster.Error(e.Error())
-	}
-
-	if err != nil {
-		L.Gluster.Error(volumeReBalanceXML)
-		rsp.Result = "ERROR"
-		rsp.Errors = volumeReBalanceXML.OpErrstr
+		rsp.Fail(e)
 		return
 	}
 
@@ -769,3 +704,72 @@ func ProcessVolumeReBalance(w http.ResponseWriter, r *http.Request) {
 
 	rsp.Result = "OK"
 }
+
+// rebalanceJob starts a rebalance and follows it until the aggregate status is final.
+// Cancelling the job stops the rebalance.
+func rebalanceJob(volname string) JobFunc {
+	return func(ctx context.Context, update func(JobProgress)) (string, error) {
+		output, e := runGluster(ctx, "volume", "rebalance", volname, "start", "--xml")
+		if e != nil {
+			return string(output), glusterError(output, e)
+		}
+		return followRebalance(ctx, volname, update)
+	}
+}
+
+// followRebalance polls a started rebalance, full or fix-layout, until the
+// aggregate status is final. Cancelling ctx stops the rebalance.
+func followRebalance(ctx context.Context, volname string, update func(JobProgress)) (string, error) {
+	for {
+		if e := sleepContext(ctx, pollInterval); e != nil {
+			runGlusterConfirm(context.Background(), "volume", "rebalance", volname, "stop")
+			return "", e
+		}
+		output, e := runGluster(ctx, "volume", "rebalance", volname, "status", "--xml")
+		if e != nil {
+			return string(output), glusterError(output, e)
+		}
+
+		var volumeReBalanceXML VolumeReBalanceXML
+		if e := xml.Unmarshal(output, &volumeReBalanceXML); e != nil {
+			return string(output), e
+		}
+		p := rebalanceProgress(volumeReBalanceXML.VolReBalance)
+		update(p)
+		if taskFinished(p.Status) {
+			if p.Status != "completed" && p.Status != "fix-layout completed" {
+				return string(output), fmt.Errorf("rebalance %s", p.Status)
+			}
+			return string(output), nil
+		}
+	}
+}
+
+func rebalanceProgress(volReBalance VolReBalance) JobProgress {
+	aggregate := volReBalance.Aggregate
+	p := JobProgress{
+		Status:     aggregate.StatusStr,
+		Files:      aggregate.Files,
+		Size:       aggregate.Size,
+		Failures:   aggregate.Failures,
+		Skipped:    aggregate.Skipped,
+		Runtime:    aggregate.Runtime,
+		NodesTotal: len(volReBalance.Node),
+	}
+	for _, node := range volReBalance.Node {
+		if taskFinished(node.StatusStr) {
+			p.NodesDone++
+		}
+	}
+	return p
+}
+
+// taskFinished reports whether a rebalance / remove-brick statusStr is final.
+func taskFinished(statusStr string) bool {
+	for _, final := range []string{"completed", "failed", "stopped"} {
+		if strings.HasSuffix(statusStr, final) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,165 @@
+package gluster
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestVolumeCreate(t *testing.T) {
+	tests := []struct {
+		name  string
+		body  string
+		fail  string // command that fails
+		state JobState
+		want  []string
+	}{
+		{
+			name:  "distribute",
+			body:  `{"volname": "dist", "bricks": ["node1:/data/dist", "node2:/data/dist"]}`,
+			state: JobSucceeded,
+			want: []string{
+				"gluster volume create dist node1:/data/dist node2:/data/dist",
+				"gluster volume start dist",
+			},
+		},
+		{
+			name:  "replica arbiter",
+			body:  `{"volname": "arb", "replica": 3, "arbiter": 1, "transport": "tcp", "bricks": ["node1:/data/arb", "node2:/data/arb", "node3:/data/arb"]}`,
+			state: JobSucceeded,
+			want: []string{
+				"gluster volume create arb replica 3 arbiter 1 transport tcp node1:/data/arb node2:/data/arb node3:/data/arb",
+				"gluster volume start arb",
+			},
+		},
+		{
+			name:  "bricks kept in order",
+			body:  `{"volname": "order", "replica": 2, "bricks": ["node1:/data/b1", "node2:/data/b1", "node2:/data/b2", "node1:/data/b2"]}`,
+			state: JobSucceeded,
+			want: []string{
+				"gluster volume create order replica 2 node1:/data/b1 node2:/data/b1 node2:/data/b2 node1:/data/b2",
+				"gluster volume start order",
+			},
+		},
+		{
+			name:  "disperse on one host with force",
+			body:  `{"volname": "ec", "disperse": 3, "redundancy": 1, "force": "true", "bricks": ["node1:/data/ec1", "node1:/data/ec2", "node1:/data/ec3"]}`,
+			state: JobSucceeded,
+			want: []string{
+				"gluster volume create ec disperse 3 redundancy 1 node1:/data/ec1 node1:/data/ec2 node1:/data/ec3 force",
+				"gluster volume start ec",
+			},
+		},
+		{
+			name:  "not started",
+			body:  `{"volname": "created", "bricks": ["node1:/data/created"], "start": "false"}`,
+			state: JobSucceeded,
+			want:  []string{"gluster volume create created node1:/data/created"},
+		},
+		{
+			name:  "options before start",
+			body:  `{"volname": "virt", "replica": 2, "bricks": ["node1:/data/virt", "node2:/data/virt"], "options": [{"name": "group", "value": "virt"}, {"name": "storage.owner-uid", "value": "36"}]}`,
+			state: JobSucceeded,
+			want: []string{
+				"gluster volume create virt replica 2 node1:/data/virt node2:/data/virt",
+				"gluster volume set virt group virt",
+				"gluster volume set virt storage.owner-uid 36",
+				"gluster volume start virt",
+			},
+		},
+		{
+			name:  "atomic rollback",
+			body:  `{"volname": "atomic", "bricks": ["node1:/data/atomic"], "atomic": "true"}`,
+			fail:  "start",
+			state: JobFailed,
+			want: []string{
+				"gluster volume create atomic node1:/data/atomic",
+				"gluster volume start atomic",
+				"gluster volume delete atomic",
+			},
+		},
+		{
+			name:  "atomic rollback of options",
+			body:  `{"volname": "atomic", "bricks": ["node1:/data/atomic"], "options": [{"name": "group", "value": "virt"}], "atomic": "true"}`,
+			fail:  "set",
+			state: JobFailed,
+			want: []string{
+				"gluster volume create atomic node1:/data/atomic",
+				"gluster volume set atomic group virt",
+				"gluster volume delete atomic",
+			},
+		},
+		{
+			name:  "failed start kept",
+			body:  `{"volname": "kept", "bricks": ["node1:/data/kept"]}`,
+			fail:  "start",
+			state: JobFailed,
+			want: []string{
+				"gluster volume create kept node1:/data/kept",
+				"gluster volume start kept",
+			},
+		},
+		{
+			name:  "failed create",
+			body:  `{"volname": "failed", "bricks": ["node1:/data/failed"], "atomic": "true"}`,
+			fail:  "create",
+			state: JobFailed,
+			want:  []string{"gluster volume create failed node1:/data/failed"},
+		},
+		{
+			name:  "legacy type and count",
+			body:  `{"volname": "legacy", "type": "replica", "count": "2", "bricks": ["node1:/data/legacy", "node2:/data/legacy"]}`,
+			state: JobSucceeded,
+			want: []string{
+				"gluster volume create legacy replica 2 node1:/data/legacy node2:/data/legacy",
+				"gluster volume start legacy",
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			f := fakeGluster(t)
+			if test.fail != "" {
+				f.On("volume "+test.fail+": failed", errExit, "gluster", "volume", test.fail)
+			}
+			var rsp CommonVolumeResponse
+			if status := serve(t, ProcessVolumeCreate, test.body, &rsp); status != http.StatusOK || rsp.JobID == "" {
+				t.Fatalf("status %d, %+v", status, rsp)
+			}
+			if job := waitJob(t, rsp.JobID); job.State != test.state {
+				t.Errorf("job %s, want %s: %+v", job.State, test.state, job.Error)
+			}
+			checkCalls(t, f, test.want)
+			// create and delete ask for confirmation
+			for _, c := range f.Calls {
+				confirm := c.Args[1] == "create" || c.Args[1] == "delete"
+				if (c.Stdin == "y\n") != confirm {
+					t.Errorf("%s stdin %q", c, c.Stdin)
+				}
+			}
+		})
+	}
+}
+
+// TestVolumeCreateSteps checks each phase is reported, the failed one with
+// its error and the rollback after it.
+func TestVolumeCreateSteps(t *testing.T) {
+	fakeGluster(t).On("volume start: atomic: failed: Commit failed on node2", errExit, "gluster", "volume", "start")
+	var rsp CommonVolumeResponse
+	if status := serve(t, ProcessVolumeCreate, `{"volname": "atomic", "bricks": ["node1:/data/atomic"], "atomic": "true"}`, &rsp); status != http.StatusOK {
+		t.Fatalf("status %d, %+v", status, rsp)
+	}
+	job := waitJob(t, rsp.JobID)
+	if job.Error == nil || !strings.Contains(job.Error.Message, "Commit failed on node2") {
+		t.Errorf("error = %+v, want the start failure", job.Error)
+	}
+	want := map[string]StepState{stepCreate: StepDone, stepOptions: StepSkipped, stepStart: StepFailed, stepRollback: StepDone}
+	if len(job.Progress.Steps) != len(want) {
+		t.Fatalf("steps = %+v", job.Progress.Steps)
+	}
+	for _, step := range job.Progress.Steps {
+		if step.State != want[step.Name] {
+			t.Errorf("step %s %s, want %s", step.Name, step.State, want[step.Name])
+		}
+	}
+}